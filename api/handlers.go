@@ -2,19 +2,30 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
 
 	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/INFURA/infra-test-benjamin-mateo/config"
 	"github.com/INFURA/infra-test-benjamin-mateo/node"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
 
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 // Warning each HTTP request gets its own go routine we might have concurrent code running against Server
 
+// maxBodySize limits the size of the posted bodies
+const maxBodySize = 1 << 20
+
 // respond is response helper
 // it can be convenient if we want to easily customize response type
 func (s *Server) respond(w http.ResponseWriter, r *http.Request, data interface{}, status int) {
@@ -58,34 +69,162 @@ func (s *Server) handleCall(w http.ResponseWriter, r *http.Request) {
 	if nok := !s.checkTypeError(w, r, data, err); nok {
 		return
 	}
-	s.Logger.Infof("calling from:%s to:%s", from, to)
+	block, err := blockParam(r)
+	if nok := !s.checkTypeError(w, r, block, err); nok {
+		return
+	}
+	s.Logger.Infof("calling from:%s to:%s at block:%s", from, to, block)
 	w.Header().Add("Content-Type", "application/json")
 
+	// the call is priced so the node checks the sender can pay for its gas, it needs the price first so they can't share a batch
 	gasPrice, err := s.client.GetGasPrice(r.Context())
 	if err != nil {
 		s.Logger.Warn("can't get gas price error: ", err)
-		s.respond(w, r, err, http.StatusFailedDependency)
+		s.respondNodeError(w, r, err)
+		return
 	}
 
+	g := eth.QuantityFromUInt64(uint64(gas))
+	v := eth.QuantityFromUInt64(uint64(value))
+	gp := eth.QuantityFromBigInt(gasPrice)
 	p := node.CallParams{
 		Data:     *data,
 		From:     *from,
-		Gas:      eth.QuantityFromUInt64(uint64(gas)),
+		Gas:      &g,
 		To:       *to,
-		Value:    eth.QuantityFromUInt64(uint64(value)),
-		GasPrice: eth.QuantityFromUInt64(uint64(gasPrice)),
+		Value:    &v,
+		GasPrice: &gp,
 	}
 
-	res, err := s.client.CallContract(r.Context(), p)
+	res, err := s.client.CallContract(r.Context(), p, block)
 	if err != nil {
 		s.Logger.Warnf("call from:%s to:%s failed err:%s", from, to, err)
-		s.respond(w, r, err.Error(), http.StatusNotFound)
+		s.respondNodeError(w, r, err)
 	} else {
 		s.respond(w, r, res, http.StatusOK)
 	}
 
 }
 
+// handleEstimateGas handles calls to eth_estimateGas which returns the gas needed by a call
+func (s *Server) handleEstimateGas(w http.ResponseWriter, r *http.Request) {
+	s.Logger.Info("estimate gas")
+	w.Header().Add("Content-Type", "application/json")
+	p, err := s.readCallParams(w, r)
+	if nok := !s.checkTypeError(w, r, p, err); nok {
+		return
+	}
+	block, err := blockParam(r)
+	if nok := !s.checkTypeError(w, r, block, err); nok {
+		return
+	}
+
+	gas, err := s.client.EstimateGas(r.Context(), p, block)
+	if err != nil {
+		s.Logger.Warnf("estimate gas from:%s to:%s failed err:%s", p.From, p.To, err)
+		s.respondNodeError(w, r, err)
+	} else {
+		data := struct {
+			Gas uint64 `json:"gas"`
+		}{gas}
+		s.respond(w, r, data, http.StatusOK)
+	}
+}
+
+// handleCreateAccessList handles calls to eth_createAccessList which returns the storage a call accesses
+func (s *Server) handleCreateAccessList(w http.ResponseWriter, r *http.Request) {
+	s.Logger.Info("create access list")
+	w.Header().Add("Content-Type", "application/json")
+	p, err := s.readCallParams(w, r)
+	if nok := !s.checkTypeError(w, r, p, err); nok {
+		return
+	}
+	block, err := blockParam(r)
+	if nok := !s.checkTypeError(w, r, block, err); nok {
+		return
+	}
+
+	list, err := s.client.CreateAccessList(r.Context(), p, block)
+	if err != nil {
+		s.Logger.Warnf("create access list from:%s to:%s failed err:%s", p.From, p.To, err)
+		s.respondNodeError(w, r, err)
+	} else {
+		data := struct {
+			AccessList []node.AccessTuple `json:"accessList"`
+			GasUsed    uint64             `json:"gasUsed"`
+		}{list.AccessList, list.GasUsed.UInt64()}
+		s.respond(w, r, data, http.StatusOK)
+	}
+}
+
+// readCallParams decodes the call parameters posted in the body
+func (s *Server) readCallParams(w http.ResponseWriter, r *http.Request) (node.CallParams, error) {
+	p := node.CallParams{}
+	if r.Body == nil {
+		return p, errors.New("missing body")
+	}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&p)
+	return p, err
+}
+
+// respondNodeError answers with the http status matching the kind of an error of the node.
+// The JSON-RPC code and data are returned with the message, and the decoded reason when the execution reverted.
+// A rate limit tells the client when to retry.
+func (s *Server) respondNodeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := errorStatus(err)
+	switch e := errors.Cause(err).(type) {
+	case *node.RateLimitError:
+		if e.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds()))))
+		}
+		if e.RPC != nil {
+			s.respondRPCError(w, r, e.RPC, status)
+			return
+		}
+		s.respond(w, r, e.Error(), status)
+	case *node.RevertError:
+		data := struct {
+			Error  string   `json:"error"`
+			Code   int      `json:"code,omitempty"`
+			Reason string   `json:"reason,omitempty"`
+			Data   eth.Data `json:"data,omitempty"`
+		}{e.Message, e.Code, e.Reason, e.Data}
+		s.respond(w, r, data, status)
+	case *node.RPCError:
+		s.respondRPCError(w, r, e, status)
+	default:
+		s.respond(w, r, err.Error(), status)
+	}
+}
+
+// respondRPCError answers with the message, code and data of a JSON-RPC error
+func (s *Server) respondRPCError(w http.ResponseWriter, r *http.Request, e *node.RPCError, status int) {
+	data := struct {
+		Error string          `json:"error"`
+		Code  int             `json:"code,omitempty"`
+		Data  json.RawMessage `json:"data,omitempty"`
+	}{e.Message, e.Code, e.Data}
+	s.respond(w, r, data, status)
+}
+
+// errorStatus maps the kind of an error of the node to an http status, errors that can't be classified are a bad gateway
+func errorStatus(err error) int {
+	switch node.Classify(err) {
+	case node.ErrInvalidParams:
+		return http.StatusBadRequest
+	case node.ErrNotFound:
+		return http.StatusNotFound
+	case node.ErrExecutionReverted:
+		return http.StatusUnprocessableEntity
+	case node.ErrRateLimited:
+		return http.StatusTooManyRequests
+	case node.ErrTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
+
 // handleGetTransactionByHash returns transaction by hash
 func (s *Server) handleGetTransactionByHash() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -95,16 +234,246 @@ func (s *Server) handleGetTransactionByHash() http.HandlerFunc {
 
 		w.Header().Add("Content-Type", "application/json")
 
-		t, err := s.client.TransactionByHash(r.Context(), hash)
+		t, err := s.transactionByHash(r.Context(), hash)
 		if err != nil {
 			s.Logger.Warnf("Tx hash does not exist: %s err:%s", hash, err)
-			s.respond(w, r, err.Error(), http.StatusNotFound)
+			s.respondNodeError(w, r, err)
 		} else {
+			if t.BlockNumber == nil {
+				// pending, it may be mined or replaced anytime
+				w.Header().Set("Cache-Control", "no-cache")
+			} else {
+				s.setBlockMaxAge(w, t.BlockNumber.UInt64())
+			}
 			s.respond(w, r, t, http.StatusOK)
 		}
 	}
 }
 
+// handleSendRawTransaction validates a signed transaction locally then broadcasts it with eth_sendRawTransaction
+func (s *Server) handleSendRawTransaction(w http.ResponseWriter, r *http.Request) {
+	s.Logger.Info("send raw transaction")
+	w.Header().Add("Content-Type", "application/json")
+	body := struct {
+		Raw string `json:"raw"`
+	}{}
+	var err error
+	if r.Body == nil {
+		err = errors.New("missing body")
+	} else {
+		err = json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&body)
+	}
+	if nok := !s.checkTypeError(w, r, body, err); nok {
+		return
+	}
+	tx, err := node.DecodeRawTransaction(body.Raw)
+	if nok := !s.checkTypeError(w, r, tx, err); nok {
+		return
+	}
+
+	chainID, err := s.getChainID(r.Context())
+	if err != nil {
+		s.Logger.Warn("can't get chain id error: ", err)
+		s.respondNodeError(w, r, err)
+		return
+	}
+	err = tx.Validate(chainID)
+	if nok := !s.checkTypeError(w, r, tx, err); nok {
+		return
+	}
+
+	hash, err := s.client.SendRawTransaction(r.Context(), body.Raw)
+	if err != nil {
+		s.Logger.Warnf("tx:%s from:%s nonce:%d rejected err:%s", tx.Hash.Hex(), tx.From.Hex(), tx.Nonce, err)
+		if rejected, ok := errors.Cause(err).(*node.TxRejectedError); ok {
+			s.respond(w, r, rejected.Error(), txRejectedStatus(rejected))
+		} else {
+			s.respondNodeError(w, r, err)
+		}
+		return
+	}
+	if !strings.EqualFold(string(hash), tx.Hash.Hex()) {
+		s.Logger.Warnf("node returned tx hash:%s expected:%s", hash, tx.Hash.Hex())
+	}
+
+	data := struct {
+		Hash  string `json:"hash"`
+		From  string `json:"from"`
+		Nonce uint64 `json:"nonce"`
+	}{tx.Hash.Hex(), tx.From.Hex(), tx.Nonce}
+	s.respond(w, r, data, http.StatusAccepted)
+}
+
+// txRejectedStatus maps the reason why the node rejected a transaction to an http status
+func txRejectedStatus(rejected *node.TxRejectedError) int {
+	switch rejected.Reason {
+	case node.ErrNonceTooLow, node.ErrReplacementUnderpriced, node.ErrAlreadyKnown:
+		return http.StatusConflict
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
+
+// handleGetTransactionReceipt returns the receipt of a mined transaction with its status and total fee
+func (s *Server) handleGetTransactionReceipt(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	hash := params["hash"]
+	s.Logger.Infof("Request received to get a transaction receipt by hash: %s", hash)
+	w.Header().Add("Content-Type", "application/json")
+	unit, err := parseUnit(r.URL.Query().Get("unit"))
+	if nok := !s.checkTypeError(w, r, unit, err); nok {
+		return
+	}
+
+	rec, err := s.transactionReceipt(r.Context(), hash)
+	if err != nil {
+		s.Logger.Warnf("can't get receipt for tx hash: %s err:%s", hash, err)
+		s.respondNodeError(w, r, err)
+		return
+	}
+
+	data := struct {
+		TransactionHash   eth.Hash     `json:"transactionHash"`
+		BlockHash         eth.Hash     `json:"blockHash"`
+		BlockNumber       uint64       `json:"blockNumber"`
+		From              eth.Address  `json:"from"`
+		To                *eth.Address `json:"to"`
+		Status            string       `json:"status"`
+		GasUsed           string       `json:"gasUsed"`
+		EffectiveGasPrice string       `json:"effectiveGasPrice,omitempty"`
+		Fee               string       `json:"fee,omitempty"`
+		Unit              string       `json:"unit"`
+		ContractAddress   *eth.Address `json:"contractAddress"`
+		Logs              []eth.Log    `json:"logs"`
+	}{
+		TransactionHash: rec.TransactionHash,
+		BlockHash:       rec.BlockHash,
+		BlockNumber:     rec.BlockNumber.UInt64(),
+		From:            rec.From,
+		To:              rec.To,
+		Status:          receiptStatus(rec),
+		GasUsed:         rec.GasUsed.Big().String(),
+		Unit:            unit,
+		ContractAddress: rec.ContractAddress,
+		Logs:            rec.Logs,
+	}
+	if rec.EffectiveGasPrice != nil {
+		data.EffectiveGasPrice = formatUnit(rec.EffectiveGasPrice.Big(), unit)
+		data.Fee = formatUnit(rec.Fee(), unit)
+	}
+	s.setBlockMaxAge(w, data.BlockNumber)
+	s.respond(w, r, data, http.StatusOK)
+}
+
+// default and max number of struct logs in a page of a trace
+const (
+	defaultStructLogLimit = 100
+	maxStructLogLimit     = 1000
+)
+
+// handleTraceTransaction replays a transaction with the tracer requested: call (default), prestate or structlog
+func (s *Server) handleTraceTransaction(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	hash := params["hash"]
+	query := r.URL.Query()
+	tracer := query.Get("tracer")
+	s.Logger.Infof("Request received to trace transaction: %s tracer:%s", hash, tracer)
+	w.Header().Add("Content-Type", "application/json")
+
+	var res interface{}
+	var err error
+	switch tracer {
+	case "", "call":
+		res, err = s.client.TraceCalls(r.Context(), hash)
+	case "prestate":
+		res, err = s.client.TracePrestate(r.Context(), hash, query.Get("diff") == "true")
+	case "structlog":
+		offset, limit, perr := pagination(query.Get("offset"), query.Get("limit"))
+		if perr != nil {
+			s.respond(w, r, perr.Error(), http.StatusBadRequest)
+			return
+		}
+		var trace *node.StructLogTrace
+		trace, err = s.structLogTrace(r.Context(), hash, node.TraceConfig{EnableMemory: query.Get("memory") == "true"})
+		if err == nil {
+			res = structLogPage(trace, offset, limit)
+		}
+	default:
+		s.respond(w, r, "unknown tracer, use call, prestate or structlog", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		s.Logger.Warnf("can't trace tx hash: %s err:%s", hash, err)
+		s.respondNodeError(w, r, err)
+		return
+	}
+	s.respond(w, r, res, http.StatusOK)
+}
+
+// pagination parses the offset and limit of a page
+func pagination(offset, limit string) (int, int, error) {
+	o, l := 0, defaultStructLogLimit
+	var err error
+	if offset != "" {
+		if o, err = strconv.Atoi(offset); err != nil || o < 0 {
+			return 0, 0, errors.New("invalid offset")
+		}
+	}
+	if limit != "" {
+		if l, err = strconv.Atoi(limit); err != nil || l < 1 || l > maxStructLogLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxStructLogLimit)
+		}
+	}
+	return o, l, nil
+}
+
+// structLogPage returns a page of the struct logs with the total count, the revert reason is decoded from the return value
+func structLogPage(trace *node.StructLogTrace, offset, limit int) interface{} {
+	total := len(trace.StructLogs)
+	end := offset + limit
+	if offset > total {
+		offset = total
+	}
+	if end > total {
+		end = total
+	}
+	page := struct {
+		Gas          uint64           `json:"gas"`
+		Failed       bool             `json:"failed"`
+		ReturnValue  string           `json:"returnValue"`
+		RevertReason string           `json:"revertReason,omitempty"`
+		Total        int              `json:"total"`
+		Offset       int              `json:"offset"`
+		Limit        int              `json:"limit"`
+		StructLogs   []node.StructLog `json:"structLogs"`
+	}{
+		Gas:         trace.Gas,
+		Failed:      trace.Failed,
+		ReturnValue: trace.ReturnValue,
+		Total:       total,
+		Offset:      offset,
+		Limit:       limit,
+		StructLogs:  trace.StructLogs[offset:end],
+	}
+	if trace.Failed && trace.ReturnValue != "" {
+		page.RevertReason = node.DecodeRevertReason("0x" + strings.TrimPrefix(trace.ReturnValue, "0x"))
+	}
+	return page
+}
+
+// receiptStatus returns success or failed, receipts from before byzantium have no status
+func receiptStatus(rec *node.Receipt) string {
+	switch {
+	case rec.Status == nil:
+		return "unknown"
+	case rec.Status.UInt64() == 1:
+		return "success"
+	default:
+		return "failed"
+	}
+}
+
 // handleGetBlockByHeight handle the root api
 func (s *Server) handleGetBlockByHeight(full bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -119,43 +488,114 @@ func (s *Server) handleGetBlockByHeight(full bool) http.HandlerFunc {
 		w.Header().Add("Content-Type", "application/json")
 		s.Logger.Infof("Request received to get a block by height: %s full: %v", height, full)
 
-		t, err := s.client.BlockByNumber(r.Context(), uint64(h), full)
+		t, err := s.blockByNumber(r.Context(), uint64(h), full)
 		if err != nil {
 			s.Logger.Warnf("can't get block height:%s err:%s", height, err)
-			s.respond(w, r, err.Error(), http.StatusNotFound)
+			s.respondNodeError(w, r, err)
 		} else {
+			s.setBlockMaxAge(w, uint64(h))
 			s.respond(w, r, t, http.StatusOK)
 		}
 
 	}
 }
 
-// handleGetTransactionByIDInBlockHash handle the root api
+// handleGetTransactionByIDInBlockHash get a transaction by its index in a block designed by its height or its hash
 func (s *Server) handleGetTransactionByIDInBlockHash(w http.ResponseWriter, r *http.Request) {
 	s.Logger.Info("get Transaction By ID In Block Hash")
 	w.Header().Add("Content-Type", "application/json")
 	params := mux.Vars(r)
-	height := params["height"]
-	h, err := strconv.ParseInt(height, 10, 64)
+	id := params["id"]
+	i, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
-		s.Logger.Infof("%d of type %T", h, h)
+		s.Logger.Infof("%d of type %T", i, i)
 		s.respond(w, r, err.Error(), http.StatusBadRequest)
+		return
 	}
-	id := params["id"]
-	i, err := strconv.ParseInt(id, 10, 64)
+
+	var res *eth.Transaction
+	block, byHash := params["hash"]
+	if byHash {
+		res, err = s.client.TransactionByBlockHashAndIndex(r.Context(), block, uint64(i))
+		// the transactions of a block never change
+		setMaxAge(w, finalMaxAge, true)
+	} else {
+		block = params["height"]
+		h, perr := strconv.ParseInt(block, 10, 64)
+		if perr != nil {
+			s.Logger.Infof("%d of type %T", h, h)
+			s.respond(w, r, perr.Error(), http.StatusBadRequest)
+			return
+		}
+		res, err = s.client.TransactionByBlockNumberAndIndex(r.Context(), uint64(h), uint64(i))
+		s.setBlockMaxAge(w, uint64(h))
+	}
+	if err != nil {
+		s.Logger.Infof("can't get transaction ID:%v in block:%v err:%s", i, block, err)
+		s.respondNodeError(w, r, err)
+	} else {
+		s.respond(w, r, res, http.StatusOK)
+	}
+
+}
+
+// handleGetUncle get the header of an uncle by its index in a block designed by its height or its hash
+func (s *Server) handleGetUncle(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	s.Logger.Infof("get uncle %s of block %s%s", params["index"], params["height"], params["hash"])
+	w.Header().Add("Content-Type", "application/json")
+	i, err := strconv.ParseUint(params["index"], 10, 64)
 	if err != nil {
-		s.Logger.Infof("%d of type %T", h, h)
 		s.respond(w, r, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	res, err := s.client.TransactionByBlockNumberAndIndex(r.Context(), uint64(h), uint64(i))
+	var res *eth.Block
+	if hash, ok := params["hash"]; ok {
+		res, err = s.client.UncleByBlockHashAndIndex(r.Context(), hash, i)
+	} else {
+		h, perr := strconv.ParseUint(params["height"], 10, 64)
+		if perr != nil {
+			s.respond(w, r, perr.Error(), http.StatusBadRequest)
+			return
+		}
+		res, err = s.client.UncleByBlockNumberAndIndex(r.Context(), h, i)
+	}
 	if err != nil {
-		s.Logger.Infof("can't get transaction ID:%v in block height:%v err:%s", i, h, err)
-		s.respond(w, r, err.Error(), http.StatusNotFound)
+		s.Logger.Infof("can't get uncle:%v err:%s", i, err)
+		s.respondNodeError(w, r, err)
 	} else {
 		s.respond(w, r, res, http.StatusOK)
 	}
+}
 
+// handleGetUncleCount get the number of uncles of a block designed by its height or its hash
+func (s *Server) handleGetUncleCount(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	s.Logger.Infof("get uncle count of block %s%s", params["height"], params["hash"])
+	w.Header().Add("Content-Type", "application/json")
+
+	var count uint64
+	var err error
+	if hash, ok := params["hash"]; ok {
+		count, err = s.client.UncleCountByBlockHash(r.Context(), hash)
+	} else {
+		h, perr := strconv.ParseUint(params["height"], 10, 64)
+		if perr != nil {
+			s.respond(w, r, perr.Error(), http.StatusBadRequest)
+			return
+		}
+		count, err = s.client.UncleCountByBlockNumber(r.Context(), h)
+	}
+	if err != nil {
+		s.Logger.Infof("can't get uncle count err:%s", err)
+		s.respondNodeError(w, r, err)
+		return
+	}
+	data := struct {
+		UncleCount uint64 `json:"uncleCount"`
+	}{count}
+	s.respond(w, r, data, http.StatusOK)
 }
 
 // handleGetLogs
@@ -183,7 +623,7 @@ func (s *Server) handleGetLogs(w http.ResponseWriter, r *http.Request) {
 	res, err := s.client.Logs(r.Context(), filter)
 	if err != nil {
 		s.Logger.Warnf("can't get log topic:%v from block:%v to %v", topic, from, to)
-		s.respond(w, r, err.Error(), http.StatusNotFound)
+		s.respondNodeError(w, r, err)
 	} else {
 		s.respond(w, r, res, http.StatusOK)
 	}
@@ -197,11 +637,18 @@ func (s *Server) handleGetBlockByHash(full bool) http.HandlerFunc {
 		w.Header().Add("Content-Type", "application/json")
 		params := mux.Vars(r)
 		hash := params["hash"]
-		res, err := s.client.BlockByHash(r.Context(), hash, full)
+		res, err := s.blockByHash(r.Context(), hash, full)
 		if err != nil {
 			s.Logger.Warn("can't get  Block By Hash error: ", err)
-			s.respond(w, r, err.Error(), http.StatusNotFound)
+			s.respondNodeError(w, r, err)
 		} else {
+			// a block never changes, only whether it is canonical
+			setMaxAge(w, finalMaxAge, true)
+			tag := strings.ToLower(hash)
+			if full {
+				tag += "-full"
+			}
+			setETag(w, tag)
 			s.respond(w, r, res, http.StatusOK)
 		}
 	}
@@ -214,16 +661,19 @@ func (s *Server) handleGetLastBlock(full bool) http.HandlerFunc {
 		s.Logger.Infof("get last block full:%v", full)
 		w.Header().Add("Content-Type", "application/json")
 
-		b, err := s.client.BlockNumber(r.Context())
-		if err != nil {
-			s.Logger.Warn("BlockNumber error: ", err)
-			s.respond(w, r, err.Error(), http.StatusFailedDependency)
+		if b, ok := s.lastBlock(full); ok {
+			setMaxAge(w, headMaxAge, false)
+			s.respond(w, r, b, http.StatusOK)
+			return
 		}
-		t, err := s.client.BlockByNumber(r.Context(), b, full)
+
+		// a single request so that the block is the latest one at the time it is answered
+		t, err := s.client.BlockByNumberOrTag(r.Context(), *eth.MustBlockNumberOrTag(eth.TagLatest), full)
 		if err != nil {
-			s.Logger.Warnf("can't get block height: %v err:%s", b, err)
-			s.respond(w, r, err.Error(), http.StatusNotFound)
+			s.Logger.Warn("can't get last block err: ", err)
+			s.respondNodeError(w, r, err)
 		} else {
+			setMaxAge(w, headMaxAge, false)
 			s.respond(w, r, t, http.StatusOK)
 		}
 	}
@@ -234,54 +684,194 @@ func (s *Server) handleGetLatestBlockID(w http.ResponseWriter, r *http.Request)
 	s.Logger.Info("get last block height")
 	w.Header().Add("Content-Type", "application/json")
 
-	b, err := s.client.BlockNumber(r.Context())
+	var b uint64
+	var err error
+	if head, ok := s.lastBlock(false); ok {
+		b = head.Number.UInt64()
+	} else {
+		b, err = s.client.BlockNumber(r.Context())
+	}
 	if err != nil {
 		s.Logger.Warn("can't get Block Number error: ", err)
-		s.respond(w, r, err.Error(), http.StatusFailedDependency)
+		s.respondNodeError(w, r, err)
 	} else {
 		data := struct {
 			LastBlockHeight uint64 `json:"lastBlockHeight"`
 		}{b}
+		setMaxAge(w, headMaxAge, false)
 		s.respond(w, r, data, http.StatusOK)
 	}
 }
 
-// handleGetGasPrice get the current gas price
+// handleGetGasPrice get the current gas price as a decimal string in the unit requested
 func (s *Server) handleGetGasPrice(w http.ResponseWriter, r *http.Request) {
 	s.Logger.Info("get current gas price")
 	w.Header().Add("Content-Type", "application/json")
+	unit, err := parseUnit(r.URL.Query().Get("unit"))
+	if nok := !s.checkTypeError(w, r, unit, err); nok {
+		return
+	}
 
 	b, err := s.client.GetGasPrice(r.Context())
 	if err != nil {
 		s.Logger.Warn("can't get gas price error: ", err)
-		s.respond(w, r, err.Error(), http.StatusFailedDependency)
+		s.respondNodeError(w, r, err)
 	} else {
 		data := struct {
-			GasPrice uint64 `json:"gasPrice"`
-		}{b}
+			GasPrice string `json:"gasPrice"`
+			Unit     string `json:"unit"`
+		}{formatUnit(b, unit), unit}
+		setMaxAge(w, headMaxAge, false)
 		s.respond(w, r, data, http.StatusOK)
 	}
 
 }
 
-// handleGetBalance get the current balance
+// handleGetFees suggests EIP-1559 fees from the fee history of the recent blocks, as decimal strings in the unit requested
+func (s *Server) handleGetFees(w http.ResponseWriter, r *http.Request) {
+	s.Logger.Info("get fees")
+	w.Header().Add("Content-Type", "application/json")
+	unit, err := parseUnit(r.URL.Query().Get("unit"))
+	if nok := !s.checkTypeError(w, r, unit, err); nok {
+		return
+	}
+	blocks := config.ReadInt("FEE_HISTORY_BLOCKS")
+	if v := r.URL.Query().Get("blocks"); v != "" {
+		blocks, err = strconv.Atoi(v)
+		if err != nil || blocks < 1 || blocks > node.MaxFeeHistoryBlocks {
+			s.respond(w, r, fmt.Sprintf("blocks must be between 1 and %d", node.MaxFeeHistoryBlocks), http.StatusBadRequest)
+			return
+		}
+	}
+
+	f, err := s.client.SuggestFees(r.Context(), blocks)
+	if err != nil {
+		s.Logger.Warn("can't suggest fees error: ", err)
+		s.respondNodeError(w, r, err)
+		return
+	}
+	type fee struct {
+		MaxFeePerGas         string `json:"maxFeePerGas"`
+		MaxPriorityFeePerGas string `json:"maxPriorityFeePerGas"`
+	}
+	toFee := func(f node.Fee) fee {
+		return fee{formatUnit(f.MaxFeePerGas, unit), formatUnit(f.MaxPriorityFeePerGas, unit)}
+	}
+	data := struct {
+		BaseFeePerGas     string `json:"baseFeePerGas"`
+		NextBaseFeePerGas string `json:"nextBaseFeePerGas"`
+		Slow              fee    `json:"slow"`
+		Standard          fee    `json:"standard"`
+		Fast              fee    `json:"fast"`
+		OldestBlock       uint64 `json:"oldestBlock"`
+		Blocks            int    `json:"blocks"`
+		Unit              string `json:"unit"`
+	}{
+		formatUnit(f.BaseFeePerGas, unit),
+		formatUnit(f.NextBaseFeePerGas, unit),
+		toFee(f.Slow),
+		toFee(f.Standard),
+		toFee(f.Fast),
+		f.OldestBlock,
+		f.Blocks,
+		unit,
+	}
+	setMaxAge(w, headMaxAge, false)
+	s.respond(w, r, data, http.StatusOK)
+}
+
+// handleGetBalance get the current balance as a decimal string in the unit requested
 func (s *Server) handleGetBalance(w http.ResponseWriter, r *http.Request) {
 	s.Logger.Info("get  balance")
 	w.Header().Add("Content-Type", "application/json")
 	params := mux.Vars(r)
 	address := params["address"]
-	b, err := s.client.GetBalance(r.Context(), address)
+	unit, err := parseUnit(r.URL.Query().Get("unit"))
+	if nok := !s.checkTypeError(w, r, unit, err); nok {
+		return
+	}
+	block, err := blockParam(r)
+	if nok := !s.checkTypeError(w, r, block, err); nok {
+		return
+	}
+	b, err := s.client.GetBalance(r.Context(), address, block)
 	if err != nil {
-		s.Logger.Warnf("can't get balance for:%s error:%s", address, err)
-		s.respond(w, r, err.Error(), http.StatusFailedDependency)
+		s.Logger.Warnf("can't get balance for:%s at block:%s error:%s", address, block, err)
+		s.respondNodeError(w, r, err)
 	} else {
 		data := struct {
-			Balance uint64 `json:"balance"`
-		}{b}
+			Balance string `json:"balance"`
+			Unit    string `json:"unit"`
+		}{formatUnit(b, unit), unit}
 		s.respond(w, r, data, http.StatusOK)
 	}
 }
 
+// handleGetNodeStatus describes the node and how far its head lags behind the wall clock.
+// It answers 503 when the node is syncing or its head is older than NODE_MAX_HEAD_AGE so that monitors can alert on the status code.
+func (s *Server) handleGetNodeStatus(w http.ResponseWriter, r *http.Request) {
+	s.Logger.Info("get node status")
+	w.Header().Add("Content-Type", "application/json")
+
+	st, err := s.client.Status(r.Context())
+	if err != nil {
+		s.Logger.Warn("can't get node status error: ", err)
+		s.respondNodeError(w, r, err)
+		return
+	}
+
+	maxAge := time.Duration(config.ReadInt("NODE_MAX_HEAD_AGE")) * time.Second
+	data := struct {
+		Healthy       bool                   `json:"healthy"`
+		ChainID       *big.Int               `json:"chainId,omitempty"`
+		NetworkID     string                 `json:"networkId,omitempty"`
+		ClientVersion string                 `json:"clientVersion,omitempty"`
+		PeerCount     *uint64                `json:"peerCount,omitempty"`
+		Sync          *node.SyncStatus       `json:"sync,omitempty"`
+		HeadNumber    uint64                 `json:"headNumber"`
+		HeadHash      *eth.Hash              `json:"headHash"`
+		HeadTimestamp uint64                 `json:"headTimestamp"`
+		HeadLag       float64                `json:"headLagSeconds"`
+		MaxHeadLag    float64                `json:"maxHeadLagSeconds"`
+		Errors        map[string]string      `json:"errors,omitempty"`
+		Upstreams     []node.UpstreamStatus  `json:"upstreams,omitempty"`
+		Budget        *node.Budget           `json:"budget,omitempty"`
+		Coalesced     uint64                 `json:"coalesced"`
+		Cache         node.CacheStats        `json:"cache"`
+		HeadTracker   *node.HeadTrackerStats `json:"headTracker,omitempty"`
+	}{
+		ChainID:       st.ChainID,
+		NetworkID:     st.NetworkID,
+		ClientVersion: st.ClientVersion,
+		PeerCount:     st.PeerCount,
+		Sync:          st.Sync,
+		HeadNumber:    st.Head.Number.UInt64(),
+		HeadHash:      st.Head.Hash,
+		HeadTimestamp: st.Head.Timestamp.UInt64(),
+		HeadLag:       st.HeadLag.Seconds(),
+		MaxHeadLag:    maxAge.Seconds(),
+		Errors:        st.Errors,
+		Cache:         s.cache.Stats(),
+	}
+	if s.tracker != nil {
+		stats := s.tracker.Stats()
+		data.HeadTracker = &stats
+	}
+	if pool := s.client.Pool(); pool != nil {
+		data.Upstreams = pool.Status()
+		budget := pool.Budget()
+		data.Budget = &budget
+		data.Coalesced = pool.Coalesced()
+	}
+	data.Healthy = st.HeadLag <= maxAge && (st.Sync == nil || !st.Sync.Syncing)
+
+	status := http.StatusOK
+	if !data.Healthy {
+		status = http.StatusServiceUnavailable
+	}
+	s.respond(w, r, data, status)
+}
+
 // handleGetDescription describe all the API routes
 func (s *Server) handleGetDescription(rout *mux.Router) http.HandlerFunc {
 	s.Logger.Info("get API description")
@@ -327,11 +917,196 @@ func (s *Server) handleGetDescription(rout *mux.Router) http.HandlerFunc {
 
 }
 
+// blockParam reads the optional block and requireCanonical query parameters selecting the state to query
+func blockParam(r *http.Request) (node.BlockParam, error) {
+	q := r.URL.Query()
+	return node.ParseBlockParam(q.Get("block"), q.Get("requireCanonical") == "true")
+}
+
 func (s *Server) checkTypeError(w http.ResponseWriter, r *http.Request, value interface{}, err error) bool {
 	if err != nil {
 		s.Logger.Infof("can't get %v to type %T", value, value)
-		s.respond(w, r, err, http.StatusBadRequest)
+		s.respond(w, r, err.Error(), http.StatusBadRequest)
 		return false
 	}
 	return true
 }
+
+// handleGetAccount get a summary of the state of an address
+func (s *Server) handleGetAccount(w http.ResponseWriter, r *http.Request) {
+	s.Logger.Info("get account")
+	w.Header().Add("Content-Type", "application/json")
+	params := mux.Vars(r)
+	address := params["address"]
+	unit, err := parseUnit(r.URL.Query().Get("unit"))
+	if nok := !s.checkTypeError(w, r, unit, err); nok {
+		return
+	}
+	block, err := blockParam(r)
+	if nok := !s.checkTypeError(w, r, block, err); nok {
+		return
+	}
+
+	a, err := s.client.GetAccount(r.Context(), address, block)
+	if err != nil {
+		s.Logger.Warnf("can't get account:%s at block:%s error:%s", address, block, err)
+		s.respondNodeError(w, r, err)
+		return
+	}
+
+	code, err := hexutil.Decode(string(a.Code))
+	if err != nil {
+		s.Logger.Warnf("can't decode code of account:%s error:%s", address, err)
+		s.respondNodeError(w, r, err)
+		return
+	}
+	data := struct {
+		Address    string `json:"address"`
+		IsContract bool   `json:"isContract"`
+		CodeHash   string `json:"codeHash"`
+		Nonce      uint64 `json:"nonce"`
+		Balance    string `json:"balance"`
+		Unit       string `json:"unit"`
+	}{
+		Address:    eth.ToChecksumAddress(address),
+		IsContract: len(code) > 0,
+		CodeHash:   crypto.Keccak256Hash(code).Hex(),
+		Nonce:      a.Nonce,
+		Balance:    formatUnit(a.Balance, unit),
+		Unit:       unit,
+	}
+	s.respond(w, r, data, http.StatusOK)
+}
+
+// handleGetNonce get the number of transactions sent from an address
+func (s *Server) handleGetNonce(w http.ResponseWriter, r *http.Request) {
+	s.Logger.Info("get nonce")
+	w.Header().Add("Content-Type", "application/json")
+	params := mux.Vars(r)
+	address := params["address"]
+	block, err := blockParam(r)
+	if nok := !s.checkTypeError(w, r, block, err); nok {
+		return
+	}
+
+	n, err := s.client.GetTransactionCount(r.Context(), address, block)
+	if err != nil {
+		s.Logger.Warnf("can't get nonce for:%s at block:%s error:%s", address, block, err)
+		s.respondNodeError(w, r, err)
+	} else {
+		data := struct {
+			Nonce uint64 `json:"nonce"`
+		}{n}
+		s.respond(w, r, data, http.StatusOK)
+	}
+}
+
+// handleGetCode get the code deployed at an address
+func (s *Server) handleGetCode(w http.ResponseWriter, r *http.Request) {
+	s.Logger.Info("get code")
+	w.Header().Add("Content-Type", "application/json")
+	params := mux.Vars(r)
+	address := params["address"]
+	block, err := blockParam(r)
+	if nok := !s.checkTypeError(w, r, block, err); nok {
+		return
+	}
+
+	c, err := s.client.GetCode(r.Context(), address, block)
+	if err != nil {
+		s.Logger.Warnf("can't get code for:%s at block:%s error:%s", address, block, err)
+		s.respondNodeError(w, r, err)
+	} else {
+		data := struct {
+			Code eth.Data `json:"code"`
+		}{c}
+		s.respond(w, r, data, http.StatusOK)
+	}
+}
+
+// handleGetStorageAt get the value of a storage slot of an address
+func (s *Server) handleGetStorageAt(w http.ResponseWriter, r *http.Request) {
+	s.Logger.Info("get storage")
+	w.Header().Add("Content-Type", "application/json")
+	params := mux.Vars(r)
+	address := params["address"]
+	slot, err := parseSlot(params["slot"])
+	if nok := !s.checkTypeError(w, r, slot, err); nok {
+		return
+	}
+	block, err := blockParam(r)
+	if nok := !s.checkTypeError(w, r, block, err); nok {
+		return
+	}
+
+	v, err := s.client.GetStorageAt(r.Context(), address, slot, block)
+	if err != nil {
+		s.Logger.Warnf("can't get storage slot:%s for:%s at block:%s error:%s", slot, address, block, err)
+		s.respondNodeError(w, r, err)
+	} else {
+		data := struct {
+			Slot  *eth.Quantity `json:"slot"`
+			Value eth.Data      `json:"value"`
+		}{&slot, v}
+		s.respond(w, r, data, http.StatusOK)
+	}
+}
+
+// maxProofKeys limits the number of storage slots proven in a request
+const maxProofKeys = 100
+
+// handleGetProof get the merkle proof of an account and of the storage keys requested, verified against the state root of the block
+func (s *Server) handleGetProof(w http.ResponseWriter, r *http.Request) {
+	s.Logger.Info("get proof")
+	w.Header().Add("Content-Type", "application/json")
+	address := mux.Vars(r)["address"]
+	var keys []eth.Quantity
+	if v := r.URL.Query().Get("keys"); v != "" {
+		for _, k := range strings.Split(v, ",") {
+			slot, err := parseSlot(strings.TrimSpace(k))
+			if nok := !s.checkTypeError(w, r, slot, err); nok {
+				return
+			}
+			keys = append(keys, slot)
+		}
+	}
+	if len(keys) > maxProofKeys {
+		s.respond(w, r, fmt.Sprintf("at most %d keys can be proven at once", maxProofKeys), http.StatusBadRequest)
+		return
+	}
+	block, err := blockParam(r)
+	if nok := !s.checkTypeError(w, r, block, err); nok {
+		return
+	}
+
+	p, err := s.client.GetVerifiedProof(r.Context(), address, keys, block)
+	if err != nil {
+		s.Logger.Warnf("can't get proof for:%s at block:%s error:%s", address, block, err)
+		s.respondNodeError(w, r, err)
+		return
+	}
+	if !p.Verification.Valid {
+		s.Logger.Warnf("invalid proof for:%s at block:%d %+v", address, p.BlockNumber, p.Verification)
+	}
+	data := struct {
+		BlockNumber  uint64                 `json:"blockNumber"`
+		BlockHash    eth.Hash               `json:"blockHash"`
+		StateRoot    eth.Data32             `json:"stateRoot"`
+		Verification node.ProofVerification `json:"verification"`
+		Proof        *node.AccountProof     `json:"proof"`
+	}{p.BlockNumber, p.BlockHash, p.StateRoot, p.Verification, p.Proof}
+	s.respond(w, r, data, http.StatusOK)
+}
+
+// parseSlot parses a storage slot position given in decimal or in hex
+func parseSlot(slot string) (eth.Quantity, error) {
+	digits, base := slot, 10
+	if strings.HasPrefix(slot, "0x") {
+		digits, base = slot[2:], 16
+	}
+	n, ok := new(big.Int).SetString(digits, base)
+	if !ok || n.Sign() < 0 || n.BitLen() > 256 {
+		return eth.Quantity{}, fmt.Errorf("invalid storage slot %s", slot)
+	}
+	return eth.QuantityFromBigInt(n), nil
+}
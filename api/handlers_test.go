@@ -1,29 +1,54 @@
 package api
 
 import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/INFURA/go-ethlibs/eth"
 	"github.com/INFURA/infra-test-benjamin-mateo/config"
 	"github.com/INFURA/infra-test-benjamin-mateo/logger"
+	"github.com/INFURA/infra-test-benjamin-mateo/node"
+	"github.com/INFURA/infra-test-benjamin-mateo/node/nodetest"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/gorilla/mux"
 )
 
 var s *Server
 
-func init() {
+// fake is the node serving the fixture chain to s
+var fake *nodetest.Server
+
+func TestMain(m *testing.M) {
 
 	// load configuration
 	config.Load()
+	fake = nodetest.NewServer(nil)
 	// get an API server
 	s = NewServer(logger.Init(true), mux.NewRouter())
-	s.loadClient(config.ReadString("NODE_URL"))
-	defer s.Logger.Sync()
+	s.loadClient([]string{fake.URL})
+	code := m.Run()
+	s.Logger.Sync()
+	fake.Close()
+	os.Exit(code)
 }
 
 func TestHandlers(t *testing.T) {
+	block := fake.Chain.Block(9135250)
+	blockTx := block.Transactions[0].Transaction
+	tx := fake.Chain.Transaction(9135267, 1)
 
 	tt := []struct {
 		URL            string
@@ -36,19 +61,49 @@ func TestHandlers(t *testing.T) {
 		{"/block/last/height", "/block/last/height", s.handleGetLatestBlockID, `{"lastBlockHeight":`, http.StatusOK},
 		{"/block/last", "/block/last", s.handleGetLastBlock(false), `{"number":`, http.StatusOK},
 		{"/block/last/full", "/block/last/full", s.handleGetLastBlock(true), `"transactionIndex"`, http.StatusOK},
-		{"/block/{height:[0-9]+}", "/block/5681044", s.handleGetBlockByHeight(false), `number":"0x56af94","hash":"0xc9ad7040dee3e49e7e7ab396278b23a738bc00e11edf0ac49520a74f1692c9cc"`, http.StatusOK},
+		{"/block/{height:[0-9]+}", "/block/9135250", s.handleGetBlockByHeight(false), fmt.Sprintf(`number":"0x8b6492","hash":"%s"`, *block.Hash), http.StatusOK},
 		{"/block/{height:[0-9]+}", "/block/dssd", s.handleGetBlockByHeight(false), "", http.StatusNotFound},
-		{"/block/{height:[0-9]+}/full", "/block/5681044/full", s.handleGetBlockByHeight(true), `"transactions":[{"blockHash":"0xc9ad7040dee3e49e7e7ab396278b23a738bc00e11edf0ac49520a74f1692c9cc"`, http.StatusOK},
-		{"/block/{hash:0x(?:[A-Fa-f0-9]{64})$}", "/block/0x840eed2d9390ea527089a7c2f2b6dd28ef382c094105d29d22b911e606c4e0bc", s.handleGetBlockByHash(false), `"number":"0xefc87","hash":"0x840eed2d9390ea527089a7c2f2b6dd28ef382c094105d29d22b911e606c4e0bc"`, http.StatusOK},
-		{"/block/{hash:0x(?:[A-Fa-f0-9]{64})}/full", "/block/0x840eed2d9390ea527089a7c2f2b6dd28ef382c094105d29d22b911e606c4e0bc/full", s.handleGetBlockByHash(true), `"r":"0x87effd10150f3d09c5ffcb79111c654637ba7a3a84c3e733d51003a029660b86","s":"0x5d68bc045b0a2d35160497c9c94da08b4dc32b8e9fb04d917c7442f1ad903205"`, http.StatusOK},
-		{"/transaction/{hash:0x(?:[A-Fa-f0-9]{64})$}", "/transaction/0x37e458fcff2a79f32257776aa67f929187d2ff1f8868092bead0b788d248b9b4", s.handleGetTransactionByHash(), `{"blockHash":"0xf247cc1a2cc1b3af1094674bd191594eeeff1e89e5036b377731758055debd51","blockNumber":"0x75a900"`, http.StatusOK},
+		{"/block/{height:[0-9]+}/full", "/block/9135250/full", s.handleGetBlockByHeight(true), fmt.Sprintf(`"transactions":[{"blockHash":"%s"`, *block.Hash), http.StatusOK},
+		{"/block/{height:[0-9]+}", "/block/100", s.handleGetBlockByHeight(false), "block not found", http.StatusNotFound},
+		{"/block/{hash:0x(?:[A-Fa-f0-9]{64})$}", "/block/" + string(*block.Hash), s.handleGetBlockByHash(false), fmt.Sprintf(`"number":"0x8b6492","hash":"%s"`, *block.Hash), http.StatusOK},
+		{"/block/{hash:0x(?:[A-Fa-f0-9]{64})}/full", "/block/" + string(*block.Hash) + "/full", s.handleGetBlockByHash(true), fmt.Sprintf(`"r":"%s","s":"%s"`, blockTx.R.String(), blockTx.S.String()), http.StatusOK},
+		{"/transaction/{hash:0x(?:[A-Fa-f0-9]{64})$}", "/transaction/" + string(tx.Hash), s.handleGetTransactionByHash(), fmt.Sprintf(`{"blockHash":"%s","blockNumber":"0x8b64a3"`, *tx.BlockHash), http.StatusOK},
 		{"/transaction/{hash:0x(?:[A-Fa-f0-9]{64})$}", "/transaction/0xgge458fcff2a79f32257776aa67f929187d2ff1f8868092bead0b788d248b9b4", s.handleGetTransactionByHash(), "", http.StatusNotFound},
+		{"/transaction/{hash:0x(?:[A-Fa-f0-9]{64})}/receipt", "/transaction/" + string(tx.Hash) + "/receipt", s.handleGetTransactionReceipt, `"status":"`, http.StatusOK},
+		{"/transaction/{hash:0x(?:[A-Fa-f0-9]{64})}/trace", "/transaction/0x37e458fcff2a79f32257776aa67f929187d2ff1f8868092bead0b788d248b9b4/trace?tracer=vm", s.handleTraceTransaction, "unknown tracer", http.StatusBadRequest},
+		{"/transaction/{hash:0x(?:[A-Fa-f0-9]{64})}/trace", "/transaction/0x37e458fcff2a79f32257776aa67f929187d2ff1f8868092bead0b788d248b9b4/trace?tracer=structlog&limit=0", s.handleTraceTransaction, "limit must be between 1 and 1000", http.StatusBadRequest},
+		{"/transaction/{hash:0x(?:[A-Fa-f0-9]{64})}/trace", "/transaction/" + string(tx.Hash) + "/trace?tracer=call", s.handleTraceTransaction, `"type":"CALL"`, http.StatusOK},
 		{"/gasprice", "/gasprice", s.handleGetGasPrice, `{"gasPrice":`, http.StatusOK},
+		{"/node/status", "/node/status", s.handleGetNodeStatus, `"chainId":1`, http.StatusOK},
+		{"/gas/fees", "/gas/fees", s.handleGetFees, `"nextBaseFeePerGas":`, http.StatusOK},
+		{"/gas/fees", "/gas/fees?blocks=2000", s.handleGetFees, "blocks must be between 1 and 1024", http.StatusBadRequest},
 		{"/balance/{address:0x(?:[A-Fa-f0-9]{40})$}", "/balance/0x5cf2CBfd110E7Ce39fb353d123776Ab683ef9fEB", s.handleGetBalance, `{"balance":`, http.StatusOK},
+		{"/balance/{address:0x(?:[A-Fa-f0-9]{40})$}", "/balance/0x5cf2CBfd110E7Ce39fb353d123776Ab683ef9fEB?unit=ether", s.handleGetBalance, `"unit":"ether"`, http.StatusOK},
+		{"/balance/{address:0x(?:[A-Fa-f0-9]{40})$}", "/balance/0x5cf2CBfd110E7Ce39fb353d123776Ab683ef9fEB?unit=finney", s.handleGetBalance, "unknown unit", http.StatusBadRequest},
+		{"/balance/{address:0x(?:[A-Fa-f0-9]{40})$}", "/balance/0x5cf2CBfd110E7Ce39fb353d123776Ab683ef9fEB?block=9135250", s.handleGetBalance, `{"balance":`, http.StatusOK},
+		{"/balance/{address:0x(?:[A-Fa-f0-9]{40})$}", "/balance/0x5cf2CBfd110E7Ce39fb353d123776Ab683ef9fEB?block=head", s.handleGetBalance, "invalid block", http.StatusBadRequest},
+		{"/account/{address:0x(?:[A-Fa-f0-9]{40})}", "/account/0xe530441f4f73bDB6DC2fA5aF7c3fC5fD551Ec838", s.handleGetAccount, `"isContract":true`, http.StatusOK},
+		{"/account/{address:0x(?:[A-Fa-f0-9]{40})}/nonce", "/account/0x5cf2CBfd110E7Ce39fb353d123776Ab683ef9fEB/nonce", s.handleGetNonce, `{"nonce":`, http.StatusOK},
+		{"/account/{address:0x(?:[A-Fa-f0-9]{40})}/code", "/account/0xe530441f4f73bDB6DC2fA5aF7c3fC5fD551Ec838/code", s.handleGetCode, `{"code":"0x`, http.StatusOK},
+		{"/account/{address:0x(?:[A-Fa-f0-9]{40})}/storage/{slot}", "/account/0xe530441f4f73bDB6DC2fA5aF7c3fC5fD551Ec838/storage/0", s.handleGetStorageAt, `"slot":"0x0"`, http.StatusOK},
+		{"/account/{address:0x(?:[A-Fa-f0-9]{40})}/storage/{slot}", "/account/0xe530441f4f73bDB6DC2fA5aF7c3fC5fD551Ec838/storage/0xzz", s.handleGetStorageAt, "invalid storage slot", http.StatusBadRequest},
+		{"/account/{address:0x(?:[A-Fa-f0-9]{40})}/proof", "/account/0xe530441f4f73bDB6DC2fA5aF7c3fC5fD551Ec838/proof?keys=0,1", s.handleGetProof, `"valid":true`, http.StatusOK},
+		{"/account/{address:0x(?:[A-Fa-f0-9]{40})}/proof", "/account/0xe530441f4f73bDB6DC2fA5aF7c3fC5fD551Ec838/proof?keys=0&block=safe", s.handleGetProof, `"valid":true`, http.StatusOK},
+		{"/account/{address:0x(?:[A-Fa-f0-9]{40})}/proof", "/account/0xe530441f4f73bDB6DC2fA5aF7c3fC5fD551Ec838/proof?keys=0&block=finalized", s.handleGetProof, `"valid":true`, http.StatusOK},
+		{"/account/{address:0x(?:[A-Fa-f0-9]{40})}/proof", "/account/0xe530441f4f73bDB6DC2fA5aF7c3fC5fD551Ec838/proof?keys=0&block=pending", s.handleGetProof, "pending block", http.StatusBadRequest},
+		{"/account/{address:0x(?:[A-Fa-f0-9]{40})}/proof", "/account/0xe530441f4f73bDB6DC2fA5aF7c3fC5fD551Ec838/proof?keys=0,slot", s.handleGetProof, "invalid storage slot", http.StatusBadRequest},
+		{"/call/estimate", "/call/estimate", s.handleEstimateGas, "missing body", http.StatusBadRequest},
+		{"/stream/blocks", "/stream/blocks", s.handleStreamBlocks, "stream not available", http.StatusServiceUnavailable},
+		{"/stream/logs", "/stream/logs", s.handleStreamLogs, "stream not available", http.StatusServiceUnavailable},
 		{"/log/{from:0x(?:[A-Fa-f0-9]+)}/{to:0x(?:[A-Fa-f0-9]+)}/{topic}", "/log/9135250/9135260/0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef", s.handleGetLogs, "", http.StatusNotFound},
 		{"/log/{from:0x(?:[A-Fa-f0-9]+)}/{to:0x(?:[A-Fa-f0-9]+)}/{topic}", "/log/0x8B6492/0x8B649C/0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef", s.handleGetLogs, `topics":["0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"`, http.StatusOK},
-		{"/block/{height:[0-9]+}/transaction/{id:[0-9]+}", "/block/9135267/transaction/4", s.handleGetTransactionByIDInBlockHash, `{"blockHash":"0xafddcde383196ecacf07def76a2572c86d1f992081cb1023c8decef92b7712ee"`, http.StatusOK},
+		{"/block/{height:[0-9]+}/transaction/{id:[0-9]+}", "/block/9135267/transaction/1", s.handleGetTransactionByIDInBlockHash, fmt.Sprintf(`{"blockHash":"%s"`, *tx.BlockHash), http.StatusOK},
 		{"/block/{height:[0-9]+}/transaction/{id:[0-9]+}", "/block/9135267/transaction/99999999999999999999999999999999", s.handleGetTransactionByIDInBlockHash, "", http.StatusBadRequest},
+		{"/block/{hash:0x(?:[A-Fa-f0-9]{64})}/transaction/{id:[0-9]+}", "/block/" + string(*tx.BlockHash) + "/transaction/1", s.handleGetTransactionByIDInBlockHash, fmt.Sprintf(`"hash":"%s"`, tx.Hash), http.StatusOK},
+		{"/block/{hash:0x(?:[A-Fa-f0-9]{64})}/transaction/{id:[0-9]+}", "/block/0x00ddcde383196ecacf07def76a2572c86d1f992081cb1023c8decef92b7712ee/transaction/4", s.handleGetTransactionByIDInBlockHash, "transaction not found", http.StatusNotFound},
+		{"/block/{height:[0-9]+}/uncles", "/block/9135267/uncles", s.handleGetUncleCount, `{"uncleCount":`, http.StatusOK},
+		{"/block/{height:[0-9]+}/uncle/{index:[0-9]+}", "/block/9135267/uncle/99", s.handleGetUncle, "block not found", http.StatusNotFound},
+		{"/block/{hash:0x(?:[A-Fa-f0-9]{64})}/uncles", "/block/" + string(*tx.BlockHash) + "/uncles", s.handleGetUncleCount, `{"uncleCount":0`, http.StatusOK},
 	}
 
 	for _, tc := range tt {
@@ -79,3 +134,369 @@ func TestHandlers(t *testing.T) {
 
 	}
 }
+
+func TestStreamBlocks(t *testing.T) {
+	// the stream follows its own node to mine blocks without moving the chain of the other tests
+	ws := nodetest.NewServer(nil)
+	defer ws.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := NewServer(s.Logger, mux.NewRouter())
+	stream.loadClient([]string{ws.WSURL})
+	// the connection must be closed before the node
+	defer stream.client.Pool().Close()
+	stream.startHeadFeed(ctx)
+	// the stream goes through the middlewares and must outlive the write timeout
+	const writeTimeout = 500 * time.Millisecond
+	srv := httptest.NewUnstartedServer(stream.router)
+	srv.Config.WriteTimeout = writeTimeout
+	srv.Start()
+	defer srv.Close()
+
+	req, err := http.NewRequest("GET", srv.URL+"/stream/blocks", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("got content type %s", ct)
+	}
+
+	// the feed may subscribe after the first blocks, mine until one is streamed
+	go func() {
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				ws.Mine()
+			}
+		}
+	}()
+	lines := bufio.NewScanner(resp.Body)
+	timeout := time.AfterFunc(10*time.Second, cancel)
+	defer timeout.Stop()
+	for lines.Scan() {
+		if lines.Text() == "event: block" && time.Since(start) > 2*writeTimeout {
+			return
+		}
+	}
+	t.Fatalf("no block streamed after the write timeout: %v", lines.Err())
+}
+
+func TestNodeErrors(t *testing.T) {
+	// the errors are scripted on their own node to keep the chain of the other tests working
+	failing := nodetest.NewServer(nil)
+	defer failing.Close()
+	srv := NewServer(s.Logger, mux.NewRouter())
+	srv.loadClient([]string{failing.URL})
+	defer srv.client.Pool().Close()
+
+	balance := "/balance/0x5cf2CBfd110E7Ce39fb353d123776Ab683ef9fEB"
+	limited := *nodetest.ErrRateLimited
+	limited.RetryAfter = 2 * time.Second
+	tt := []struct {
+		name           string
+		method         string
+		err            error
+		URL            string
+		routeVariable  string
+		handler        func(w http.ResponseWriter, r *http.Request)
+		expectedRes    string
+		expectedStatus int
+	}{
+		{"invalid params", "eth_getBalance", nodetest.ErrInvalidParams, "/balance/{address}", balance, srv.handleGetBalance, `"code":-32602`, http.StatusBadRequest},
+		{"not found", "eth_getBlockByNumber", nodetest.ErrHeaderNotFound, "/block/{height:[0-9]+}", "/block/9135250", srv.handleGetBlockByHeight(false), `"error":"header not found"`, http.StatusNotFound},
+		{"reverted", "", nil, "/call/{from}/{to}/{gas}/{value}/{data}", "/call/0x5cf2cefd110e7ce39fb353d123776ab683ef9fee/0xe530441f4f73bDB6DC2fA5aF7c3fC5fD551Ec838/30400/0/0x12345678", srv.handleCall, `"reason":"insufficient balance"`, http.StatusUnprocessableEntity},
+		{"upstream down", "eth_gasPrice", nodetest.ErrUpstreamDown, "/gasprice", "/gasprice", srv.handleGetGasPrice, `"error":"upstream unavailable"`, http.StatusBadGateway},
+		{"method not found", "eth_getCode", nodetest.ErrMethodNotFound, "/account/{address}/code", "/account/0xe530441f4f73bDB6DC2fA5aF7c3fC5fD551Ec838/code", srv.handleGetCode, `"code":-32601`, http.StatusBadGateway},
+		{"timeout", "debug_traceTransaction", nodetest.ErrTimeout, "/transaction/{hash}/trace", "/transaction/" + string(failing.Chain.Transaction(9135267, 1).Hash) + "/trace?tracer=call", srv.handleTraceTransaction, "timeout", http.StatusGatewayTimeout},
+		{"transaction by height timeout", "eth_getTransactionByBlockNumberAndIndex", nodetest.ErrTimeout, "/block/{height:[0-9]+}/transaction/{id:[0-9]+}", "/block/9135267/transaction/1", srv.handleGetTransactionByIDInBlockHash, "timeout", http.StatusGatewayTimeout},
+		{"transaction by hash upstream down", "eth_getTransactionByBlockHashAndIndex", nodetest.ErrUpstreamDown, "/block/{hash:0x(?:[A-Fa-f0-9]{64})}/transaction/{id:[0-9]+}", "/block/" + string(*failing.Chain.Transaction(9135267, 1).BlockHash) + "/transaction/1", srv.handleGetTransactionByIDInBlockHash, `"error":"upstream unavailable"`, http.StatusBadGateway},
+		{"call gas price timeout", "eth_gasPrice", nodetest.ErrTimeout, "/call/{from}/{to}/{gas}/{value}/{data}", "/call/0x5cf2cefd110e7ce39fb353d123776ab683ef9fee/0xe530441f4f73bDB6DC2fA5aF7c3fC5fD551Ec838/30400/0/0x70a08231", srv.handleCall, "timeout", http.StatusGatewayTimeout},
+		// the upstream is paused after a rate limit, it comes last
+		{"rate limited", "eth_getBalance", &limited, "/balance/{address}", balance, srv.handleGetBalance, `"code":-32005`, http.StatusTooManyRequests},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.err != nil {
+				failing.Fail(tc.method, tc.err)
+			}
+			req, err := http.NewRequest("GET", tc.routeVariable, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			router := mux.NewRouter()
+			router.HandleFunc(tc.URL, tc.handler)
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedStatus {
+				t.Errorf("got status %v want %v body:%s", rr.Code, tc.expectedStatus, rr.Body.String())
+			}
+			if !strings.Contains(rr.Body.String(), tc.expectedRes) {
+				t.Errorf("returned unexpected body: got %v want %v", rr.Body.String(), tc.expectedRes)
+			}
+			if retry := rr.Header().Get("Retry-After"); tc.expectedStatus == http.StatusTooManyRequests && retry != "2" {
+				t.Errorf("got Retry-After %q want 2", retry)
+			}
+		})
+	}
+}
+
+func TestSendRawTransactionErrors(t *testing.T) {
+	failing := nodetest.NewServer(nil)
+	defer failing.Close()
+	srv := NewServer(s.Logger, mux.NewRouter())
+	srv.loadClient([]string{failing.URL})
+	defer srv.client.Pool().Close()
+
+	key, err := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := types.SignTx(types.NewTransaction(3, common.HexToAddress("0xe530441f4f73bDB6DC2fA5aF7c3fC5fD551Ec838"), big.NewInt(10), 21000, big.NewInt(1e9), nil), types.NewEIP155Signer(big.NewInt(1)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"raw":"` + hexutil.Encode(raw) + `"}`
+
+	limited := *nodetest.ErrRateLimited
+	limited.RetryAfter = 2 * time.Second
+	tt := []struct {
+		name           string
+		err            error
+		expectedRes    string
+		expectedStatus int
+	}{
+		{"nonce too low", &nodetest.Error{Code: -32000, Message: "nonce too low"}, "nonce too low", http.StatusConflict},
+		{"underpriced", &nodetest.Error{Code: -32000, Message: "transaction underpriced"}, "transaction underpriced", http.StatusUnprocessableEntity},
+		{"upstream down", nodetest.ErrUpstreamDown, `"error":"upstream unavailable"`, http.StatusBadGateway},
+		// the upstream is paused after a rate limit, it comes last
+		{"rate limited", &limited, `"code":-32005`, http.StatusTooManyRequests},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			failing.Fail("eth_sendRawTransaction", tc.err)
+			rr := httptest.NewRecorder()
+			srv.handleSendRawTransaction(rr, httptest.NewRequest("POST", "/transaction", strings.NewReader(body)))
+			if rr.Code != tc.expectedStatus {
+				t.Errorf("got status %v want %v body:%s", rr.Code, tc.expectedStatus, rr.Body.String())
+			}
+			if !strings.Contains(rr.Body.String(), tc.expectedRes) {
+				t.Errorf("returned unexpected body: got %v want %v", rr.Body.String(), tc.expectedRes)
+			}
+			if retry := rr.Header().Get("Retry-After"); tc.expectedStatus == http.StatusTooManyRequests && retry != "2" {
+				t.Errorf("got Retry-After %q want 2", retry)
+			}
+		})
+	}
+}
+
+func TestBlockCache(t *testing.T) {
+	// the cache starts empty on its own server
+	cached := nodetest.NewServer(nil)
+	defer cached.Close()
+	srv := NewServer(s.Logger, mux.NewRouter())
+	srv.loadClient([]string{cached.URL})
+	defer srv.client.Pool().Close()
+
+	hash := string(*cached.Chain.Block(9135255).Hash)
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+		router.HandleFunc("/block/{hash:0x(?:[A-Fa-f0-9]{64})}/full", srv.handleGetBlockByHash(true))
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/block/"+hash+"/full", nil))
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), hash) {
+			t.Fatalf("got %d %s", rr.Code, rr.Body.String())
+		}
+	}
+	if n := cached.Calls("eth_getBlockByHash"); n != 1 {
+		t.Errorf("got %d requests to the node want 1", n)
+	}
+	if n := srv.cache.Stats().Hits; n != 2 {
+		t.Errorf("got %d cache hits want 2", n)
+	}
+}
+
+func TestStructLogPages(t *testing.T) {
+	// the trace cache starts empty on its own server
+	traced := nodetest.NewServer(nil)
+	defer traced.Close()
+	srv := NewServer(s.Logger, mux.NewRouter())
+	srv.loadClient([]string{traced.URL})
+	defer srv.client.Pool().Close()
+
+	hash := string(traced.Chain.Transaction(9135267, 2).Hash)
+	for _, offset := range []string{"0", "2"} {
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+		router.HandleFunc("/transaction/{hash:0x(?:[A-Fa-f0-9]{64})}/trace", srv.handleTraceTransaction)
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/transaction/"+hash+"/trace?tracer=structlog&limit=2&offset="+offset, nil))
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"offset":`+offset) {
+			t.Fatalf("offset %s: got %d %s", offset, rr.Code, rr.Body.String())
+		}
+	}
+	if n := traced.Calls("debug_traceTransaction"); n != 1 {
+		t.Errorf("got %d traces of the transaction want 1", n)
+	}
+}
+
+func TestCacheHeaders(t *testing.T) {
+	block := fake.Chain.Block(9135250)
+	hash := string(*block.Hash)
+	get := func(url string, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/block/"+hash, "")
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"`+hash+`"` || !strings.Contains(rr.Header().Get("Cache-Control"), "immutable") {
+		t.Fatalf("block by hash: got %d %v", rr.Code, rr.Header())
+	}
+	rr = get("/block/"+hash, `"other", `+rr.Header().Get("ETag"))
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("revalidated block: got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = get("/gasprice", "")
+	if rr.Code != http.StatusOK || rr.Header().Get("Cache-Control") != "public, max-age=1" || rr.Header().Get("ETag") == "" {
+		t.Fatalf("gas price: got %d %v", rr.Code, rr.Header())
+	}
+	if rr = get("/gasprice", rr.Header().Get("ETag")); rr.Code != http.StatusNotModified {
+		t.Errorf("revalidated gas price: got %d", rr.Code)
+	}
+	if rr = get("/gasprice?unit=gwei", `"stale"`); rr.Code != http.StatusOK {
+		t.Errorf("stale gas price: got %d", rr.Code)
+	}
+
+	for _, url := range []string{"/block/100", "/balance/0x5cf2CBfd110E7Ce39fb353d123776Ab683ef9fEB"} {
+		rr = get(url, "*")
+		if rr.Header().Get("Cache-Control") != noStore || rr.Header().Get("ETag") != "" || rr.Code == http.StatusNotModified {
+			t.Errorf("%s should not be stored: got %d %v", url, rr.Code, rr.Header())
+		}
+	}
+}
+
+func TestHeadTracker(t *testing.T) {
+	// the tracker follows its own node to mine blocks without moving the chain of the other tests
+	ws := nodetest.NewServer(nil)
+	defer ws.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := NewServer(s.Logger, mux.NewRouter())
+	srv.loadClient([]string{ws.WSURL})
+	defer srv.client.Pool().Close()
+	srv.startHeadFeed(ctx)
+
+	// the feed may subscribe after the first blocks, mine until one is tracked
+	var head *eth.Block
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		mined := ws.Mine()
+		time.Sleep(50 * time.Millisecond)
+		if b, ok := srv.tracker.Head(false); ok && *b.Hash == *mined.Hash {
+			head = b
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no block tracked: %+v", srv.tracker.Stats())
+		}
+	}
+
+	get := func(url string) string {
+		rr := httptest.NewRecorder()
+		srv.router.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: got %d %s", url, rr.Code, rr.Body.String())
+		}
+		return rr.Body.String()
+	}
+	calls := ws.Calls("eth_blockNumber") + ws.Calls("eth_getBlockByNumber")
+	if body := get("/block/last/height"); !strings.Contains(body, fmt.Sprintf(`"lastBlockHeight":%d`, head.Number.UInt64())) {
+		t.Errorf("got height %s want %d", body, head.Number.UInt64())
+	}
+	if body := get("/block/last"); !strings.Contains(body, string(*head.Hash)) {
+		t.Errorf("got block %s want %s", body, *head.Hash)
+	}
+	if n := ws.Calls("eth_blockNumber") + ws.Calls("eth_getBlockByNumber") - calls; n != 0 {
+		t.Errorf("got %d requests to the node want 0", n)
+	}
+	// full blocks are not kept by default, they are requested to the node
+	if body := get("/block/last/full"); !strings.Contains(body, `"transactionIndex"`) {
+		t.Errorf("got block %s", body)
+	}
+	if !strings.Contains(get("/node/status"), `"headTracker":{"head":`) {
+		t.Error("head tracker missing from the status")
+	}
+}
+
+func TestStore(t *testing.T) {
+	// the node of the test goes down, it must not break the chain of the other tests
+	down := nodetest.NewServer(nil)
+	defer down.Close()
+	srv := NewServer(s.Logger, mux.NewRouter())
+	srv.loadClient([]string{down.URL})
+	defer srv.client.Pool().Close()
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	srv.openStore(dir)
+	defer srv.store.Close()
+
+	block := down.Chain.Block(9135250)
+	tx := block.Transactions[1].Transaction
+	receipts := make([]*node.Receipt, len(block.Transactions))
+	for i, btx := range block.Transactions {
+		receipts[i] = &node.Receipt{TransactionReceipt: *down.Chain.Receipt(string(btx.Hash))}
+	}
+	if err := srv.store.PutBlock(block, receipts); err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range []string{"eth_getBlockByNumber", "eth_getBlockByHash", "eth_getTransactionByHash", "eth_getTransactionReceipt"} {
+		down.Fail(method, nodetest.ErrUpstreamDown)
+	}
+
+	tt := []struct {
+		url  string
+		want string
+	}{
+		{"/block/9135250/full", fmt.Sprintf(`"hash":"%s"`, tx.Hash)},
+		{"/block/" + string(*block.Hash), fmt.Sprintf(`"transactions":["%s"`, block.Transactions[0].Hash)},
+		{"/transaction/" + string(tx.Hash), fmt.Sprintf(`"blockHash":"%s"`, *block.Hash)},
+		{"/transaction/" + string(tx.Hash) + "/receipt", `"status":"`},
+	}
+	for _, tc := range tt {
+		rr := httptest.NewRecorder()
+		srv.router.ServeHTTP(rr, httptest.NewRequest("GET", tc.url, nil))
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), tc.want) {
+			t.Errorf("%s: got %d %s want %s", tc.url, rr.Code, rr.Body.String(), tc.want)
+		}
+	}
+
+	// the blocks not indexed are requested to the node
+	rr := httptest.NewRecorder()
+	srv.router.ServeHTTP(rr, httptest.NewRequest("GET", "/block/9135251", nil))
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("got %d want %d", rr.Code, http.StatusBadGateway)
+	}
+}
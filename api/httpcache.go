@@ -0,0 +1,156 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// noStore is the Cache-Control of the responses that depend on the state of the chain
+const noStore = "no-store, no-cache, must-revalidate, post-check=0, pre-check=0"
+
+// Cache durations of the responses
+const (
+	// finalMaxAge is the max-age of the data that can't change anymore like final blocks
+	finalMaxAge = 365 * 24 * time.Hour
+	// recentMaxAge is the max-age of the blocks and transactions that can still be reorganized
+	recentMaxAge = 5 * time.Second
+	// headMaxAge is the max-age of the data that changes with every block like the head or the gas price
+	headMaxAge = time.Second
+)
+
+// cacheHeaders is a middleware setting the cache headers of the responses.
+// Handlers set the Cache-Control of the data they return with setMaxAge, the others are not stored.
+// Cacheable responses get a strong ETag, the hash of their body unless the handler set one,
+// and requests with a matching If-None-Match get a 304.
+// Responses are buffered to be hashed, a handler streaming with Flush is passed through.
+func cacheHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := &cacheWriter{ResponseWriter: w}
+		next.ServeHTTP(cw, r)
+		cw.finish(r)
+	})
+}
+
+// setMaxAge allows the response to be cached for d, immutable is set when the data can't change anymore
+func setMaxAge(w http.ResponseWriter, d time.Duration, immutable bool) {
+	v := fmt.Sprintf("public, max-age=%d", int(d.Seconds()))
+	if immutable {
+		v += ", immutable"
+	}
+	w.Header().Set("Cache-Control", v)
+}
+
+// setBlockMaxAge sets the max-age of data of a mined block, it is long-lived once the block is final
+func (s *Server) setBlockMaxAge(w http.ResponseWriter, number uint64) {
+	if s.cache.IsFinal(number) {
+		setMaxAge(w, finalMaxAge, true)
+	} else {
+		setMaxAge(w, recentMaxAge, false)
+	}
+}
+
+// setETag sets the strong ETag of the response
+func setETag(w http.ResponseWriter, tag string) {
+	w.Header().Set("ETag", `"`+tag+`"`)
+}
+
+// cacheWriter buffers a response until the handler is done
+type cacheWriter struct {
+	http.ResponseWriter
+	status    int
+	buf       bytes.Buffer
+	streaming bool
+}
+
+func (w *cacheWriter) WriteHeader(status int) {
+	if w.streaming {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.buf.Write(b)
+}
+
+// Flush sends the response buffered so far and streams the rest
+func (w *cacheWriter) Flush() {
+	if !w.streaming {
+		w.streaming = true
+		if w.Header().Get("Cache-Control") == "" {
+			w.Header().Set("Cache-Control", noStore)
+		}
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.ResponseWriter.WriteHeader(w.status)
+		w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the ResponseWriter of the server so http.ResponseController reaches it
+func (w *cacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish sets the cache headers and writes the buffered response
+func (w *cacheWriter) finish(r *http.Request) {
+	if w.streaming {
+		return
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	h := w.Header()
+	cacheControl := h.Get("Cache-Control")
+	switch {
+	case w.status != http.StatusOK || cacheControl == "":
+		// errors are never cached, they may be transient
+		h.Set("Cache-Control", noStore)
+		h.Del("ETag")
+	case !strings.Contains(cacheControl, "no-store"):
+		if h.Get("ETag") == "" {
+			sum := sha256.Sum256(w.buf.Bytes())
+			setETag(w, hex.EncodeToString(sum[:16]))
+		}
+		if (r.Method == http.MethodGet || r.Method == http.MethodHead) && etagMatch(r.Header.Get("If-None-Match"), h.Get("ETag")) {
+			h.Del("Content-Type")
+			w.ResponseWriter.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(w.buf.Bytes())
+}
+
+// etagMatch tells if the ETag is in the If-None-Match header, with the weak comparison of RFC 7232
+func etagMatch(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
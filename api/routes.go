@@ -62,10 +62,162 @@ func (s *Server) routes() {
 	//     description: transaction is returned
 	//     schema:
 	//       $ref: '#/definitions/Transaction'
+	//   "304":
+	//     description: transaction is unchanged since the ETag sent in If-None-Match
 	//   "404":
 	//     description: transaction not found
 	t.HandleFunc("/{hash:0x(?:[A-Fa-f0-9]{64})$}", s.handleGetTransactionByHash()).Methods("GET")
 
+	// swagger:operation POST /transaction transaction handleSendRawTransaction
+	//
+	// Broadcasts a signed transaction
+	//
+	// The transaction is decoded and validated before being sent to the node: chain ID, EIP-155 replay protection, signature and intrinsic gas.
+	// Legacy, EIP-2930 and EIP-1559 transactions are supported.
+	//
+	// ---
+	// consumes:
+	// - application/json
+	// parameters:
+	// - name: transaction
+	//   in: body
+	//   description: the signed transaction RLP encoded in hex
+	//   required: true
+	//   schema:
+	//     type: object
+	//     properties:
+	//       raw:
+	//         type: string
+	// responses:
+	//   "202":
+	//     description: transaction is broadcast, its hash, sender and nonce are returned
+	//     schema:
+	//      type: object
+	//      properties:
+	//        hash:
+	//          type: string
+	//        from:
+	//          type: string
+	//        nonce:
+	//          type: number
+	//   "400":
+	//     description: transaction can't be decoded or is invalid
+	//   "409":
+	//     description: nonce too low, transaction already known or replacement transaction underpriced
+	//   "422":
+	//     description: transaction rejected by the node e.g. underpriced
+	s.router.HandleFunc("/transaction", s.handleSendRawTransaction).Methods("POST")
+
+	// swagger:operation GET /transaction/{hash}/receipt transaction handleGetTransactionReceipt
+	//
+	// Returns the receipt of a transaction for a given hash
+	//
+	// If the transaction is mined, its status, gas used, effective gas price, created contract address, logs and total fee will be returned
+	// else Error Not Found (404) will be returned, as long as the transaction is pending.
+	//
+	// ---
+	// parameters:
+	// - name: hash
+	//   in: path
+	//   description: a string representing the hash (32 bytes) of a transaction
+	//   type: string
+	//   required: true
+	// - name: unit
+	//   in: query
+	//   description: the unit of the effective gas price and fee, one of wei, gwei or ether
+	//   type: string
+	//   required: false
+	// responses:
+	//   "200":
+	//     description: receipt is returned
+	//     schema:
+	//      type: object
+	//      properties:
+	//        transactionHash:
+	//          type: string
+	//        blockHash:
+	//          type: string
+	//        blockNumber:
+	//          type: number
+	//        from:
+	//          type: string
+	//        to:
+	//          type: string
+	//        status:
+	//          type: string
+	//          enum: [success, failed, unknown]
+	//        gasUsed:
+	//          type: string
+	//        effectiveGasPrice:
+	//          type: string
+	//        fee:
+	//          type: string
+	//        unit:
+	//          type: string
+	//        contractAddress:
+	//          type: string
+	//        logs:
+	//          type: array
+	//          items:
+	//            $ref: '#/definitions/Log'
+	//   "404":
+	//     description: transaction not found or pending
+	t.HandleFunc("/{hash:0x(?:[A-Fa-f0-9]{64})}/receipt", s.handleGetTransactionReceipt).Methods("GET")
+
+	// swagger:operation GET /transaction/{hash}/trace transaction handleTraceTransaction
+	//
+	// Replays a transaction with debug_traceTransaction
+	//
+	// The call tracer returns the call tree, each frame has its gas, gas used, whether it reverted and its decoded revert reason.
+	// The prestate tracer returns the accounts touched by the transaction, in diff mode with their state after the execution.
+	// The structlog tracer returns the opcodes executed, a page at a time.
+	// The node must expose the debug namespace.
+	//
+	// ---
+	// parameters:
+	// - name: hash
+	//   in: path
+	//   description: a string representing the hash (32 bytes) of a transaction
+	//   type: string
+	//   required: true
+	// - name: tracer
+	//   in: query
+	//   description: call (default), prestate or structlog
+	//   type: string
+	//   required: false
+	// - name: diff
+	//   in: query
+	//   description: prestate only, return the pre and post state of the modified accounts
+	//   type: boolean
+	//   required: false
+	// - name: offset
+	//   in: query
+	//   description: structlog only, index of the first opcode returned
+	//   type: integer
+	//   required: false
+	// - name: limit
+	//   in: query
+	//   description: structlog only, number of opcodes returned, 100 by default, 1000 at most
+	//   type: integer
+	//   required: false
+	// - name: memory
+	//   in: query
+	//   description: structlog only, include the memory
+	//   type: boolean
+	//   required: false
+	// responses:
+	//   "200":
+	//     description: the output of the tracer
+	//   "400":
+	//     description: unknown tracer or invalid page
+	//   "404":
+	//     description: transaction not found
+	//   "502":
+	//     description: the node could not trace the transaction
+	//   "504":
+	//     description: the trace timed out on the node
+	t.HandleFunc("/{hash:0x(?:[A-Fa-f0-9]{64})}/trace", s.handleTraceTransaction).Methods("GET")
+
 	b := s.router.PathPrefix("/block").Subrouter()
 
 	// swagger:operation GET /block/last block handleGetLastBlock
@@ -126,6 +278,8 @@ func (s *Server) routes() {
 	//     description: block is returned
 	//     schema:
 	//       $ref: '#/definitions/Block'
+	//   "304":
+	//     description: block is unchanged since the ETag sent in If-None-Match
 	//   "404":
 	//     description: block not found
 	b.HandleFunc("/{hash:0x(?:[A-Fa-f0-9]{64})$}", s.handleGetBlockByHash(false)).Methods("GET")
@@ -218,26 +372,226 @@ func (s *Server) routes() {
 	//     description: transaction not found
 	b.HandleFunc("/{height:[0-9]+}/transaction/{id:[0-9]+}", s.handleGetTransactionByIDInBlockHash).Methods("GET")
 
+	// swagger:operation GET /block/{hash}/transaction/{id} block handleGetTransactionByIDInBlockHash
+	//
+	// Returns information about a transaction by block hash and transaction index position.
+	//
+	// The lookup is pinned to the block so a reorg can't make it return a transaction of another block.
+	// If the transaction is found, transaction will be returned
+	// else Error Not Found (404) will be returned.
+	//
+	// ---
+	// parameters:
+	// - name: hash
+	//   in: path
+	//   description: a string representing the hash (32 bytes) of a block
+	//   type: string
+	//   required: true
+	// - name: id
+	//   in: path
+	//   description: an integer representing the position in the block
+	//   type: number
+	//   required: true
+	// responses:
+	//   "200":
+	//     description: transaction is returned
+	//     schema:
+	//       $ref: '#/definitions/Transaction'
+	//   "404":
+	//     description: transaction not found
+	b.HandleFunc("/{hash:0x(?:[A-Fa-f0-9]{64})}/transaction/{id:[0-9]+}", s.handleGetTransactionByIDInBlockHash).Methods("GET")
+
+	// swagger:operation GET /block/{height}/uncle/{index} block handleGetUncle
+	//
+	// Returns the header of an uncle by block number and uncle index position.
+	//
+	// Uncles are returned without transactions.
+	// If the uncle is found, the uncle will be returned
+	// else Error Not Found (404) will be returned.
+	//
+	// ---
+	// parameters:
+	// - name: height
+	//   in: path
+	//   description: an integer block number
+	//   type: number
+	//   required: true
+	// - name: index
+	//   in: path
+	//   description: an integer representing the position in the uncles of the block
+	//   type: number
+	//   required: true
+	// responses:
+	//   "200":
+	//     description: uncle is returned
+	//     schema:
+	//       $ref: '#/definitions/Block'
+	//   "404":
+	//     description: uncle not found
+	b.HandleFunc("/{height:[0-9]+}/uncle/{index:[0-9]+}", s.handleGetUncle).Methods("GET")
+
+	// swagger:operation GET /block/{height}/uncles block handleGetUncleCount
+	//
+	// Returns the number of uncles of a block by block number.
+	//
+	// ---
+	// parameters:
+	// - name: height
+	//   in: path
+	//   description: an integer block number
+	//   type: number
+	//   required: true
+	// responses:
+	//   "200":
+	//     description: the number of uncles is returned
+	//     schema:
+	//      type: object
+	//      properties:
+	//        uncleCount:
+	//          type: integer
+	//      example:
+	//        uncleCount: 1
+	//   "404":
+	//     description: block not found
+	b.HandleFunc("/{height:[0-9]+}/uncles", s.handleGetUncleCount).Methods("GET")
+
+	// swagger:operation GET /block/{hash}/uncle/{index} block handleGetUncle
+	//
+	// Returns the header of an uncle by block hash and uncle index position.
+	//
+	// Uncles are returned without transactions.
+	// If the uncle is found, the uncle will be returned
+	// else Error Not Found (404) will be returned.
+	//
+	// ---
+	// parameters:
+	// - name: hash
+	//   in: path
+	//   description: a string representing the hash (32 bytes) of a block
+	//   type: string
+	//   required: true
+	// - name: index
+	//   in: path
+	//   description: an integer representing the position in the uncles of the block
+	//   type: number
+	//   required: true
+	// responses:
+	//   "200":
+	//     description: uncle is returned
+	//     schema:
+	//       $ref: '#/definitions/Block'
+	//   "404":
+	//     description: uncle not found
+	b.HandleFunc("/{hash:0x(?:[A-Fa-f0-9]{64})}/uncle/{index:[0-9]+}", s.handleGetUncle).Methods("GET")
+
+	// swagger:operation GET /block/{hash}/uncles block handleGetUncleCount
+	//
+	// Returns the number of uncles of a block by block hash.
+	//
+	// ---
+	// parameters:
+	// - name: hash
+	//   in: path
+	//   description: a string representing the hash (32 bytes) of a block
+	//   type: string
+	//   required: true
+	// responses:
+	//   "200":
+	//     description: the number of uncles is returned
+	//     schema:
+	//      type: object
+	//      properties:
+	//        uncleCount:
+	//          type: integer
+	//      example:
+	//        uncleCount: 1
+	//   "404":
+	//     description: block not found
+	b.HandleFunc("/{hash:0x(?:[A-Fa-f0-9]{64})}/uncles", s.handleGetUncleCount).Methods("GET")
+
 	// swagger:operation GET /gasprice gas handleGetGasPrice
 	//
-	// Returns the current gas price in wei
+	// Returns the current gas price as a decimal string, in wei unless another unit is requested
 	//
 	// ---
+	// parameters:
+	// - name: unit
+	//   in: query
+	//   description: the unit of the gas price, one of wei, gwei or ether
+	//   type: string
+	//   required: false
 	// responses:
 	//   "200":
-	//     description: gasPrice is returned in wei
+	//     description: gasPrice is returned in the requested unit
 	//     schema:
 	//      type: object
 	//      properties:
 	//        gasPrice:
-	//          type: number
+	//          type: string
+	//        unit:
+	//          type: string
 	//      example:
-	//         gasPrice: 4000000000
+	//         gasPrice: "4000000000"
+	//         unit: wei
+	//   "400":
+	//     description: unknown unit
 	s.router.HandleFunc("/gasprice", s.handleGetGasPrice).Methods("GET")
 
+	// swagger:operation GET /gas/fees gas handleGetFees
+	//
+	// Returns EIP-1559 fee suggestions as decimal strings, in wei unless another unit is requested
+	//
+	// The fees are computed from eth_feeHistory over the recent blocks.
+	// The priority fee of slow, standard and fast is the median of the 10th, 50th and 90th reward percentiles of the blocks,
+	// the max fee adds it to the next base fee raised to absorb 1, 3 and 6 full blocks.
+	//
+	// ---
+	// parameters:
+	// - name: unit
+	//   in: query
+	//   description: the unit of the fees, one of wei, gwei or ether
+	//   type: string
+	//   required: false
+	// - name: blocks
+	//   in: query
+	//   description: the number of recent blocks, 1 to 1024, FEE_HISTORY_BLOCKS by default
+	//   type: integer
+	//   required: false
+	// responses:
+	//   "200":
+	//     description: the base fees and the suggestions are returned in the requested unit
+	//     schema:
+	//      type: object
+	//      properties:
+	//        baseFeePerGas:
+	//          type: string
+	//        nextBaseFeePerGas:
+	//          type: string
+	//        slow:
+	//          type: object
+	//          properties:
+	//            maxFeePerGas:
+	//              type: string
+	//            maxPriorityFeePerGas:
+	//              type: string
+	//        standard:
+	//          type: object
+	//        fast:
+	//          type: object
+	//        oldestBlock:
+	//          type: integer
+	//        blocks:
+	//          type: integer
+	//        unit:
+	//          type: string
+	//   "400":
+	//     description: unknown unit or invalid number of blocks
+	s.router.HandleFunc("/gas/fees", s.handleGetFees).Methods("GET")
+
 	// swagger:operation GET /balance/{address} balance handleGetBalance
 	//
-	// Returns balance in wei of the given address.
+	// Returns balance of the given address as a decimal string, in wei unless another unit is requested.
+	// The balance is read from the latest state unless a past block is requested.
 	//
 	// If the address is found, balance will be returned
 	// else Error Not Found (404) will be returned.
@@ -249,6 +603,21 @@ func (s *Server) routes() {
 	//   description: a string representing the address (20 bytes) to check for balance
 	//   type: number
 	//   required: true
+	// - name: unit
+	//   in: query
+	//   description: the unit of the balance, one of wei, gwei or ether
+	//   type: string
+	//   required: false
+	// - name: block
+	//   in: query
+	//   description: the state to query, a block number in decimal or hex, a block hash, or one of "latest" (default), "earliest", "pending", "safe", "finalized"
+	//   type: string
+	//   required: false
+	// - name: requireCanonical
+	//   in: query
+	//   description: when the block is a hash, fails if the block is not in the canonical chain (EIP-1898)
+	//   type: boolean
+	//   required: false
 	// responses:
 	//   "200":
 	//     description: balance is returned
@@ -256,13 +625,238 @@ func (s *Server) routes() {
 	//      type: object
 	//      properties:
 	//        balance:
-	//          type: number
+	//          type: string
+	//        unit:
+	//          type: string
 	//      example:
-	//       balance: 2381188418352874359
+	//       balance: "2.381188418352874359"
+	//       unit: ether
+	//   "400":
+	//     description: unknown unit
 	//   "404":
 	//     description: address not found
 	s.router.HandleFunc("/balance/{address:0x(?:[A-Fa-f0-9]{40})$}", s.handleGetBalance).Methods("GET")
 
+	a := s.router.PathPrefix("/account").Subrouter()
+
+	// swagger:operation GET /account/{address} account handleGetAccount
+	//
+	// Returns a summary of the state of the given address: whether it is a contract, its code hash, nonce and balance.
+	//
+	// ---
+	// parameters:
+	// - name: address
+	//   in: path
+	//   description: a string representing the address (20 bytes)
+	//   type: string
+	//   required: true
+	// - name: unit
+	//   in: query
+	//   description: the unit of the balance, one of wei, gwei or ether
+	//   type: string
+	//   required: false
+	// - name: block
+	//   in: query
+	//   description: the state to query, a block number in decimal or hex, a block hash, or one of "latest" (default), "earliest", "pending", "safe", "finalized"
+	//   type: string
+	//   required: false
+	// - name: requireCanonical
+	//   in: query
+	//   description: when the block is a hash, fails if the block is not in the canonical chain (EIP-1898)
+	//   type: boolean
+	//   required: false
+	// responses:
+	//   "200":
+	//     description: account is returned
+	//     schema:
+	//      type: object
+	//      properties:
+	//        address:
+	//          type: string
+	//        isContract:
+	//          type: boolean
+	//        codeHash:
+	//          type: string
+	//        nonce:
+	//          type: number
+	//        balance:
+	//          type: string
+	//        unit:
+	//          type: string
+	//      example:
+	//       address: "0x5cf2CBfd110E7Ce39fb353d123776Ab683ef9fEB"
+	//       isContract: false
+	//       codeHash: "0xc5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"
+	//       nonce: 12
+	//       balance: "2381188418352874359"
+	//       unit: wei
+	//   "400":
+	//     description: unknown unit or invalid block
+	a.HandleFunc("/{address:0x(?:[A-Fa-f0-9]{40})}", s.handleGetAccount).Methods("GET")
+
+	// swagger:operation GET /account/{address}/nonce account handleGetNonce
+	//
+	// Returns the number of transactions sent from the given address.
+	//
+	// ---
+	// parameters:
+	// - name: address
+	//   in: path
+	//   description: a string representing the address (20 bytes)
+	//   type: string
+	//   required: true
+	// - name: block
+	//   in: query
+	//   description: the state to query, a block number in decimal or hex, a block hash, or one of "latest" (default), "earliest", "pending", "safe", "finalized"
+	//   type: string
+	//   required: false
+	// - name: requireCanonical
+	//   in: query
+	//   description: when the block is a hash, fails if the block is not in the canonical chain (EIP-1898)
+	//   type: boolean
+	//   required: false
+	// responses:
+	//   "200":
+	//     description: nonce is returned
+	//     schema:
+	//      type: object
+	//      properties:
+	//        nonce:
+	//          type: number
+	//      example:
+	//       nonce: 12
+	//   "400":
+	//     description: invalid block
+	a.HandleFunc("/{address:0x(?:[A-Fa-f0-9]{40})}/nonce", s.handleGetNonce).Methods("GET")
+
+	// swagger:operation GET /account/{address}/code account handleGetCode
+	//
+	// Returns the code deployed at the given address, "0x" for an externally owned account.
+	//
+	// ---
+	// parameters:
+	// - name: address
+	//   in: path
+	//   description: a string representing the address (20 bytes)
+	//   type: string
+	//   required: true
+	// - name: block
+	//   in: query
+	//   description: the state to query, a block number in decimal or hex, a block hash, or one of "latest" (default), "earliest", "pending", "safe", "finalized"
+	//   type: string
+	//   required: false
+	// - name: requireCanonical
+	//   in: query
+	//   description: when the block is a hash, fails if the block is not in the canonical chain (EIP-1898)
+	//   type: boolean
+	//   required: false
+	// responses:
+	//   "200":
+	//     description: code is returned
+	//     schema:
+	//      type: object
+	//      properties:
+	//        code:
+	//          type: string
+	//   "400":
+	//     description: invalid block
+	a.HandleFunc("/{address:0x(?:[A-Fa-f0-9]{40})}/code", s.handleGetCode).Methods("GET")
+
+	// swagger:operation GET /account/{address}/storage/{slot} account handleGetStorageAt
+	//
+	// Returns the value of a storage slot of the given address.
+	//
+	// ---
+	// parameters:
+	// - name: address
+	//   in: path
+	//   description: a string representing the address (20 bytes)
+	//   type: string
+	//   required: true
+	// - name: slot
+	//   in: path
+	//   description: the position of the storage slot in decimal or hex
+	//   type: string
+	//   required: true
+	// - name: block
+	//   in: query
+	//   description: the state to query, a block number in decimal or hex, a block hash, or one of "latest" (default), "earliest", "pending", "safe", "finalized"
+	//   type: string
+	//   required: false
+	// - name: requireCanonical
+	//   in: query
+	//   description: when the block is a hash, fails if the block is not in the canonical chain (EIP-1898)
+	//   type: boolean
+	//   required: false
+	// responses:
+	//   "200":
+	//     description: value of the slot is returned
+	//     schema:
+	//      type: object
+	//      properties:
+	//        slot:
+	//          type: string
+	//        value:
+	//          type: string
+	//   "400":
+	//     description: invalid slot or block
+	a.HandleFunc("/{address:0x(?:[A-Fa-f0-9]{40})}/storage/{slot}", s.handleGetStorageAt).Methods("GET")
+
+	// swagger:operation GET /account/{address}/proof account handleGetProof
+	//
+	// Returns the merkle proof of an account and of some of its storage slots with the result of its verification.
+	//
+	// The proof is verified by the API against the state root of the block so that the balance, nonce, code hash and storage values don't have to be trusted.
+	// The state of the latest block is proven unless another block is requested.
+	//
+	// ---
+	// parameters:
+	// - name: address
+	//   in: path
+	//   description: a string representing the address (20 bytes)
+	//   type: string
+	//   required: true
+	// - name: keys
+	//   in: query
+	//   description: comma separated storage slots in decimal or hex, 100 at most
+	//   type: string
+	//   required: false
+	// - name: block
+	//   in: query
+	//   description: the state to prove, a block number in decimal or hex, a block hash, or one of "latest" (default), "earliest", "safe", "finalized", the pending block is not mined and can't be proven
+	//   type: string
+	//   required: false
+	// responses:
+	//   "200":
+	//     description: the proof and its verification are returned, verification.valid is false if the node returned a value the proof doesn't match
+	//     schema:
+	//      type: object
+	//      properties:
+	//        blockNumber:
+	//          type: integer
+	//        blockHash:
+	//          type: string
+	//        stateRoot:
+	//          type: string
+	//        verification:
+	//          type: object
+	//          properties:
+	//            valid:
+	//              type: boolean
+	//            accountError:
+	//              type: string
+	//            storageError:
+	//              type: string
+	//            storage:
+	//              type: array
+	//        proof:
+	//          type: object
+	//   "400":
+	//     description: invalid storage slot or block
+	//   "404":
+	//     description: block not found
+	a.HandleFunc("/{address:0x(?:[A-Fa-f0-9]{40})}/proof", s.handleGetProof).Methods("GET")
+
 	// swagger:operation GET /log/{from}/{to}/{topic} log handleGetLogs
 	//
 	// Returns an array of all logs matching a given filter object.
@@ -302,6 +896,7 @@ func (s *Server) routes() {
 	//
 	// Executes a new message call immediately without creating a transaction on the block chain.
 	//
+	// The call is executed against the latest state unless a past block is requested.
 	//
 	// ---
 	// parameters:
@@ -330,15 +925,284 @@ func (s *Server) routes() {
 	//   description:  Hash of the method signature and encoded parameters. For details see Ethereum Contract ABI
 	//   type: string
 	//   required: true
+	// - name: block
+	//   in: query
+	//   description: the state to query, a block number in decimal or hex, a block hash, or one of "latest" (default), "earliest", "pending", "safe", "finalized"
+	//   type: string
+	//   required: false
+	// - name: requireCanonical
+	//   in: query
+	//   description: when the block is a hash, fails if the block is not in the canonical chain (EIP-1898)
+	//   type: boolean
+	//   required: false
 	// responses:
 	//   "200":
 	//     description:  the return value of the executed contract method.
 	//     schema:
 	//       type: string
+	//   "400":
+	//     description: invalid parameters
 	//   "404":
-	//     description: logs not found
+	//     description: call failed
 	s.router.HandleFunc("/call/{from:0x(?:[A-Fa-f0-9]{40})}/{to:0x(?:[A-Fa-f0-9]{40})}/{gas:[0-9]+}/{value:[0-9]+}/{data}", s.handleCall).Methods("GET")
 
+	// swagger:operation POST /call/estimate call handleEstimateGas
+	//
+	// Estimates the gas needed by a call.
+	//
+	// If the execution reverts the decoded revert reason is returned with an Unprocessable Entity (422) error.
+	//
+	// ---
+	// consumes:
+	// - application/json
+	// parameters:
+	// - name: call
+	//   in: body
+	//   description: the call, all the fields are hex encoded as in the JSON-RPC api and only to is required except for a contract creation
+	//   required: true
+	//   schema:
+	//     type: object
+	//     properties:
+	//       from:
+	//         type: string
+	//       to:
+	//         type: string
+	//       gas:
+	//         type: string
+	//       gasPrice:
+	//         type: string
+	//       value:
+	//         type: string
+	//       data:
+	//         type: string
+	//     example:
+	//       from: "0x5cf2cefd110e7ce39fb353d123776ab683ef9fee"
+	//       to: "0xe530441f4f73bDB6DC2fA5aF7c3fC5fD551Ec838"
+	//       data: "0x70a082310000000000000000000000005cf2cbfd110e7ce39fb353d123776ab683ef9feb"
+	// - name: block
+	//   in: query
+	//   description: the state to query, a block number in decimal or hex, a block hash, or one of "latest" (default), "earliest", "pending", "safe", "finalized"
+	//   type: string
+	//   required: false
+	// - name: requireCanonical
+	//   in: query
+	//   description: when the block is a hash, fails if the block is not in the canonical chain (EIP-1898)
+	//   type: boolean
+	//   required: false
+	// responses:
+	//   "200":
+	//     description: the gas estimate
+	//     schema:
+	//      type: object
+	//      properties:
+	//        gas:
+	//          type: number
+	//      example:
+	//         gas: 30400
+	//   "400":
+	//     description: invalid call or block
+	//   "422":
+	//     description: the execution reverted, the decoded revert reason is returned
+	//     schema:
+	//      type: object
+	//      properties:
+	//        error:
+	//          type: string
+	//        reason:
+	//          type: string
+	//        data:
+	//          type: string
+	s.router.HandleFunc("/call/estimate", s.handleEstimateGas).Methods("POST")
+
+	// swagger:operation POST /call/accesslist call handleCreateAccessList
+	//
+	// Creates the EIP-2930 access list of a call, with the gas used by the call when the access list is applied.
+	//
+	// If the execution reverts the decoded revert reason is returned with an Unprocessable Entity (422) error.
+	//
+	// ---
+	// consumes:
+	// - application/json
+	// parameters:
+	// - name: call
+	//   in: body
+	//   description: the call, all the fields are hex encoded as in the JSON-RPC api and only to is required except for a contract creation
+	//   required: true
+	//   schema:
+	//     type: object
+	//     properties:
+	//       from:
+	//         type: string
+	//       to:
+	//         type: string
+	//       gas:
+	//         type: string
+	//       gasPrice:
+	//         type: string
+	//       value:
+	//         type: string
+	//       data:
+	//         type: string
+	//     example:
+	//       from: "0x5cf2cefd110e7ce39fb353d123776ab683ef9fee"
+	//       to: "0xe530441f4f73bDB6DC2fA5aF7c3fC5fD551Ec838"
+	//       data: "0x70a082310000000000000000000000005cf2cbfd110e7ce39fb353d123776ab683ef9feb"
+	// - name: block
+	//   in: query
+	//   description: the state to query, a block number in decimal or hex, a block hash, or one of "latest" (default), "earliest", "pending", "safe", "finalized"
+	//   type: string
+	//   required: false
+	// - name: requireCanonical
+	//   in: query
+	//   description: when the block is a hash, fails if the block is not in the canonical chain (EIP-1898)
+	//   type: boolean
+	//   required: false
+	// responses:
+	//   "200":
+	//     description: the access list
+	//     schema:
+	//      type: object
+	//      properties:
+	//        accessList:
+	//          type: array
+	//          items:
+	//            type: object
+	//            properties:
+	//              address:
+	//                type: string
+	//              storageKeys:
+	//                type: array
+	//                items:
+	//                  type: string
+	//        gasUsed:
+	//          type: number
+	//   "400":
+	//     description: invalid call or block
+	//   "422":
+	//     description: the execution reverted, the decoded revert reason is returned
+	//     schema:
+	//      type: object
+	//      properties:
+	//        error:
+	//          type: string
+	//        reason:
+	//          type: string
+	//        data:
+	//          type: string
+	s.router.HandleFunc("/call/accesslist", s.handleCreateAccessList).Methods("POST")
+
+	// swagger:operation GET /stream/blocks stream handleStreamBlocks
+	//
+	// Streams the new heads as server-sent events
+	//
+	// A block event is sent for each new canonical head, its data is the block header.
+	// When the chain reorganizes a reorg event lists the removed blocks and the common ancestor, it is followed by the block events of the new canonical chain.
+	//
+	// ---
+	// produces:
+	// - text/event-stream
+	// parameters:
+	// - name: Last-Event-ID
+	//   in: header
+	//   description: ID of the last event received, the missed events are sent first
+	//   type: integer
+	//   required: false
+	// responses:
+	//   "200":
+	//     description: event stream
+	//   "400":
+	//     description: invalid Last-Event-ID
+	//   "503":
+	//     description: the stream is not available
+	s.router.HandleFunc("/stream/blocks", s.handleStreamBlocks).Methods("GET")
+
+	// swagger:operation GET /stream/logs stream handleStreamLogs
+	//
+	// Streams the logs matching a filter as server-sent events
+	//
+	// A log event is sent for each matching log of the new canonical blocks.
+	// When a reorg removes a block its logs are sent again with removed set to true.
+	// The ID of an event is "block:logIndex", or the block number once a reorg rolled back to it.
+	// A checkpoint event with the block number as ID is sent once a block or a range of blocks is scanned.
+	//
+	// ---
+	// produces:
+	// - text/event-stream
+	// parameters:
+	// - name: filter
+	//   in: query
+	//   description: JSON filter with the shape of eth_getLogs e.g. {"address":"0x...","topics":[["0x..."],null,"0x..."]}, fromBlock resumes from a block, toBlock and blockHash are rejected
+	//   type: string
+	//   required: false
+	// - name: Last-Event-ID
+	//   in: header
+	//   description: ID of the last event received, the missed logs are sent first
+	//   type: string
+	//   required: false
+	// responses:
+	//   "200":
+	//     description: event stream
+	//   "400":
+	//     description: invalid filter or Last-Event-ID
+	//   "503":
+	//     description: the stream is not available
+	s.router.HandleFunc("/stream/logs", s.handleStreamLogs).Methods("GET")
+
+	// swagger:operation GET /node/status node handleGetNodeStatus
+	//
+	// Returns the chain ID, network ID, client version, peer count, sync progress and head of the node
+	//
+	// The head lag is the time elapsed since the head was mined.
+	// The node is unhealthy when it is syncing or its head lag exceeds NODE_MAX_HEAD_AGE seconds.
+	// Methods disabled by the provider are reported in errors.
+	//
+	// ---
+	// responses:
+	//   "200":
+	//     description: the node is healthy
+	//     schema:
+	//      type: object
+	//      properties:
+	//        healthy:
+	//          type: boolean
+	//        chainId:
+	//          type: integer
+	//        networkId:
+	//          type: string
+	//        clientVersion:
+	//          type: string
+	//        peerCount:
+	//          type: integer
+	//        sync:
+	//          type: object
+	//        headNumber:
+	//          type: integer
+	//        headHash:
+	//          type: string
+	//        headTimestamp:
+	//          type: integer
+	//        headLagSeconds:
+	//          type: number
+	//        maxHeadLagSeconds:
+	//          type: number
+	//        errors:
+	//          type: object
+	//        upstreams:
+	//          type: array
+	//        budget:
+	//          type: object
+	//        coalesced:
+	//          type: integer
+	//        cache:
+	//          type: object
+	//        headTracker:
+	//          type: object
+	//   "503":
+	//     description: the node is syncing or its head is stuck, the status is returned
+	//   "502":
+	//     description: the node could not be reached
+	s.router.HandleFunc("/node/status", s.handleGetNodeStatus).Methods("GET")
+
 	// swagger:operation GET /describe describe handleGetDescription
 	//
 	// Returns information about the available api routes.
@@ -1,12 +1,19 @@
 package api
 
 import (
+	"context"
+	"fmt"
+	"math/big"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/INFURA/go-ethlibs/eth"
 	"github.com/INFURA/infra-test-benjamin-mateo/config"
 	"github.com/INFURA/infra-test-benjamin-mateo/node"
 	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
@@ -17,6 +24,20 @@ type Server struct {
 	Logger *zap.SugaredLogger
 	//Client instantiate client one
 	client node.CustomClient
+	// heads follows the chain for the streams, nil until Serve starts it
+	heads *node.HeadFeed
+	// cache serves the blocks and transactions already requested
+	cache *node.BlockCache
+	// traces serves the pages of the struct log traces already requested
+	traces *node.TraceCache
+	// tracker serves the latest blocks, nil until Serve starts it
+	tracker *node.HeadTracker
+	// store serves the final blocks and transactions indexed on disk, nil if STORE_PATH is not set
+	store *node.Store
+
+	// chainID is loaded from the node on first use
+	chainIDMu sync.Mutex
+	chainID   *big.Int
 }
 
 // NewServer bind handlers functions and set router, eth client and logger
@@ -26,16 +47,22 @@ func NewServer(logger *zap.SugaredLogger, router *mux.Router) *Server {
 	s.Logger = logger
 	// set the router
 	s.router = router
-	// enforce no cache
-	s.router.Use(noCacheHeader)
+	s.cache = node.NewBlockCache(node.CacheConfig{
+		Size:     config.ReadInt("CACHE_SIZE"),
+		Finality: uint64(config.ReadInt("REORG_DEPTH")),
+	})
+	s.traces = node.NewTraceCache(config.ReadInt("TRACE_CACHE_SIZE"))
+	// cache headers derived from the data returned
+	s.router.Use(cacheHeaders)
 	s.routes()
 	return s
 }
 
-// loadClient load an ethereum client that targets the url provided.
-func (s *Server) loadClient(target string) {
-	s.Logger.Infof("Connecting to: %s", target)
-	client, err := node.GetNewCustomClient(target)
+// loadClient load an ethereum client that routes requests to a pool of nodes targeted by the urls provided.
+// Unreachable nodes do not prevent the client to load, they are kept out of rotation until they are healthy.
+func (s *Server) loadClient(targets []string) {
+	pool := s.newPool(targets)
+	client, err := node.GetNewPooledClient(pool)
 	if err != nil {
 		s.Logger.Fatal("Client error: ", err)
 	}
@@ -43,20 +70,252 @@ func (s *Server) loadClient(target string) {
 	s.client = client
 }
 
-// noCacheHeader is a middleware function, to enforce no caching which will be called for each request
-func noCacheHeader(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, post-check=0, pre-check=0")
-		next.ServeHTTP(w, r)
-	})
+// loadCassetteClient load an ethereum client that records the traffic of the nodes to the cassette file
+// or replays it without any node
+func (s *Server) loadCassetteClient(mode node.CassetteMode, path string, targets []string) {
+	s.Logger.Infof("Cassette %s in %s mode", path, mode)
+	var cassette *node.Cassette
+	var err error
+	if mode == node.RecordMode {
+		cassette, err = node.NewCassette(path, mode, s.newPool(targets))
+	} else {
+		cassette, err = node.NewCassette(path, mode, nil)
+	}
+	if err != nil {
+		s.Logger.Fatal("Cassette error: ", err)
+	}
+	client, err := node.GetNewCassetteClient(cassette)
+	if err != nil {
+		s.Logger.Fatal("Client error: ", err)
+	}
+	s.client = client
+}
+
+func (s *Server) newPool(targets []string) *node.Pool {
+	s.Logger.Infof("Connecting to %d nodes", len(targets))
+	pool, err := node.NewPool(node.PoolConfig{
+		URLs:           targets,
+		HealthInterval: time.Duration(config.ReadInt("NODE_HEALTH_INTERVAL")) * time.Second,
+		MaxLag:         uint64(config.ReadInt("NODE_MAX_LAG")),
+		RateLimit: node.RateLimit{
+			PerSecond: float64(config.ReadInt("NODE_RATE_LIMIT")),
+			Burst:     config.ReadInt("NODE_RATE_BURST"),
+			Daily:     config.ReadInt("NODE_DAILY_LIMIT"),
+		},
+		MaxWait: time.Duration(config.ReadInt("NODE_RATE_MAX_WAIT_MS")) * time.Millisecond,
+	}, s.Logger)
+	if err != nil {
+		s.Logger.Fatal("Pool error: ", err)
+	}
+	for _, st := range pool.Status() {
+		s.Logger.Infof("node healthy:%v height:%d", st.Healthy, st.Height)
+	}
+	return pool
+}
+
+// startHeadFeed follows the new heads of the chain in the background
+func (s *Server) startHeadFeed(ctx context.Context) {
+	s.heads = node.NewHeadFeed(&s.client, node.HeadFeedConfig{
+		PollInterval: time.Duration(config.ReadInt("STREAM_POLL_INTERVAL")) * time.Second,
+		History:      config.ReadInt("STREAM_HISTORY"),
+		Depth:        config.ReadInt("REORG_DEPTH"),
+	}, s.Logger)
+	s.tracker = node.NewHeadTracker(&s.client, node.HeadTrackerConfig{
+		Size: config.ReadInt("HEAD_TRACKER_SIZE"),
+		Full: config.ReadBool("HEAD_TRACKER_FULL"),
+	}, s.Logger)
+	go s.heads.Run(ctx)
+	go s.cache.Follow(ctx, s.heads)
+	go s.tracker.Follow(ctx, s.heads)
+}
+
+// getChainID returns the chain ID of the node, it never changes so it is only requested once
+func (s *Server) getChainID(ctx context.Context) (*big.Int, error) {
+	s.chainIDMu.Lock()
+	defer s.chainIDMu.Unlock()
+	if s.chainID == nil {
+		id, err := s.client.ChainID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.chainID = id
+	}
+	return s.chainID, nil
+}
+
+// blockByNumber returns the canonical block at a height from the head tracker, the cache, the store or the node
+func (s *Server) blockByNumber(ctx context.Context, number uint64, full bool) (*eth.Block, error) {
+	if s.tracker != nil {
+		if b, ok := s.tracker.BlockByNumber(number, full); ok {
+			return b, nil
+		}
+	}
+	if b, ok := s.cache.BlockByNumber(number, full); ok {
+		return b, nil
+	}
+	if s.store != nil {
+		if b, ok := s.store.BlockByNumber(number, full); ok {
+			return b, nil
+		}
+	}
+	epoch := s.cache.Epoch()
+	b, err := s.client.BlockByNumber(ctx, number, full)
+	if err == nil {
+		s.cache.AddBlock(b, full, true, epoch)
+	}
+	return b, err
+}
+
+// lastBlock returns the head kept by the tracker, ok is false when the latest block must be requested to the node
+func (s *Server) lastBlock(full bool) (*eth.Block, bool) {
+	if s.tracker == nil {
+		return nil, false
+	}
+	return s.tracker.Head(full)
+}
+
+// blockByHash returns a block from the cache, the store or the node
+func (s *Server) blockByHash(ctx context.Context, hash string, full bool) (*eth.Block, error) {
+	if b, ok := s.cache.BlockByHash(hash, full); ok {
+		return b, nil
+	}
+	if s.store != nil {
+		if b, ok := s.store.BlockByHash(hash, full); ok {
+			return b, nil
+		}
+	}
+	epoch := s.cache.Epoch()
+	b, err := s.client.BlockByHash(ctx, hash, full)
+	if err == nil {
+		s.cache.AddBlock(b, full, false, epoch)
+	}
+	return b, err
+}
+
+// transactionByHash returns a transaction from the cache, the store or the node, pending transactions are not cached
+func (s *Server) transactionByHash(ctx context.Context, hash string) (*eth.Transaction, error) {
+	if tx, ok := s.cache.TransactionByHash(hash); ok {
+		return tx, nil
+	}
+	if s.store != nil {
+		if tx, ok := s.store.TransactionByHash(hash); ok {
+			return tx, nil
+		}
+	}
+	epoch := s.cache.Epoch()
+	tx, err := s.client.TransactionByHash(ctx, hash)
+	if err == nil {
+		s.cache.AddTransaction(tx, epoch)
+	}
+	return tx, err
+}
+
+// structLogTrace returns the struct log trace of a mined transaction from the cache or the node.
+// Traces are cached with the hash of the block of the transaction, a transaction reorganized into another block is traced again.
+func (s *Server) structLogTrace(ctx context.Context, hash string, cfg node.TraceConfig) (*node.StructLogTrace, error) {
+	tx, err := s.transactionByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if tx.BlockHash == nil {
+		return s.client.TraceStructLogs(ctx, hash, cfg)
+	}
+	key := fmt.Sprintf("%s:%s:%v", strings.ToLower(hash), strings.ToLower(string(*tx.BlockHash)), cfg.EnableMemory)
+	if trace, ok := s.traces.Get(key); ok {
+		return trace, nil
+	}
+	trace, err := s.client.TraceStructLogs(ctx, hash, cfg)
+	if err == nil {
+		s.traces.Add(key, trace)
+	}
+	return trace, err
+}
+
+// transactionReceipt returns a receipt from the store or the node
+func (s *Server) transactionReceipt(ctx context.Context, hash string) (*node.Receipt, error) {
+	if s.store != nil {
+		if r, ok := s.store.TransactionReceipt(hash); ok {
+			return r, nil
+		}
+	}
+	return s.client.GetTransactionReceipt(ctx, hash)
+}
+
+// Index backfills the store from the height to the other one then returns, or follows the head if to is 0.
+// The store can only be opened by one process, Index runs without the api.
+func (s *Server) Index(ctx context.Context, from uint64, to uint64) error {
+	defer s.Logger.Sync()
+	path := config.ReadString("STORE_PATH")
+	if path == "" {
+		return errors.New("STORE_PATH is not set")
+	}
+	s.loadNodeClient()
+	s.openStore(path)
+	defer s.store.Close()
+	return s.newIndexer(from, to).Run(ctx)
+}
+
+// loadNodeClient loads the client configured, a cassette or the pool of NODE_URLS
+func (s *Server) loadNodeClient() {
+	if mode := config.ReadString("NODE_CASSETTE_MODE"); mode != "" {
+		var targets []string
+		if node.CassetteMode(mode) == node.RecordMode {
+			targets = s.nodeURLs()
+		}
+		s.loadCassetteClient(node.CassetteMode(mode), config.ReadString("NODE_CASSETTE"), targets)
+	} else {
+		s.loadClient(s.nodeURLs())
+	}
+}
+
+// nodeURLs returns the urls of the nodes, the single NODE_URL of the former configurations is still read
+func (s *Server) nodeURLs() []string {
+	switch {
+	case config.IsSet("NODE_URLS"):
+		return config.ReadStringSlice("NODE_URLS")
+	case config.IsSet("NODE_URL"):
+		s.Logger.Warn("NODE_URL is deprecated, list the nodes in NODE_URLS")
+		return []string{config.ReadString("NODE_URL")}
+	}
+	s.Logger.Fatal("Config error: NODE_URLS is not set, it lists the urls of the nodes")
+	return nil
+}
+
+// openStore opens the store of the indexed blocks
+func (s *Server) openStore(path string) {
+	store, err := node.OpenStore(path)
+	if err != nil {
+		s.Logger.Fatal("Store error: ", err)
+	}
+	s.store = store
+}
+
+// newIndexer creates an indexer of the final blocks into the store
+func (s *Server) newIndexer(from uint64, to uint64) *node.Indexer {
+	return node.NewIndexer(&s.client, s.store, node.IndexerConfig{
+		From:          from,
+		To:            to,
+		Confirmations: uint64(config.ReadInt("REORG_DEPTH")),
+		PollInterval:  time.Duration(config.ReadInt("STREAM_POLL_INTERVAL")) * time.Second,
+	}, s.Logger)
 }
 
 // Serve the api at servingURL URL
 func (s *Server) Serve(servingURL string) {
 	defer s.Logger.Sync()
 
-	// load the ethereum client
-	s.loadClient(config.ReadString("NODE_URL"))
+	s.loadNodeClient()
+	if path := config.ReadString("STORE_PATH"); path != "" {
+		s.openStore(path)
+		if config.ReadBool("INDEXER_ENABLED") {
+			go func() {
+				if err := s.newIndexer(uint64(config.ReadInt("INDEXER_FROM")), 0).Run(context.Background()); err != nil {
+					s.Logger.Error("indexer stopped: ", err)
+				}
+			}()
+		}
+	}
+	s.startHeadFeed(context.Background())
 
 	// configure the api server
 	srv := &http.Server{
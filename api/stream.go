@@ -0,0 +1,239 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/INFURA/infra-test-benjamin-mateo/node"
+)
+
+// keepAliveInterval is the delay between two comments sent on an idle stream so that proxies keep it open
+const keepAliveInterval = 15 * time.Second
+
+// sseWriter writes server-sent events
+type sseWriter struct {
+	w http.ResponseWriter
+	f http.Flusher
+}
+
+// newSSEWriter sends the headers of an event stream.
+// A stream outlives the server write timeout so the deadline is removed.
+func newSSEWriter(w http.ResponseWriter) (*sseWriter, error) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("streaming unsupported")
+	}
+	// the server would close the stream at its write timeout otherwise
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		return nil, fmt.Errorf("stream deadline unsupported: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	// ask the clients to reconnect quickly
+	fmt.Fprint(w, "retry: 2000\n\n")
+	f.Flush()
+	return &sseWriter{w: w, f: f}, nil
+}
+
+// event writes a single event, data is JSON encoded on one line.
+// An event without id doesn't change the ID a client resumes from.
+func (e *sseWriter) event(id string, name string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(e.w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", name, b); err != nil {
+		return err
+	}
+	e.f.Flush()
+	return nil
+}
+
+// keepAlive writes a comment ignored by the clients
+func (e *sseWriter) keepAlive() error {
+	if _, err := fmt.Fprint(e.w, ": keep-alive\n\n"); err != nil {
+		return err
+	}
+	e.f.Flush()
+	return nil
+}
+
+// lastEventID reads the ID sent by a reconnecting EventSource
+func lastEventID(r *http.Request) (resume bool, id uint64, err error) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		return false, 0, nil
+	}
+	id, err = strconv.ParseUint(v, 10, 64)
+	return err == nil, id, err
+}
+
+// handleStreamBlocks pushes the new heads and the reorgs as server-sent events
+func (s *Server) handleStreamBlocks(w http.ResponseWriter, r *http.Request) {
+	s.Logger.Info("stream blocks")
+	if s.heads == nil {
+		s.respond(w, r, "stream not available", http.StatusServiceUnavailable)
+		return
+	}
+	resume, lastID, err := lastEventID(r)
+	if err != nil {
+		s.respond(w, r, "invalid Last-Event-ID", http.StatusBadRequest)
+		return
+	}
+
+	replay, events, cancel := s.heads.Subscribe(resume, lastID)
+	defer cancel()
+	sse, err := newSSEWriter(w)
+	if err != nil {
+		s.respond(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	send := func(ev node.HeadEvent) error {
+		if ev.Type == node.ReorgEvent {
+			return sse.event(strconv.FormatUint(ev.ID, 10), ev.Type, ev.Reorg)
+		}
+		return sse.event(strconv.FormatUint(ev.ID, 10), ev.Type, ev.Head)
+	}
+	for _, ev := range replay {
+		if err := send(ev); err != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				// too slow, the client reconnects with the last ID it received
+				s.Logger.Warn("block stream subscriber dropped")
+				return
+			}
+			if err := send(ev); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := sse.keepAlive(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// logFilter reads the filter of a log stream, it has the shape of eth_getLogs filter.
+// Only the logs of new blocks are streamed unless fromBlock is set.
+func logFilter(r *http.Request) (eth.LogFilter, *node.LogCursor, error) {
+	filter := eth.LogFilter{}
+	if v := r.URL.Query().Get("filter"); v != "" {
+		if err := json.Unmarshal([]byte(v), &filter); err != nil {
+			return filter, nil, fmt.Errorf("invalid filter: %s", err)
+		}
+	}
+	if filter.ToBlock != nil || filter.BlockHash != nil {
+		return filter, nil, fmt.Errorf("invalid filter: toBlock and blockHash can't be streamed")
+	}
+
+	var from *node.LogCursor
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		c, err := node.ParseLogCursor(v)
+		if err != nil {
+			return filter, nil, fmt.Errorf("invalid Last-Event-ID: %s", err)
+		}
+		from = &c
+	} else if filter.FromBlock != nil {
+		if q, ok := filter.FromBlock.Quantity(); ok {
+			// the logs of fromBlock are included, the genesis block has none
+			c := node.LogCursor{}
+			if n := q.UInt64(); n > 0 {
+				c.Block = n - 1
+			}
+			from = &c
+		}
+	}
+	filter.FromBlock = nil
+	return filter, from, nil
+}
+
+// handleStreamLogs pushes the logs matching a filter as server-sent events as the blocks arrive
+func (s *Server) handleStreamLogs(w http.ResponseWriter, r *http.Request) {
+	s.Logger.Info("stream logs")
+	if s.heads == nil {
+		s.respond(w, r, "stream not available", http.StatusServiceUnavailable)
+		return
+	}
+	filter, from, err := logFilter(r)
+	if err != nil {
+		s.respond(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sse, err := newSSEWriter(w)
+	if err != nil {
+		s.respond(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// logs are sent as the blocks arrive, the keep-alive comments are sent in between
+	ctx, cancel := context.WithCancel(r.Context())
+	var mu sync.Mutex
+	done := make(chan struct{})
+	defer func() {
+		// the response can't be written once the handler returned
+		cancel()
+		<-done
+	}()
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(keepAliveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				mu.Lock()
+				err := sse.keepAlive()
+				mu.Unlock()
+				if err != nil {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	err = s.heads.FollowLogs(ctx, filter, from, func(ev node.LogEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if ev.Checkpoint {
+			return sse.event(ev.ID.String(), "checkpoint", struct {
+				Block uint64 `json:"block"`
+			}{ev.ID.Block})
+		}
+		return sse.event(ev.ID.String(), "log", ev.Log)
+	})
+	if err != nil && ctx.Err() == nil {
+		s.Logger.Warnf("log stream ended err:%s", err)
+		mu.Lock()
+		defer mu.Unlock()
+		sse.event("", "error", err.Error())
+	}
+}
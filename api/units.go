@@ -0,0 +1,47 @@
+package api
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// units maps the supported ether denominations to their number of decimals
+var units = map[string]int{
+	"wei":   0,
+	"gwei":  9,
+	"ether": 18,
+}
+
+// parseUnit validates the requested unit, wei is the default
+func parseUnit(unit string) (string, error) {
+	if unit == "" {
+		return "wei", nil
+	}
+	unit = strings.ToLower(unit)
+	if _, ok := units[unit]; !ok {
+		return "", fmt.Errorf("unknown unit %s, should be one of wei, gwei or ether", unit)
+	}
+	return unit, nil
+}
+
+// formatUnit converts an amount of wei to a decimal string in the given unit without losing precision
+// e.g. 1500000000000000000 wei is "1.5" ether
+func formatUnit(wei *big.Int, unit string) string {
+	decimals := units[unit]
+	if decimals == 0 {
+		return wei.String()
+	}
+	abs := new(big.Int).Abs(wei)
+	q, r := new(big.Int).QuoRem(abs, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil), new(big.Int))
+
+	ret := q.String()
+	if r.Sign() != 0 {
+		frac := fmt.Sprintf("%0*s", decimals, r.String())
+		ret += "." + strings.TrimRight(frac, "0")
+	}
+	if wei.Sign() < 0 {
+		ret = "-" + ret
+	}
+	return ret
+}
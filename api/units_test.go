@@ -0,0 +1,40 @@
+package api
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestFormatUnit(t *testing.T) {
+	tt := []struct {
+		wei      string
+		unit     string
+		expected string
+	}{
+		{"0", "wei", "0"},
+		{"0", "ether", "0"},
+		{"2381188418352874359", "wei", "2381188418352874359"},
+		{"2381188418352874359", "ether", "2.381188418352874359"},
+		{"1500000000000000000", "ether", "1.5"},
+		{"1", "ether", "0.000000000000000001"},
+		{"-1000000001", "gwei", "-1.000000001"},
+		{"4000000000", "gwei", "4"},
+		{"123456789012345678901234567890", "ether", "123456789012.34567890123456789"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.wei+tc.unit, func(t *testing.T) {
+			wei, ok := new(big.Int).SetString(tc.wei, 10)
+			if !ok {
+				t.Fatalf("invalid wei %s", tc.wei)
+			}
+			if got := formatUnit(wei, tc.unit); got != tc.expected {
+				t.Errorf("got %s want %s", got, tc.expected)
+			}
+		})
+	}
+
+	if _, err := parseUnit("finney"); err == nil {
+		t.Error("unknown unit should fail")
+	}
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"os/signal"
 	"runtime"
+	"syscall"
 
 	"github.com/INFURA/infra-test-benjamin-mateo/api"
 	"github.com/INFURA/infra-test-benjamin-mateo/config"
@@ -28,9 +32,34 @@ func run() error {
 	// get an API server
 	s := api.NewServer(logger.Init(config.ReadBool("ENABLE_DEBUG")), mux.NewRouter())
 
+	if len(os.Args) > 1 && os.Args[1] == "index" {
+		return index(s, os.Args[2:])
+	}
+
 	servingURL := fmt.Sprintf("%s:%d", config.ReadString("APP_URL"), config.ReadInt("APP_PORT"))
 	fmt.Printf("running on %v cpus\n", runtime.NumCPU())
 	s.Serve(servingURL)
 
 	return nil
 }
+
+// index runs the indexer command: index [-from height] [-to height]
+// It stops on interrupt, the next run resumes after the last block stored.
+func index(s *api.Server, args []string) error {
+	flags := flag.NewFlagSet("index", flag.ContinueOnError)
+	from := flags.Uint64("from", uint64(config.ReadInt("INDEXER_FROM")), "first block indexed")
+	to := flags.Uint64("to", 0, "last block indexed, 0 follows the head")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		cancel()
+	}()
+	return s.Index(ctx, *from, *to)
+}
@@ -11,6 +11,26 @@ func Load() {
 	// Setting default value
 	viper.SetDefault("APP_URL", "0.0.0.0")
 	viper.SetDefault("APP_PORT", "8080")
+	viper.SetDefault("NODE_HEALTH_INTERVAL", 5)
+	viper.SetDefault("NODE_MAX_LAG", 3)
+	viper.SetDefault("NODE_MAX_HEAD_AGE", 60)
+	viper.SetDefault("NODE_RATE_LIMIT", 0)
+	viper.SetDefault("NODE_RATE_BURST", 0)
+	viper.SetDefault("NODE_DAILY_LIMIT", 0)
+	viper.SetDefault("NODE_RATE_MAX_WAIT_MS", 500)
+	viper.SetDefault("NODE_CASSETTE_MODE", "")
+	viper.SetDefault("NODE_CASSETTE", "cassette.jsonl")
+	viper.SetDefault("STREAM_POLL_INTERVAL", 2)
+	viper.SetDefault("STREAM_HISTORY", 256)
+	viper.SetDefault("REORG_DEPTH", 64)
+	viper.SetDefault("FEE_HISTORY_BLOCKS", 20)
+	viper.SetDefault("CACHE_SIZE", 10000)
+	viper.SetDefault("TRACE_CACHE_SIZE", 100000)
+	viper.SetDefault("HEAD_TRACKER_SIZE", 128)
+	viper.SetDefault("HEAD_TRACKER_FULL", false)
+	viper.SetDefault("STORE_PATH", "")
+	viper.SetDefault("INDEXER_ENABLED", false)
+	viper.SetDefault("INDEXER_FROM", 0)
 
 	viper.SetConfigName("app")
 	viper.SetConfigType("yaml")
@@ -37,12 +57,24 @@ func ReadString(key string) string {
 	return viper.GetString(key)
 }
 
+// ReadStringSlice reads a list of strings from configuration based on its key
+// from the environment the values are separated by spaces
+func ReadStringSlice(key string) []string {
+	isSet(key)
+	return viper.GetStringSlice(key)
+}
+
 // ReadBool reads a bool from configuration based on its key
 func ReadBool(key string) bool {
 	isSet(key)
 	return viper.GetBool(key)
 }
 
+// IsSet tells if the key is set in the configuration file, the environment or has a default value
+func IsSet(key string) bool {
+	return viper.IsSet(key)
+}
+
 func isSet(key string) {
 	if !viper.IsSet(key) {
 		panic(fmt.Errorf("key %s is not set", key))
@@ -0,0 +1,250 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"github.com/INFURA/go-ethlibs/node"
+	"github.com/pkg/errors"
+)
+
+// batchID is shared by all the batches so that every request sent in a batch gets a unique ID
+var batchID uint64
+
+// BatchElem is a single call of a batch.
+// Result must be a pointer, it is left untouched if the call fails and set to nil if the node returns null.
+type BatchElem struct {
+	Method string
+	Params []interface{}
+	Result interface{}
+	Error  error
+}
+
+// BatchCall sends all the calls in a single JSON-RPC array and decodes each response into its call.
+// The returned error is only set if the batch could not be sent, errors of a single call are set in its Error field.
+func (c *CustomClient) BatchCall(ctx context.Context, elems []BatchElem) error {
+	requests := make([]*jsonrpc.Request, len(elems))
+	index := make(map[uint64]int, len(elems))
+	for i, e := range elems {
+		params, err := jsonrpc.MakeParams(e.Params...)
+		if err != nil {
+			return errors.Wrapf(err, "invalid params for %s", e.Method)
+		}
+		id := atomic.AddUint64(&batchID, 1)
+		requests[i] = &jsonrpc.Request{
+			JSONRPC: "2.0",
+			ID:      jsonrpc.ID{Num: id},
+			Method:  e.Method,
+			Params:  params,
+		}
+		index[id] = i
+	}
+
+	var responses []*jsonrpc.RawResponse
+	var err error
+	if c.pool != nil {
+		responses, err = c.pool.BatchRequest(ctx, requests)
+	} else {
+		responses, err = requestEach(ctx, c.Client, requests)
+	}
+	if err != nil {
+		return errors.Wrap(err, "could not make batch request")
+	}
+
+	// responses may come in any order, they are matched to their call by ID
+	answered := make([]bool, len(elems))
+	for _, res := range responses {
+		if res == nil || res.ID.IsString {
+			continue
+		}
+		i, ok := index[res.ID.Num]
+		if !ok || answered[i] {
+			continue
+		}
+		answered[i] = true
+		e := &elems[i]
+		if res.Error != nil {
+			e.Error = parseRPCError(*res.Error)
+			continue
+		}
+		if e.Result == nil {
+			continue
+		}
+		if len(res.Result) == 0 {
+			res.Result = json.RawMessage(`null`)
+		}
+		if err := json.Unmarshal(res.Result, e.Result); err != nil {
+			e.Error = errors.Wrapf(err, "could not decode %s result", e.Method)
+		}
+	}
+	for i := range elems {
+		if !answered[i] {
+			elems[i].Error = errors.Errorf("no response for %s", elems[i].Method)
+		}
+	}
+	return nil
+}
+
+// requestEach is used when the transport can't send batches, it sends every request concurrently
+func requestEach(ctx context.Context, r node.Requester, requests []*jsonrpc.Request) ([]*jsonrpc.RawResponse, error) {
+	responses := make([]*jsonrpc.RawResponse, len(requests))
+	errs := make([]error, len(requests))
+	var wg sync.WaitGroup
+	for i, req := range requests {
+		wg.Add(1)
+		go func(i int, req *jsonrpc.Request) {
+			defer wg.Done()
+			responses[i], errs[i] = r.Request(ctx, req)
+			if responses[i] != nil {
+				// some transports rewrite the ID
+				responses[i].ID = req.ID
+			}
+		}(i, req)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return responses, nil
+}
+
+// batchRejectedError is returned when the node rejects a whole batch, because it doesn't support batches or the batch is too large.
+// The node is healthy, the requests can be sent one by one.
+type batchRejectedError struct {
+	// RPC is the JSON-RPC error the node rejected the batch with, nil if it only answered an http status
+	RPC     *RPCError
+	Message string
+}
+
+func (e *batchRejectedError) Error() string {
+	return "batch rejected: " + e.Message
+}
+
+// httpStatusError is returned when an http endpoint answers a status other than 2xx without a JSON-RPC response
+type httpStatusError struct {
+	Status int
+	Body   string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("http status %d: %s", e.Status, e.Body)
+}
+
+// httpBatcher posts JSON-RPC requests and arrays to an http endpoint.
+// Unlike the go-ethlibs transport it reads the http status, a 429 is returned as a RateLimitError.
+type httpBatcher struct {
+	url    string
+	client *http.Client
+}
+
+func newHTTPBatcher(url string) *httpBatcher {
+	return &httpBatcher{
+		url: url,
+		client: &http.Client{
+			Timeout:   120 * time.Second,
+			Transport: &http.Transport{MaxIdleConnsPerHost: 100},
+		},
+	}
+}
+
+// Request sends a single request
+func (b *httpBatcher) Request(ctx context.Context, r *jsonrpc.Request) (*jsonrpc.RawResponse, error) {
+	res, err := b.post(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	response := jsonrpc.RawResponse{}
+	if err := json.Unmarshal(res, &response); err != nil {
+		return nil, errors.Wrap(err, "could not decode response json")
+	}
+	return &response, nil
+}
+
+// BatchRequest sends all the requests in a single http round trip.
+// A batch the node rejects as a whole is returned as a batchRejectedError, unless the node rate limited it.
+func (b *httpBatcher) BatchRequest(ctx context.Context, requests []*jsonrpc.Request) ([]*jsonrpc.RawResponse, error) {
+	res, err := b.post(ctx, requests)
+	if status, ok := err.(*httpStatusError); ok && status.Status == http.StatusRequestEntityTooLarge {
+		return nil, &batchRejectedError{Message: status.Error()}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(res) > 0 && res[0] == '{' {
+		// the whole batch has been rejected with a single error
+		single := jsonrpc.RawResponse{}
+		if err := json.Unmarshal(res, &single); err == nil && single.Error != nil {
+			rpcErr := parseRPCError(*single.Error).(*RPCError)
+			if rpcErr.Kind == ErrRateLimited {
+				return nil, &RateLimitError{Message: rpcErr.Message, RPC: rpcErr}
+			}
+			return nil, &batchRejectedError{RPC: rpcErr, Message: rpcErr.Message}
+		}
+		return nil, errors.Errorf("unexpected batch response: %s", string(res))
+	}
+
+	var responses []*jsonrpc.RawResponse
+	if err := json.Unmarshal(res, &responses); err != nil {
+		return nil, errors.Wrap(err, "could not decode batch response")
+	}
+	return responses, nil
+}
+
+// post sends the JSON encoding of v and returns the body of the response.
+// A status other than 2xx is an httpStatusError, unless the body is a JSON-RPC error which is returned like a 2xx.
+func (b *httpBatcher) post(ctx context.Context, v interface{}) ([]byte, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not encode request")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, b.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create http.Request")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "error in client.Do")
+	}
+	defer resp.Body.Close()
+
+	res, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading body")
+	}
+	res = bytes.TrimSpace(res)
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		e := &RateLimitError{RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()), Message: "rate limited by upstream"}
+		// providers explain the limit in a JSON-RPC error
+		single := jsonrpc.RawResponse{}
+		if json.Unmarshal(res, &single) == nil && single.Error != nil {
+			e.RPC = parseRPCError(*single.Error).(*RPCError)
+			e.Message = e.RPC.Message
+		}
+		return nil, e
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// nodes may send their JSON-RPC errors with an error status
+		single := jsonrpc.RawResponse{}
+		if len(res) > 0 && res[0] == '{' && json.Unmarshal(res, &single) == nil && single.Error != nil {
+			return res, nil
+		}
+		return nil, &httpStatusError{Status: resp.StatusCode, Body: string(res)}
+	}
+	return res, nil
+}
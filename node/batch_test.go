@@ -0,0 +1,165 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"go.uber.org/zap"
+)
+
+func TestBatchCall(t *testing.T) {
+	var batches int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqs []jsonrpc.Request
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+			// health probes are single requests
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":"0x10"}`)
+			return
+		}
+		batches++
+		// answer in reverse order to check the responses are matched by ID
+		res := make([]string, 0, len(reqs))
+		for i := len(reqs) - 1; i >= 0; i-- {
+			switch reqs[i].Method {
+			case "eth_blockNumber":
+				res = append(res, fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":"0x10"}`, reqs[i].ID))
+			case "eth_getBlockByNumber":
+				res = append(res, fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":null}`, reqs[i].ID))
+			default:
+				res = append(res, fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"error":{"code":-32601,"message":"method not found"}}`, reqs[i].ID))
+			}
+		}
+		var raw []json.RawMessage
+		for _, r := range res {
+			raw = append(raw, json.RawMessage(r))
+		}
+		b, _ := json.Marshal(raw)
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	pool, err := NewPool(PoolConfig{URLs: []string{srv.URL}, HealthInterval: time.Hour}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	client, err := GetNewPooledClient(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var height eth.Quantity
+	block := &eth.Block{}
+	var missing string
+	batch := []BatchElem{
+		{Method: "eth_blockNumber", Result: &height},
+		{Method: "eth_getBlockByNumber", Params: []interface{}{"latest", false}, Result: &block},
+		{Method: "eth_missing", Result: &missing},
+	}
+	if err := client.BatchCall(context.Background(), batch); err != nil {
+		t.Fatal(err)
+	}
+
+	if batches != 1 {
+		t.Errorf("got %d round trips want 1", batches)
+	}
+	if batch[0].Error != nil || height.UInt64() != 16 {
+		t.Errorf("got height %d err %v want 16", height.UInt64(), batch[0].Error)
+	}
+	if batch[1].Error != nil || block != nil {
+		t.Errorf("null result should set the block to nil got %v err %v", block, batch[1].Error)
+	}
+	if batch[2].Error == nil {
+		t.Error("JSON-RPC error should be set on the call")
+	}
+}
+
+func TestBatchRejected(t *testing.T) {
+	tt := []struct {
+		name   string
+		reject func(w http.ResponseWriter)
+	}{
+		{"batch unsupported", func(w http.ResponseWriter) {
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"batch requests are not supported"}}`)
+		}},
+		{"batch too large", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			fmt.Fprint(w, "request entity too large")
+		}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var batches, singles int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				req := jsonrpc.Request{}
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					atomic.AddInt32(&batches, 1)
+					tc.reject(w)
+					return
+				}
+				atomic.AddInt32(&singles, 1)
+				fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"0x10"}`, req.ID)
+			}))
+			defer srv.Close()
+
+			pool, err := NewPool(PoolConfig{URLs: []string{srv.URL}, HealthInterval: time.Hour}, zap.NewNop().Sugar())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pool.Close()
+			client, err := GetNewPooledClient(pool)
+			if err != nil {
+				t.Fatal(err)
+			}
+			probes := atomic.LoadInt32(&singles)
+
+			var a, b eth.Quantity
+			batch := []BatchElem{{Method: "eth_blockNumber", Result: &a}, {Method: "eth_gasPrice", Result: &b}}
+			if err := client.BatchCall(context.Background(), batch); err != nil {
+				t.Fatal(err)
+			}
+			// the requests are sent one by one and the node stays in rotation
+			if batch[0].Error != nil || batch[1].Error != nil || a.UInt64() != 16 || b.UInt64() != 16 {
+				t.Errorf("got %d err %v and %d err %v want 16", a.UInt64(), batch[0].Error, b.UInt64(), batch[1].Error)
+			}
+			if n, m := atomic.LoadInt32(&batches), atomic.LoadInt32(&singles)-probes; n != 1 || m != 2 {
+				t.Errorf("got %d batches and %d single requests want 1 and 2", n, m)
+			}
+			if st := pool.Status()[0]; !st.Healthy {
+				t.Errorf("node rejecting a batch taken out of rotation err:%s", st.Error)
+			}
+		})
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rpc-error" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"invalid params"}}`)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprint(w, "<html>bad gateway</html>")
+	}))
+	defer srv.Close()
+
+	req := &jsonrpc.Request{ID: jsonrpc.ID{Num: 1}, Method: "eth_blockNumber"}
+	// a JSON-RPC error is a response of the node whatever the status
+	res, err := newHTTPBatcher(srv.URL+"/rpc-error").Request(context.Background(), req)
+	if err != nil || res.Error == nil {
+		t.Errorf("got response %v err %v want the JSON-RPC error", res, err)
+	}
+	if _, err := newHTTPBatcher(srv.URL).Request(context.Background(), req); err == nil {
+		t.Error("an error status without JSON-RPC response should fail")
+	}
+}
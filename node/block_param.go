@@ -0,0 +1,91 @@
+package node
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/pkg/errors"
+)
+
+// Block tags supported on top of the go-ethlibs ones
+const (
+	TagSafe      = "safe"
+	TagFinalized = "finalized"
+)
+
+// LatestBlock targets the state of the latest block
+var LatestBlock = BlockParam{Tag: eth.TagLatest}
+
+// BlockParam selects the block a state query is executed against.
+// Exactly one of Number, Tag or Hash is set, Hash is encoded as an EIP-1898 object.
+type BlockParam struct {
+	Number           *eth.Quantity
+	Tag              string
+	Hash             *eth.Hash
+	RequireCanonical bool
+}
+
+// ParseBlockParam parses a decimal or hex block number, a block tag or a block hash.
+// An empty value targets the latest block.
+func ParseBlockParam(value string, requireCanonical bool) (BlockParam, error) {
+	switch value {
+	case "":
+		return LatestBlock, nil
+	case eth.TagLatest, eth.TagEarliest, eth.TagPending, TagSafe, TagFinalized:
+		return BlockParam{Tag: value}, nil
+	}
+
+	if strings.HasPrefix(value, "0x") {
+		if len(value) == 66 {
+			h, err := eth.NewHash(value)
+			if err != nil {
+				return BlockParam{}, errors.Wrap(err, "invalid block hash")
+			}
+			return BlockParam{Hash: h, RequireCanonical: requireCanonical}, nil
+		}
+		q, err := eth.NewQuantity(value)
+		if err != nil {
+			return BlockParam{}, errors.Wrap(err, "invalid block number")
+		}
+		n := eth.QuantityFromBigInt(q.Big())
+		return BlockParam{Number: &n}, nil
+	}
+
+	n, ok := new(big.Int).SetString(value, 10)
+	if !ok || n.Sign() < 0 {
+		return BlockParam{}, errors.Errorf("invalid block %s, should be a number, a hash or one of latest, earliest, pending, safe, finalized", value)
+	}
+	q := eth.QuantityFromBigInt(n)
+	return BlockParam{Number: &q}, nil
+}
+
+// String returns a human readable form of the block param
+func (b BlockParam) String() string {
+	switch {
+	case b.Hash != nil:
+		return b.Hash.String()
+	case b.Number != nil:
+		return b.Number.Big().String()
+	case b.Tag != "":
+		return b.Tag
+	}
+	return eth.TagLatest
+}
+
+// MarshalJSON encodes the block param as expected by the JSON-RPC state methods
+func (b BlockParam) MarshalJSON() ([]byte, error) {
+	switch {
+	case b.Hash != nil:
+		return json.Marshal(struct {
+			BlockHash        eth.Hash `json:"blockHash"`
+			RequireCanonical bool     `json:"requireCanonical,omitempty"`
+		}{*b.Hash, b.RequireCanonical})
+	case b.Number != nil:
+		return b.Number.MarshalJSON()
+	case b.Tag != "":
+		return json.Marshal(b.Tag)
+	}
+	return json.Marshal(eth.TagLatest)
+}
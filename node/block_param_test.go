@@ -0,0 +1,49 @@
+package node
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseBlockParam(t *testing.T) {
+	tt := []struct {
+		value            string
+		requireCanonical bool
+		expected         string
+		fails            bool
+	}{
+		{"", false, `"latest"`, false},
+		{"finalized", false, `"finalized"`, false},
+		{"safe", false, `"safe"`, false},
+		{"earliest", false, `"earliest"`, false},
+		{"9135250", false, `"0x8b6492"`, false},
+		{"0x8B6492", false, `"0x8b6492"`, false},
+		{"0x840eed2d9390ea527089a7c2f2b6dd28ef382c094105d29d22b911e606c4e0bc", false, `{"blockHash":"0x840eed2d9390ea527089a7c2f2b6dd28ef382c094105d29d22b911e606c4e0bc"}`, false},
+		{"0x840eed2d9390ea527089a7c2f2b6dd28ef382c094105d29d22b911e606c4e0bc", true, `{"blockHash":"0x840eed2d9390ea527089a7c2f2b6dd28ef382c094105d29d22b911e606c4e0bc","requireCanonical":true}`, false},
+		{"head", false, "", true},
+		{"-1", false, "", true},
+		{"0xzz", false, "", true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.value, func(t *testing.T) {
+			b, err := ParseBlockParam(tc.value, tc.requireCanonical)
+			if tc.fails {
+				if err == nil {
+					t.Errorf("should have failed got %s", b)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := json.Marshal(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(res) != tc.expected {
+				t.Errorf("got %s want %s", res, tc.expected)
+			}
+		})
+	}
+}
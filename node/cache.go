@@ -0,0 +1,323 @@
+package node
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/INFURA/go-ethlibs/eth"
+)
+
+// CacheConfig configures a BlockCache
+type CacheConfig struct {
+	// Size bounds the cache, a block weighs one plus its transactions when they are full and a transaction one
+	Size int
+	// Finality is the number of confirmations after which a block can't be reorganized
+	Finality uint64
+}
+
+// CacheStats are the counters of a BlockCache
+type CacheStats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Entries       int    `json:"entries"`
+	Size          int    `json:"size"`
+	MaxSize       int    `json:"maxSize"`
+	Evictions     uint64 `json:"evictions"`
+	Invalidations uint64 `json:"invalidations"`
+	Head          uint64 `json:"head"`
+}
+
+// cacheEntry is a block or a transaction of the cache
+type cacheEntry struct {
+	key    string
+	number uint64
+	block  *eth.Block
+	tx     *eth.Transaction
+	weight int
+	// height is the key of the height index pointing to the entry, empty if there is none
+	height string
+}
+
+// BlockCache is a least recently used cache of blocks and transactions.
+// Blocks are cached by hash and by height, transactions by hash.
+// Blocks by hash never change, the entries that depend on the canonical chain are only cached while the cache follows
+// the head with Follow: the blocks by height and the transactions whose block may still be reorganized are dropped on reorg.
+type BlockCache struct {
+	cfg CacheConfig
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	heights map[string]string
+	// head is the last canonical block seen by Follow, 0 until it follows the chain
+	head uint64
+	// epoch changes on every invalidation
+	epoch uint64
+	size  int
+	stats CacheStats
+}
+
+// NewBlockCache creates an empty cache
+func NewBlockCache(cfg CacheConfig) *BlockCache {
+	if cfg.Size <= 0 {
+		cfg.Size = 10000
+	}
+	return &BlockCache{
+		cfg:     cfg,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		heights: make(map[string]string),
+	}
+}
+
+// Follow keeps the cache consistent with the canonical chain until the context is done.
+// The head gives the confirmations of the blocks and a reorg drops the entries of the blocks above the common ancestor.
+func (c *BlockCache) Follow(ctx context.Context, feed *HeadFeed) {
+	// the events kept by the feed give the head when the cache starts after it
+	var lastID uint64
+	for ctx.Err() == nil {
+		replay, events, cancel := feed.Subscribe(true, lastID)
+		for _, ev := range replay {
+			c.onEvent(ev)
+			lastID = ev.ID
+		}
+		open := true
+		for open {
+			select {
+			case <-ctx.Done():
+				cancel()
+				return
+			case ev, ok := <-events:
+				if !ok {
+					open = false
+					break
+				}
+				c.onEvent(ev)
+				lastID = ev.ID
+			}
+		}
+		cancel()
+		// events may have been missed, nothing above finality can be trusted
+		c.invalidateAbove(c.finalized())
+	}
+}
+
+// BlockByHash returns a cached block
+func (c *BlockCache) BlockByHash(hash string, full bool) (*eth.Block, bool) {
+	e := c.get(blockKey(hash, full))
+	if e == nil {
+		return nil, false
+	}
+	return e.block, true
+}
+
+// BlockByNumber returns a cached canonical block
+func (c *BlockCache) BlockByNumber(number uint64, full bool) (*eth.Block, bool) {
+	c.mu.Lock()
+	key, ok := c.heights[heightKey(number, full)]
+	c.mu.Unlock()
+	if !ok {
+		c.miss()
+		return nil, false
+	}
+	e := c.get(key)
+	if e == nil {
+		return nil, false
+	}
+	return e.block, true
+}
+
+// TransactionByHash returns a cached mined transaction
+func (c *BlockCache) TransactionByHash(hash string) (*eth.Transaction, bool) {
+	e := c.get(txKey(hash))
+	if e == nil {
+		return nil, false
+	}
+	return e.tx, true
+}
+
+// Epoch changes when the cache drops the entries of a reorg.
+// It is read before requesting the node, an entry requested before the reorg and added after is not indexed.
+func (c *BlockCache) Epoch() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.epoch
+}
+
+// AddBlock caches a block, canonical is set when it was requested by height
+func (c *BlockCache) AddBlock(b *eth.Block, full bool, canonical bool, epoch uint64) {
+	if b == nil || b.Hash == nil || b.Number == nil {
+		// pending block
+		return
+	}
+	weight := 1
+	if full {
+		weight += len(b.Transactions)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := &cacheEntry{key: blockKey(string(*b.Hash), full), number: b.Number.UInt64(), block: b, weight: weight}
+	if canonical && c.head > 0 && epoch == c.epoch {
+		e.height = heightKey(e.number, full)
+	}
+	c.add(e)
+}
+
+// AddTransaction caches a mined transaction while the cache follows the chain
+func (c *BlockCache) AddTransaction(tx *eth.Transaction, epoch uint64) {
+	if tx == nil || tx.BlockNumber == nil {
+		// pending transaction, its block is not known yet
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.head == 0 || epoch != c.epoch {
+		return
+	}
+	c.add(&cacheEntry{key: txKey(string(tx.Hash)), number: tx.BlockNumber.UInt64(), tx: tx, weight: 1})
+}
+
+// IsFinal tells if a block can't be reorganized anymore, it is false until the cache follows the chain
+func (c *BlockCache) IsFinal(number uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.head > 0 && number+c.cfg.Finality <= c.head
+}
+
+// Stats returns the counters of the cache
+func (c *BlockCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries = c.lru.Len()
+	s.Size = c.size
+	s.MaxSize = c.cfg.Size
+	s.Head = c.head
+	return s
+}
+
+func (c *BlockCache) onEvent(ev HeadEvent) {
+	switch ev.Type {
+	case BlockEvent:
+		c.mu.Lock()
+		if n := ev.Head.Number.UInt64(); n > c.head {
+			c.head = n
+		}
+		c.mu.Unlock()
+	case ReorgEvent:
+		if ev.Reorg.CommonAncestor != nil {
+			c.invalidateAbove(ev.Reorg.CommonAncestor.Number)
+		} else {
+			c.invalidateAbove(c.finalized())
+		}
+		c.mu.Lock()
+		c.head = ev.Reorg.NewHead.Number
+		c.mu.Unlock()
+	}
+}
+
+// finalized returns the last block that can't be reorganized
+func (c *BlockCache) finalized() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.head < c.cfg.Finality {
+		return 0
+	}
+	return c.head - c.cfg.Finality
+}
+
+// invalidateAbove drops the blocks by height and the transactions above the block number, the blocks by hash are kept
+func (c *BlockCache) invalidateAbove(number uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	for el := c.lru.Front(); el != nil; {
+		next := el.Next()
+		e := el.Value.(*cacheEntry)
+		if e.number > number {
+			if e.tx != nil {
+				c.remove(el)
+				c.stats.Invalidations++
+			} else if e.height != "" {
+				delete(c.heights, e.height)
+				e.height = ""
+				c.stats.Invalidations++
+			}
+		}
+		el = next
+	}
+}
+
+func (c *BlockCache) get(key string) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return nil
+	}
+	c.stats.Hits++
+	c.lru.MoveToFront(el)
+	return el.Value.(*cacheEntry)
+}
+
+func (c *BlockCache) miss() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Misses++
+}
+
+// add inserts or replaces an entry then evicts the least recently used ones, c.mu must be held
+func (c *BlockCache) add(e *cacheEntry) {
+	if e.weight > c.cfg.Size {
+		return
+	}
+	if el, ok := c.entries[e.key]; ok {
+		if e.height == "" {
+			// keep the height index of a block cached again by hash
+			e.height = el.Value.(*cacheEntry).height
+		}
+		c.remove(el)
+	}
+	if e.height != "" {
+		if key, ok := c.heights[e.height]; ok && key != e.key {
+			// another block was canonical at this height
+			if el, ok := c.entries[key]; ok {
+				el.Value.(*cacheEntry).height = ""
+			}
+		}
+		c.heights[e.height] = e.key
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+	c.size += e.weight
+	for c.size > c.cfg.Size {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// remove drops an entry and its height index, c.mu must be held
+func (c *BlockCache) remove(el *list.Element) {
+	e := el.Value.(*cacheEntry)
+	c.lru.Remove(el)
+	delete(c.entries, e.key)
+	if e.height != "" && c.heights[e.height] == e.key {
+		delete(c.heights, e.height)
+	}
+	c.size -= e.weight
+}
+
+func blockKey(hash string, full bool) string {
+	return fmt.Sprintf("block:%s:%v", strings.ToLower(hash), full)
+}
+
+func heightKey(number uint64, full bool) string {
+	return fmt.Sprintf("%d:%v", number, full)
+}
+
+func txKey(hash string) string {
+	return "tx:" + strings.ToLower(hash)
+}
@@ -0,0 +1,111 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/INFURA/infra-test-benjamin-mateo/node/nodetest"
+	"go.uber.org/zap"
+)
+
+func TestBlockCache(t *testing.T) {
+	srv := nodetest.NewServer(nil)
+	defer srv.Close()
+	client, err := GetNewCustomClient(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	feed := NewHeadFeed(&client, HeadFeedConfig{Depth: 16}, zap.NewNop().Sugar())
+	cache := NewBlockCache(CacheConfig{Size: 20, Finality: nodetest.FinalizedDepth})
+
+	onHead := func(b *eth.Block, wait func(CacheStats) bool) {
+		head := &eth.NewHeadsResult{}
+		head.FromBlock(b)
+		if err := feed.onHead(ctx, head); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for !wait(cache.Stats()) {
+			if time.Now().After(deadline) {
+				t.Fatalf("cache didn't follow head %d: %+v", b.Number.UInt64(), cache.Stats())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	head := srv.Chain.Head()
+	n := head.Number.UInt64()
+	recent, final := srv.Chain.Block(n-1), srv.Chain.Block(n-20)
+	tx := srv.Chain.Transaction(n, 0)
+
+	// until the cache follows the chain only the blocks by hash are cached
+	cache.AddBlock(recent, false, true, cache.Epoch())
+	cache.AddTransaction(tx, cache.Epoch())
+	if _, ok := cache.BlockByNumber(n-1, false); ok {
+		t.Error("block cached by height without following the chain")
+	}
+	if _, ok := cache.TransactionByHash(string(tx.Hash)); ok {
+		t.Error("transaction cached without following the chain")
+	}
+	if b, ok := cache.BlockByHash(string(*recent.Hash), false); !ok || *b.Hash != *recent.Hash {
+		t.Error("block not cached by hash")
+	}
+
+	go cache.Follow(ctx, feed)
+	onHead(head, func(s CacheStats) bool { return s.Head == n })
+	if cache.IsFinal(n-1) || !cache.IsFinal(n-20) {
+		t.Errorf("finality of blocks %d and %d", n-1, n-20)
+	}
+
+	epoch := cache.Epoch()
+	cache.AddBlock(recent, false, true, epoch)
+	cache.AddBlock(final, false, true, epoch)
+	cache.AddTransaction(tx, epoch)
+	if _, ok := cache.BlockByNumber(n-1, false); !ok {
+		t.Error("recent block not cached by height")
+	}
+	if _, ok := cache.BlockByNumber(n-1, true); ok {
+		t.Error("light block served as full")
+	}
+	if got, ok := cache.TransactionByHash(string(tx.Hash)); !ok || got.Hash != tx.Hash {
+		t.Error("transaction not cached")
+	}
+
+	// a reorg drops the entries above the common ancestor
+	fork := srv.Reorg(2)
+	onHead(fork[len(fork)-1], func(s CacheStats) bool { return s.Invalidations >= 2 })
+	if _, ok := cache.BlockByNumber(n-1, false); ok {
+		t.Error("reorganized block still cached by height")
+	}
+	if _, ok := cache.TransactionByHash(string(tx.Hash)); ok {
+		t.Error("transaction of a reorganized block still cached")
+	}
+	if _, ok := cache.BlockByNumber(n-20, false); !ok {
+		t.Error("final block dropped by the reorg")
+	}
+	if _, ok := cache.BlockByHash(string(*recent.Hash), false); !ok {
+		t.Error("block by hash dropped by the reorg")
+	}
+
+	// a block requested before the reorg is not indexed by height
+	cache.AddBlock(srv.Chain.Block(n-2), false, true, epoch)
+	if _, ok := cache.BlockByNumber(n-2, false); ok {
+		t.Error("block of an older epoch cached by height")
+	}
+
+	// full blocks weigh their transactions
+	for i := uint64(0); i < 10; i++ {
+		cache.AddBlock(srv.Chain.Block(n-10-i), true, true, cache.Epoch())
+	}
+	s := cache.Stats()
+	if s.Size > 20 || s.Evictions == 0 {
+		t.Errorf("cache not bounded: %+v", s)
+	}
+	if s.Hits == 0 || s.Misses == 0 {
+		t.Errorf("hits and misses not counted: %+v", s)
+	}
+}
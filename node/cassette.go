@@ -0,0 +1,216 @@
+package node
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"github.com/INFURA/go-ethlibs/node"
+	"github.com/pkg/errors"
+)
+
+// CassetteMode selects whether a cassette records the traffic of the node or replays it
+type CassetteMode string
+
+// Modes of a cassette
+const (
+	// RecordMode forwards every request to the node and writes it with its response to the cassette
+	RecordMode CassetteMode = "record"
+	// ReplayMode serves the responses of the cassette without any node
+	ReplayMode CassetteMode = "replay"
+)
+
+// ErrNotRecorded is returned in replay mode when the cassette has no response for a request
+var ErrNotRecorded = errors.New("request not recorded in the cassette")
+
+// Interaction is a request and the response of the node, it is a line of a cassette file
+type Interaction struct {
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params,omitempty"`
+	Result json.RawMessage  `json:"result,omitempty"`
+	Error  *json.RawMessage `json:"error,omitempty"`
+}
+
+// Cassette records the JSON-RPC traffic of a node to a file or replays it.
+// Requests are matched on their method and params, the ID is ignored.
+// A request recorded several times gets its responses in the recorded order then the last one again,
+// so a replayed eth_blockNumber moves forward like during the recording and then stays at the last head.
+// Transport errors are not recorded and subscriptions are not supported.
+// It implements the go-ethlibs Requester interface.
+type Cassette struct {
+	mode     CassetteMode
+	upstream node.Requester
+
+	mu sync.Mutex
+	// file and w are only set in record mode
+	file *os.File
+	w    *bufio.Writer
+	// replay queues the responses by request, served counts the responses already served
+	replay map[string][]*Interaction
+	served map[string]int
+}
+
+// NewCassette opens a cassette file.
+// In record mode the file is truncated and every request is forwarded to upstream,
+// in replay mode the file is loaded and upstream is not used.
+func NewCassette(path string, mode CassetteMode, upstream node.Requester) (*Cassette, error) {
+	c := &Cassette{mode: mode, upstream: upstream}
+	switch mode {
+	case RecordMode:
+		if upstream == nil {
+			return nil, errors.New("recording needs an upstream node")
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not create cassette")
+		}
+		c.file = f
+		c.w = bufio.NewWriter(f)
+	case ReplayMode:
+		if err := c.load(path); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("unknown cassette mode %q, use record or replay", mode)
+	}
+	return c, nil
+}
+
+// GetNewCassetteClient returns a new ethereum client recording or replaying its requests with the cassette
+func GetNewCassetteClient(cassette *Cassette) (CustomClient, error) {
+	client, err := node.NewCustomClient(cassette, nil)
+	if err != nil {
+		return CustomClient{}, err
+	}
+	return CustomClient{Client: client}, nil
+}
+
+// Request records the request and its response or replays the response
+func (c *Cassette) Request(ctx context.Context, r *jsonrpc.Request) (*jsonrpc.RawResponse, error) {
+	params, err := requestParams(r)
+	if err != nil {
+		return nil, err
+	}
+	if c.mode == ReplayMode {
+		return c.replayed(r, params)
+	}
+
+	res, err := c.upstream.Request(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	err = c.record(&Interaction{Method: r.Method, Params: params, Result: res.Result, Error: res.Error})
+	return res, err
+}
+
+// Close writes the interactions recorded to the file
+func (c *Cassette) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return nil
+	}
+	err := c.w.Flush()
+	if cerr := c.file.Close(); err == nil {
+		err = cerr
+	}
+	c.file = nil
+	return errors.Wrap(err, "could not write cassette")
+}
+
+func (c *Cassette) record(i *Interaction) error {
+	line, err := json.Marshal(i)
+	if err != nil {
+		return errors.Wrap(err, "could not encode interaction")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return errors.New("cassette closed")
+	}
+	c.w.Write(line)
+	c.w.WriteByte('\n')
+	// flushed on every request so that a recording interrupted is still usable
+	return errors.Wrap(c.w.Flush(), "could not write cassette")
+}
+
+func (c *Cassette) replayed(r *jsonrpc.Request, params json.RawMessage) (*jsonrpc.RawResponse, error) {
+	key := interactionKey(r.Method, params)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	queue := c.replay[key]
+	if len(queue) == 0 {
+		return nil, errors.Wrapf(ErrNotRecorded, "%s %s", r.Method, params)
+	}
+	n := c.served[key]
+	if n >= len(queue) {
+		n = len(queue) - 1
+	}
+	c.served[key]++
+	i := queue[n]
+	return &jsonrpc.RawResponse{JSONRPC: "2.0", ID: r.ID, Result: i.Result, Error: i.Error}, nil
+}
+
+func (c *Cassette) load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "could not open cassette")
+	}
+	defer f.Close()
+
+	c.replay = make(map[string][]*Interaction)
+	c.served = make(map[string]int)
+	lines := bufio.NewScanner(f)
+	// a line holds a whole response, full blocks exceed the default limit
+	lines.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for n := 1; lines.Scan(); n++ {
+		if len(bytes.TrimSpace(lines.Bytes())) == 0 {
+			continue
+		}
+		i := &Interaction{}
+		if err := json.Unmarshal(lines.Bytes(), i); err != nil {
+			return errors.Wrapf(err, "invalid interaction line %d", n)
+		}
+		params, err := compact(i.Params)
+		if err != nil {
+			return errors.Wrapf(err, "invalid params line %d", n)
+		}
+		key := interactionKey(i.Method, params)
+		c.replay[key] = append(c.replay[key], i)
+	}
+	return errors.Wrap(lines.Err(), "could not read cassette")
+}
+
+// requestParams returns the params of the request as compact JSON, nil if there is none
+func requestParams(r *jsonrpc.Request) (json.RawMessage, error) {
+	if len(r.Params) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(r.Params)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid params for %s", r.Method)
+	}
+	return compact(b)
+}
+
+func compact(b json.RawMessage) (json.RawMessage, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	buf := bytes.Buffer{}
+	if err := json.Compact(&buf, b); err != nil {
+		return nil, err
+	}
+	if buf.String() == "[]" || buf.String() == "null" {
+		return nil, nil
+	}
+	return buf.Bytes(), nil
+}
+
+func interactionKey(method string, params json.RawMessage) string {
+	return method + " " + string(params)
+}
@@ -0,0 +1,101 @@
+package node
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/INFURA/infra-test-benjamin-mateo/node/nodetest"
+	"github.com/pkg/errors"
+)
+
+func TestCassette(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mainnet.jsonl")
+	ctx := context.Background()
+
+	// session runs the same requests while recording and replaying, mine is only set while recording
+	type result struct {
+		Heads   []uint64
+		Block   string
+		Account *Account
+		Revert  string
+	}
+	session := func(c *CustomClient, mine func()) result {
+		res := result{}
+		for i := 0; i < 3; i++ {
+			head, err := c.BlockNumber(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Heads = append(res.Heads, head)
+			if mine != nil && i == 0 {
+				mine()
+			}
+		}
+		b, err := c.BlockByNumber(ctx, nodetest.FirstBlock, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Block = string(*b.Hash)
+		if res.Account, err = c.GetAccount(ctx, nodetest.Token.Hex(), LatestBlock); err != nil {
+			t.Fatal(err)
+		}
+		_, err = c.CallContract(ctx, CallParams{To: eth.Data(nodetest.Token.Hex()), Data: "0x12345678"}, LatestBlock)
+		if err == nil {
+			t.Fatal("call should revert")
+		}
+		res.Revert = err.Error()
+		return res
+	}
+
+	fake := nodetest.NewServer(nil)
+	upstream, err := GetNewCustomClient(fake.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder, err := NewCassette(path, RecordMode, upstream.Client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := GetNewCassetteClient(recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := session(&client, func() { fake.Mine() })
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fake.Close()
+
+	player, err := NewCassette(path, ReplayMode, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err = GetNewCassetteClient(player)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := session(&client, nil)
+	if !reflect.DeepEqual(recorded, replayed) {
+		t.Errorf("replay differs from the recording:\n%+v\n%+v", recorded, replayed)
+	}
+	if recorded.Heads[0] == recorded.Heads[1] || recorded.Heads[1] != recorded.Heads[2] {
+		t.Errorf("heads should move once: %v", recorded.Heads)
+	}
+
+	if _, err := client.BlockByNumber(ctx, nodetest.FirstBlock+1, false); errors.Cause(err) != ErrNotRecorded {
+		t.Errorf("unknown request: got %v want %v", err, ErrNotRecorded)
+	}
+	if _, err := NewCassette(path, "rewind", nil); err == nil {
+		t.Error("unknown mode should fail")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"math/big"
+	"strings"
 
 	"github.com/INFURA/go-ethlibs/eth"
 	"github.com/INFURA/go-ethlibs/jsonrpc"
@@ -11,19 +13,21 @@ import (
 	"github.com/pkg/errors"
 )
 
-// CustomClient ddd
+// CustomClient extends the go-ethlibs client with more methods
 type CustomClient struct {
 	node.Client
+	pool *Pool
 }
 
-// CallParams parameters for eth_call
+// CallParams parameters for eth_call, eth_estimateGas and eth_createAccessList
+// To is empty for a contract creation, the node picks a default for the other empty fields
 type CallParams struct {
-	From     eth.Data
-	To       eth.Data
-	Gas      eth.Quantity
-	Value    eth.Quantity
-	Data     eth.Data
-	GasPrice eth.Quantity
+	From     eth.Data      `json:"from,omitempty"`
+	To       eth.Data      `json:"to,omitempty"`
+	Gas      *eth.Quantity `json:"gas,omitempty"`
+	Value    *eth.Quantity `json:"value,omitempty"`
+	Data     eth.Data      `json:"data,omitempty"`
+	GasPrice *eth.Quantity `json:"gasPrice,omitempty"`
 }
 
 // GetNewCustomClient returns a new ethereum client
@@ -31,12 +35,132 @@ func GetNewCustomClient(target string) (CustomClient, error) {
 	ctx := context.Background()
 	client, err := node.NewClient(ctx, target)
 	if err != nil {
-		return CustomClient{nil}, err
+		return CustomClient{}, err
 	}
-	return CustomClient{client}, nil
+	return CustomClient{Client: client}, nil
 }
 
-// TransactionByBlockNumberAndIndex get transaction based on its ID in a block designed by its hash
+// GetNewPooledClient returns a new ethereum client routing every request to a healthy upstream of the pool
+func GetNewPooledClient(pool *Pool) (CustomClient, error) {
+	client, err := node.NewCustomClient(pool, pool)
+	if err != nil {
+		return CustomClient{}, err
+	}
+	return CustomClient{Client: client, pool: pool}, nil
+}
+
+// IsBidirectional returns true if subscriptions are supported
+func (c *CustomClient) IsBidirectional() bool {
+	if c.pool != nil {
+		return c.pool.IsBidirectional()
+	}
+	return c.Client.IsBidirectional()
+}
+
+// Pool returns the upstream pool or nil if the client targets a single node
+func (c *CustomClient) Pool() *Pool {
+	return c.pool
+}
+
+// The methods of the go-ethlibs client return the JSON-RPC errors as plain text,
+// they are wrapped so that every method of CustomClient returns an RPCError.
+
+// BlockNumber returns the number of the latest block
+func (c *CustomClient) BlockNumber(ctx context.Context) (uint64, error) {
+	n, err := c.Client.BlockNumber(ctx)
+	return n, typedError(err)
+}
+
+// BlockByNumber returns the block at a height, node.ErrBlockNotFound if there is none
+func (c *CustomClient) BlockByNumber(ctx context.Context, number uint64, full bool) (*eth.Block, error) {
+	b, err := c.Client.BlockByNumber(ctx, number, full)
+	return b, typedError(err)
+}
+
+// BlockByNumberOrTag returns the block at a height or tag, node.ErrBlockNotFound if there is none
+func (c *CustomClient) BlockByNumberOrTag(ctx context.Context, numberOrTag eth.BlockNumberOrTag, full bool) (*eth.Block, error) {
+	b, err := c.Client.BlockByNumberOrTag(ctx, numberOrTag, full)
+	return b, typedError(err)
+}
+
+// BlockByTag returns the block of a tag, node.ErrBlockNotFound if there is none.
+// Unlike BlockByNumberOrTag it accepts the tags unknown to go-ethlibs like safe and finalized.
+func (c *CustomClient) BlockByTag(ctx context.Context, tag string, full bool) (*eth.Block, error) {
+	request := jsonrpc.Request{
+		ID:     jsonrpc.ID{Num: 1},
+		Method: "eth_getBlockByNumber",
+		Params: jsonrpc.MustParams(tag, full),
+	}
+
+	response, err := c.Request(ctx, &request)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not make  request")
+	}
+
+	if response.Error != nil {
+		return nil, parseRPCError(*response.Error)
+	}
+
+	if len(response.Result) == 0 || bytes.Equal(response.Result, json.RawMessage(`null`)) {
+		return nil, node.ErrBlockNotFound
+	}
+
+	b := eth.Block{}
+	err = b.UnmarshalJSON(response.Result)
+	return &b, err
+}
+
+// BlockByHash returns the block with the hash, node.ErrBlockNotFound if there is none
+func (c *CustomClient) BlockByHash(ctx context.Context, hash string, full bool) (*eth.Block, error) {
+	b, err := c.Client.BlockByHash(ctx, hash, full)
+	return b, typedError(err)
+}
+
+// TransactionReceipt returns the receipt of a mined transaction
+func (c *CustomClient) TransactionReceipt(ctx context.Context, hash string) (*eth.TransactionReceipt, error) {
+	r, err := c.Client.TransactionReceipt(ctx, hash)
+	return r, typedError(err)
+}
+
+// Logs returns the logs matching the filter
+func (c *CustomClient) Logs(ctx context.Context, filter eth.LogFilter) ([]eth.Log, error) {
+	logs, err := c.Client.Logs(ctx, filter)
+	return logs, typedError(err)
+}
+
+// TransactionByHash returns a transaction, node.ErrTransactionNotFound if the node doesn't know it.
+// Unlike the go-ethlibs client an error of the node is not reported as a transaction not found.
+func (c *CustomClient) TransactionByHash(ctx context.Context, hash string) (*eth.Transaction, error) {
+	h, err := eth.NewHash(hash)
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidParams, "invalid hash: %s", err)
+	}
+
+	request := jsonrpc.Request{
+		ID:     jsonrpc.ID{Num: 1},
+		Method: "eth_getTransactionByHash",
+		Params: jsonrpc.MustParams(h),
+	}
+
+	response, err := c.Request(ctx, &request)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not make transaction by hash request")
+	}
+
+	if response.Error != nil {
+		return nil, parseRPCError(*response.Error)
+	}
+
+	if len(response.Result) == 0 || bytes.Equal(response.Result, json.RawMessage(`null`)) {
+		return nil, node.ErrTransactionNotFound
+	}
+
+	tx := eth.Transaction{}
+	err = tx.UnmarshalJSON(response.Result)
+	return &tx, err
+}
+
+// TransactionByBlockNumberAndIndex get transaction based on its ID in a block designed by its number
 func (c *CustomClient) TransactionByBlockNumberAndIndex(ctx context.Context, number uint64, index uint64) (*eth.Transaction, error) {
 	n := eth.QuantityFromUInt64(number)
 	i := eth.QuantityFromUInt64(index)
@@ -51,6 +175,10 @@ func (c *CustomClient) TransactionByBlockNumberAndIndex(ctx context.Context, num
 		return nil, errors.Wrap(err, "could not make  request")
 	}
 
+	if response.Error != nil {
+		return nil, parseRPCError(*response.Error)
+	}
+
 	if len(response.Result) == 0 || bytes.Equal(response.Result, json.RawMessage(`null`)) {
 		// Then the transaction isn't recognized
 		return nil, node.ErrTransactionNotFound
@@ -61,30 +189,66 @@ func (c *CustomClient) TransactionByBlockNumberAndIndex(ctx context.Context, num
 	return &tx, err
 }
 
-// GetBalance balance of an address from latest state
-func (c *CustomClient) GetBalance(ctx context.Context, address string) (uint64, error) {
+// TransactionByBlockHashAndIndex get transaction based on its ID in a block designed by its hash.
+// The lookup is pinned to the block so it can't return a transaction of a block reorganized away.
+func (c *CustomClient) TransactionByBlockHashAndIndex(ctx context.Context, hash string, index uint64) (*eth.Transaction, error) {
+	h, err := eth.NewHash(hash)
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidParams, "invalid hash: %s", err)
+	}
+	i := eth.QuantityFromUInt64(index)
 	request := jsonrpc.Request{
 		ID:     jsonrpc.ID{Num: 1},
-		Method: "eth_getBalance",
-		Params: jsonrpc.MustParams(address, "latest"),
+		Method: "eth_getTransactionByBlockHashAndIndex",
+		Params: jsonrpc.MustParams(h, &i),
 	}
 
 	response, err := c.Request(ctx, &request)
 	if err != nil {
-		return 0, errors.Wrap(err, "could not make  request")
+		return nil, errors.Wrap(err, "could not make  request")
 	}
 
 	if response.Error != nil {
-		return 0, errors.New(string(*response.Error))
+		return nil, parseRPCError(*response.Error)
+	}
+
+	if len(response.Result) == 0 || bytes.Equal(response.Result, json.RawMessage(`null`)) {
+		// Then the block or the transaction isn't recognized
+		return nil, node.ErrTransactionNotFound
 	}
 
-	tx := eth.Quantity{}
+	tx := eth.Transaction{}
 	err = tx.UnmarshalJSON(response.Result)
-	return tx.UInt64(), err
+	return &tx, err
 }
 
-// GetGasPrice get current gas price
-func (c *CustomClient) GetGasPrice(ctx context.Context) (uint64, error) {
+// GetBalance balance in wei of an address from the state of the given block
+func (c *CustomClient) GetBalance(ctx context.Context, address string, block BlockParam) (*big.Int, error) {
+	request := jsonrpc.Request{
+		ID:     jsonrpc.ID{Num: 1},
+		Method: "eth_getBalance",
+		Params: jsonrpc.MustParams(address, block),
+	}
+
+	response, err := c.Request(ctx, &request)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not make  request")
+	}
+
+	if response.Error != nil {
+		return nil, parseRPCError(*response.Error)
+	}
+
+	q := eth.Quantity{}
+	err = q.UnmarshalJSON(response.Result)
+	if err != nil {
+		return nil, err
+	}
+	return q.Big(), nil
+}
+
+// GetGasPrice get current gas price in wei
+func (c *CustomClient) GetGasPrice(ctx context.Context) (*big.Int, error) {
 	request := jsonrpc.Request{
 		ID:     jsonrpc.ID{Num: 1},
 		Method: "eth_gasPrice",
@@ -92,24 +256,28 @@ func (c *CustomClient) GetGasPrice(ctx context.Context) (uint64, error) {
 
 	response, err := c.Request(ctx, &request)
 	if err != nil {
-		return 0, errors.Wrap(err, "could not make  request")
+		return nil, errors.Wrap(err, "could not make  request")
 	}
 
 	if response.Error != nil {
-		return 0, errors.New(string(*response.Error))
+		return nil, parseRPCError(*response.Error)
 	}
 
-	tx := eth.Quantity{}
-	err = tx.UnmarshalJSON(response.Result)
-	return tx.UInt64(), err
+	q := eth.Quantity{}
+	err = q.UnmarshalJSON(response.Result)
+	if err != nil {
+		return nil, err
+	}
+	return q.Big(), nil
 }
 
-// CallContract from latest state
-func (c *CustomClient) CallContract(ctx context.Context, param CallParams) (string, error) {
+// CallContract from the state of the given block
+// It returns a RevertError with the decoded reason if the execution reverted.
+func (c *CustomClient) CallContract(ctx context.Context, param CallParams, block BlockParam) (string, error) {
 	request := jsonrpc.Request{
 		ID:     jsonrpc.ID{Num: 1},
 		Method: "eth_call",
-		Params: jsonrpc.MustParams(&param, "latest"),
+		Params: jsonrpc.MustParams(&param, block),
 	}
 
 	response, err := c.Request(ctx, &request)
@@ -118,10 +286,316 @@ func (c *CustomClient) CallContract(ctx context.Context, param CallParams) (stri
 	}
 
 	if response.Error != nil {
-		return "", errors.New(string(*response.Error))
+		return "", parseExecutionError(*response.Error)
 	}
 
 	var tx eth.Data
 	err = tx.UnmarshalJSON(response.Result)
 	return string(tx), err
 }
+
+// Receipt is a transaction receipt including the fields added by EIP-1559
+type Receipt struct {
+	eth.TransactionReceipt
+	EffectiveGasPrice *eth.Quantity `json:"effectiveGasPrice,omitempty"`
+	Type              *eth.Quantity `json:"type,omitempty"`
+}
+
+// Fee returns the total fee paid in wei
+func (r *Receipt) Fee() *big.Int {
+	if r.EffectiveGasPrice == nil {
+		return nil
+	}
+	return new(big.Int).Mul(r.GasUsed.Big(), r.EffectiveGasPrice.Big())
+}
+
+// GetTransactionReceipt get the receipt of a mined transaction.
+// Receipts from before EIP-1559 have no effective gas price, it is then read from the transaction in the same round trip.
+func (c *CustomClient) GetTransactionReceipt(ctx context.Context, hash string) (*Receipt, error) {
+	h, err := eth.NewHash(hash)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid hash")
+	}
+
+	var receipt *Receipt
+	var tx *eth.Transaction
+	batch := []BatchElem{
+		{Method: "eth_getTransactionReceipt", Params: []interface{}{h}, Result: &receipt},
+		{Method: "eth_getTransactionByHash", Params: []interface{}{h}, Result: &tx},
+	}
+	if err := c.BatchCall(ctx, batch); err != nil {
+		return nil, err
+	}
+	if batch[0].Error != nil {
+		return nil, batch[0].Error
+	}
+	if receipt == nil {
+		// the transaction is unknown or still pending
+		return nil, node.ErrTransactionNotFound
+	}
+
+	if receipt.EffectiveGasPrice == nil && batch[1].Error == nil && tx != nil {
+		receipt.EffectiveGasPrice = &tx.GasPrice
+	}
+	return receipt, nil
+}
+
+// Account is the state of an address at a given block
+type Account struct {
+	Balance *big.Int
+	Nonce   uint64
+	Code    eth.Data
+}
+
+// GetTransactionCount number of transactions sent from an address at the given block i.e. its nonce
+func (c *CustomClient) GetTransactionCount(ctx context.Context, address string, block BlockParam) (uint64, error) {
+	request := jsonrpc.Request{
+		ID:     jsonrpc.ID{Num: 1},
+		Method: "eth_getTransactionCount",
+		Params: jsonrpc.MustParams(address, block),
+	}
+
+	response, err := c.Request(ctx, &request)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not make  request")
+	}
+
+	if response.Error != nil {
+		return 0, parseRPCError(*response.Error)
+	}
+
+	q := eth.Quantity{}
+	err = q.UnmarshalJSON(response.Result)
+	return q.UInt64(), err
+}
+
+// GetCode code of the contract deployed at an address at the given block, it is empty for an externally owned account
+func (c *CustomClient) GetCode(ctx context.Context, address string, block BlockParam) (eth.Data, error) {
+	request := jsonrpc.Request{
+		ID:     jsonrpc.ID{Num: 1},
+		Method: "eth_getCode",
+		Params: jsonrpc.MustParams(address, block),
+	}
+
+	response, err := c.Request(ctx, &request)
+	if err != nil {
+		return "", errors.Wrap(err, "could not make  request")
+	}
+
+	if response.Error != nil {
+		return "", parseRPCError(*response.Error)
+	}
+
+	var code eth.Data
+	err = code.UnmarshalJSON(response.Result)
+	return code, err
+}
+
+// GetStorageAt value of a storage slot of an address at the given block
+func (c *CustomClient) GetStorageAt(ctx context.Context, address string, slot eth.Quantity, block BlockParam) (eth.Data, error) {
+	request := jsonrpc.Request{
+		ID:     jsonrpc.ID{Num: 1},
+		Method: "eth_getStorageAt",
+		Params: jsonrpc.MustParams(address, &slot, block),
+	}
+
+	response, err := c.Request(ctx, &request)
+	if err != nil {
+		return "", errors.Wrap(err, "could not make  request")
+	}
+
+	if response.Error != nil {
+		return "", parseRPCError(*response.Error)
+	}
+
+	var value eth.Data
+	err = value.UnmarshalJSON(response.Result)
+	return value, err
+}
+
+// GetAccount balance, nonce and code of an address at the given block in a single round trip
+func (c *CustomClient) GetAccount(ctx context.Context, address string, block BlockParam) (*Account, error) {
+	var balance eth.Quantity
+	var nonce eth.Quantity
+	var code eth.Data
+	batch := []BatchElem{
+		{Method: "eth_getBalance", Params: []interface{}{address, block}, Result: &balance},
+		{Method: "eth_getTransactionCount", Params: []interface{}{address, block}, Result: &nonce},
+		{Method: "eth_getCode", Params: []interface{}{address, block}, Result: &code},
+	}
+	if err := c.BatchCall(ctx, batch); err != nil {
+		return nil, err
+	}
+	for _, e := range batch {
+		if e.Error != nil {
+			return nil, errors.Wrapf(e.Error, "%s failed", e.Method)
+		}
+	}
+
+	return &Account{
+		Balance: balance.Big(),
+		Nonce:   nonce.UInt64(),
+		Code:    code,
+	}, nil
+}
+
+// AccessTuple is an address and the storage keys a transaction accesses
+type AccessTuple struct {
+	Address     eth.Address `json:"address"`
+	StorageKeys []eth.Hash  `json:"storageKeys"`
+}
+
+// AccessList is the result of eth_createAccessList
+type AccessList struct {
+	AccessList []AccessTuple `json:"accessList"`
+	GasUsed    eth.Quantity  `json:"gasUsed"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// EstimateGas estimates the gas needed by a call at the given block.
+// It returns a RevertError with the decoded reason if the execution reverted.
+func (c *CustomClient) EstimateGas(ctx context.Context, param CallParams, block BlockParam) (uint64, error) {
+	request := jsonrpc.Request{
+		ID:     jsonrpc.ID{Num: 1},
+		Method: "eth_estimateGas",
+		Params: jsonrpc.MustParams(&param, block),
+	}
+
+	response, err := c.Request(ctx, &request)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not make  request")
+	}
+
+	if response.Error != nil {
+		return 0, parseExecutionError(*response.Error)
+	}
+
+	q := eth.Quantity{}
+	err = q.UnmarshalJSON(response.Result)
+	return q.UInt64(), err
+}
+
+// CreateAccessList generates the access list of a call at the given block.
+// It returns a RevertError with the decoded reason if the execution reverted.
+func (c *CustomClient) CreateAccessList(ctx context.Context, param CallParams, block BlockParam) (*AccessList, error) {
+	request := jsonrpc.Request{
+		ID:     jsonrpc.ID{Num: 1},
+		Method: "eth_createAccessList",
+		Params: jsonrpc.MustParams(&param, block),
+	}
+
+	response, err := c.Request(ctx, &request)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not make  request")
+	}
+
+	if response.Error != nil {
+		return nil, parseExecutionError(*response.Error)
+	}
+
+	list := AccessList{}
+	err = json.Unmarshal(response.Result, &list)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode access list")
+	}
+	if list.Error != "" {
+		// geth reports a reverted execution in the result
+		return nil, &RevertError{Message: list.Error, Reason: strings.TrimPrefix(strings.TrimPrefix(list.Error, "execution reverted"), ": ")}
+	}
+	return &list, nil
+}
+
+// Errors returned by the node when it rejects a transaction
+var (
+	ErrNonceTooLow            = errors.New("nonce too low")
+	ErrUnderpriced            = errors.New("transaction underpriced")
+	ErrReplacementUnderpriced = errors.New("replacement transaction underpriced")
+	ErrAlreadyKnown           = errors.New("already known")
+)
+
+// TxRejectedError is returned when the node rejects a transaction
+type TxRejectedError struct {
+	// Reason is one of ErrNonceTooLow, ErrUnderpriced, ErrReplacementUnderpriced, ErrAlreadyKnown or nil
+	Reason error
+	// Code is the JSON-RPC error code
+	Code int
+	// Message is the error message of the node
+	Message string
+}
+
+func (e *TxRejectedError) Error() string {
+	return e.Message
+}
+
+// ChainID get the chain ID used to sign replay protected transactions
+func (c *CustomClient) ChainID(ctx context.Context) (*big.Int, error) {
+	request := jsonrpc.Request{
+		ID:     jsonrpc.ID{Num: 1},
+		Method: "eth_chainId",
+	}
+
+	response, err := c.Request(ctx, &request)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not make  request")
+	}
+
+	if response.Error != nil {
+		return nil, parseRPCError(*response.Error)
+	}
+
+	q := eth.Quantity{}
+	err = q.UnmarshalJSON(response.Result)
+	if err != nil {
+		return nil, err
+	}
+	return q.Big(), nil
+}
+
+// SendRawTransaction broadcasts a signed transaction and returns its hash.
+// It returns a TxRejectedError if the node rejects the transaction.
+func (c *CustomClient) SendRawTransaction(ctx context.Context, raw string) (eth.Hash, error) {
+	request := jsonrpc.Request{
+		ID:     jsonrpc.ID{Num: 1},
+		Method: "eth_sendRawTransaction",
+		Params: jsonrpc.MustParams(raw),
+	}
+
+	response, err := c.Request(ctx, &request)
+	if err != nil {
+		return "", errors.Wrap(err, "could not make  request")
+	}
+
+	if response.Error != nil {
+		return "", parseTxRejectedError(*response.Error)
+	}
+
+	var hash eth.Hash
+	err = hash.UnmarshalJSON(response.Result)
+	return hash, err
+}
+
+// parseTxRejectedError classifies the reason why the node rejected a transaction from its message.
+// Errors that are not a rejection, like rate limits, are returned as an RPCError.
+func parseTxRejectedError(raw json.RawMessage) error {
+	err := parseRPCError(raw)
+	rpcErr := err.(*RPCError)
+	switch rpcErr.Kind {
+	case ErrRateLimited, ErrTimeout, ErrUpstreamUnavailable:
+		return err
+	}
+
+	e := &TxRejectedError{Code: rpcErr.Code, Message: rpcErr.Message}
+	msg := strings.ToLower(rpcErr.Message)
+	// the replacement check comes first as its message contains the underpriced one
+	for _, reason := range []error{ErrNonceTooLow, ErrReplacementUnderpriced, ErrUnderpriced, ErrAlreadyKnown} {
+		if strings.Contains(msg, reason.Error()) {
+			e.Reason = reason
+			break
+		}
+	}
+	if e.Reason == nil && strings.Contains(msg, "known transaction") {
+		// message of older geth and parity versions
+		e.Reason = ErrAlreadyKnown
+	}
+	return e
+}
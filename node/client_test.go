@@ -0,0 +1,58 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"github.com/INFURA/go-ethlibs/node"
+)
+
+func TestTransactionByBlockHashAndIndex(t *testing.T) {
+	const hash = "0xafddcde383196ecacf07def76a2572c86d1f992081cb1023c8decef92b7712ee"
+	tx := `{"blockHash":"` + hash + `","blockNumber":"0x8b64a3","hash":"0x37e458fcff2a79f32257776aa67f929187d2ff1f8868092bead0b788d248b9b4","transactionIndex":"0x1","from":"0x5cf2cbfd110e7ce39fb353d123776ab683ef9feb","gas":"0x5208","gasPrice":"0x1","input":"0x","nonce":"0x0","value":"0x0","v":"0x1b","r":"0x1","s":"0x1"}`
+	tt := []struct {
+		name   string
+		hash   string
+		result string
+		rpcErr string
+		kind   error
+	}{
+		{"found", hash, tx, "", nil},
+		{"not found", hash, `null`, "", ErrNotFound},
+		{"invalid hash", "0x1234", "", "", ErrInvalidParams},
+		{"rate limited", hash, "", `{"code":-32005,"message":"request rate limited"}`, ErrRateLimited},
+		{"timeout", hash, "", `{"code":-32000,"message":"request timed out"}`, ErrTimeout},
+		{"internal error", hash, "", `{"code":-32603,"message":"internal error"}`, ErrUpstreamUnavailable},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests int
+			client, err := node.NewCustomClient(requesterFunc(func(r *jsonrpc.Request) (json.RawMessage, *json.RawMessage) {
+				requests++
+				if tc.rpcErr != "" {
+					e := json.RawMessage(tc.rpcErr)
+					return nil, &e
+				}
+				return json.RawMessage(tc.result), nil
+			}), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := CustomClient{Client: client}
+
+			got, err := c.TransactionByBlockHashAndIndex(context.Background(), tc.hash, 1)
+			if kind := Classify(err); kind != tc.kind {
+				t.Fatalf("got err %v kind %v want %v", err, kind, tc.kind)
+			}
+			if tc.kind == nil && (got == nil || *got.BlockHash != hash) {
+				t.Errorf("got transaction %v", got)
+			}
+			if tc.kind == ErrInvalidParams && requests != 0 {
+				t.Errorf("an invalid hash reached the node")
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+)
+
+// uncoalescable are the methods whose identical calls must all reach the node, they change its state
+var uncoalescable = map[string]bool{
+	"eth_sendRawTransaction": true,
+	"eth_sendTransaction":    true,
+	"eth_newFilter":          true,
+	"eth_newBlockFilter":     true,
+	"eth_getFilterChanges":   true,
+	"eth_uninstallFilter":    true,
+	"eth_subscribe":          true,
+	"eth_unsubscribe":        true,
+}
+
+// coalescer shares the result of a call with the identical calls made while it is in flight
+type coalescer struct {
+	mu    sync.Mutex
+	calls map[string]*call
+	// coalesced is the number of calls that didn't reach the node because an identical one was in flight
+	coalesced uint64
+}
+
+// call is a call in flight, done is closed once res and err are set
+type call struct {
+	done    chan struct{}
+	res     interface{}
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func newCoalescer() *coalescer {
+	return &coalescer{calls: make(map[string]*call)}
+}
+
+// do runs fn once for the concurrent calls with the same key and returns its result to all of them.
+// fn gets a context cancelled when every caller gave up, so a caller leaving doesn't fail the others.
+func (c *coalescer) do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	cl, ok := c.calls[key]
+	if ok {
+		cl.waiters++
+		atomic.AddUint64(&c.coalesced, 1)
+	} else {
+		callCtx, cancel := context.WithCancel(context.Background())
+		cl = &call{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		c.calls[key] = cl
+		go func() {
+			cl.res, cl.err = fn(callCtx)
+			c.mu.Lock()
+			if c.calls[key] == cl {
+				delete(c.calls, key)
+			}
+			c.mu.Unlock()
+			cancel()
+			close(cl.done)
+		}()
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-cl.done:
+		return cl.res, cl.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		cl.waiters--
+		if cl.waiters == 0 && c.calls[key] == cl {
+			// nobody waits for the result anymore, a new call will be made for the next caller
+			delete(c.calls, key)
+			cl.cancel()
+		}
+		c.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// requestKey identifies the requests with the same method and params, ok is false if they can't be coalesced
+func requestKey(requests ...*jsonrpc.Request) (key string, ok bool) {
+	var buf bytes.Buffer
+	for _, r := range requests {
+		if uncoalescable[r.Method] {
+			return "", false
+		}
+		params, err := json.Marshal(r.Params)
+		if err != nil {
+			return "", false
+		}
+		buf.WriteString(r.Method)
+		buf.Write(params)
+		buf.WriteByte('\n')
+	}
+	return buf.String(), true
+}
+
+// batchResult is the result of a shared batch, the responses have the IDs of its requests
+type batchResult struct {
+	requests  []*jsonrpc.Request
+	responses []*jsonrpc.RawResponse
+}
+
+// forRequests returns copies of the responses of a shared batch with the IDs of identical requests
+func (b batchResult) forRequests(requests []*jsonrpc.Request) []*jsonrpc.RawResponse {
+	ids := make(map[jsonrpc.ID]jsonrpc.ID, len(requests))
+	for i, r := range b.requests {
+		ids[r.ID] = requests[i].ID
+	}
+	ret := make([]*jsonrpc.RawResponse, len(b.responses))
+	for i, res := range b.responses {
+		if res == nil {
+			continue
+		}
+		cp := *res
+		if id, ok := ids[res.ID]; ok {
+			cp.ID = id
+		}
+		ret[i] = &cp
+	}
+	return ret
+}
+
+// withID returns a copy of a shared response with the ID of the request
+func withID(res *jsonrpc.RawResponse, id jsonrpc.ID) *jsonrpc.RawResponse {
+	if res == nil {
+		return nil
+	}
+	cp := *res
+	cp.ID = id
+	return &cp
+}
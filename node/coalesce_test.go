@@ -0,0 +1,128 @@
+package node
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"github.com/INFURA/infra-test-benjamin-mateo/node/nodetest"
+	"go.uber.org/zap"
+)
+
+func TestPoolCoalesce(t *testing.T) {
+	srv := nodetest.NewServer(nil)
+	defer srv.Close()
+	pool, err := NewPool(PoolConfig{URLs: []string{srv.URL}, HealthInterval: time.Hour}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	client, err := GetNewPooledClient(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the node holds the gas price requests until released
+	release := make(chan struct{})
+	srv.Handle("eth_gasPrice", func(jsonrpc.Params) (interface{}, error) {
+		<-release
+		return "0x3b9aca00", nil
+	})
+	waitCoalesced := func(n uint64) {
+		deadline := time.Now().Add(5 * time.Second)
+		for pool.Coalesced() < n {
+			if time.Now().After(deadline) {
+				t.Fatalf("got %d coalesced requests want %d", pool.Coalesced(), n)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	const callers = 10
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	errs := make([]error, callers)
+	call := func(i int, ctx context.Context) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			price, err := client.GetGasPrice(ctx)
+			if err == nil && price.Uint64() != 1000000000 {
+				t.Errorf("got gas price %v", price)
+			}
+			errs[i] = err
+		}()
+	}
+	// the first caller sends the request, the others wait for its response
+	call(0, leaderCtx)
+	for srv.Calls("eth_gasPrice") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 1; i < callers; i++ {
+		call(i, context.Background())
+	}
+	waitCoalesced(callers - 1)
+	// the caller that sent the request leaving doesn't fail the others
+	cancelLeader()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if errs[0] == nil {
+		t.Error("cancelled caller should fail")
+	}
+	for i, err := range errs[1:] {
+		if err != nil {
+			t.Errorf("caller %d: %v", i+1, err)
+		}
+	}
+	if n := srv.Calls("eth_gasPrice"); n != 1 {
+		t.Errorf("got %d requests to the node want 1", n)
+	}
+
+	// calls that are not in flight anymore are sent again, so are the ones with different params
+	if _, err := client.GetGasPrice(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.BlockByNumber(context.Background(), nodetest.FirstBlock, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.BlockByNumber(context.Background(), nodetest.FirstBlock+1, false); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.Calls("eth_gasPrice"); n != 2 {
+		t.Errorf("got %d requests to the node want 2", n)
+	}
+	if n := srv.Calls("eth_getBlockByNumber"); n != 2 {
+		t.Errorf("got %d block requests to the node want 2", n)
+	}
+	if n := pool.Coalesced(); n != callers-1 {
+		t.Errorf("got %d coalesced requests want %d", n, callers-1)
+	}
+}
+
+func TestRequestKey(t *testing.T) {
+	a := &jsonrpc.Request{ID: jsonrpc.ID{Num: 1}, Method: "eth_getBlockByNumber", Params: jsonrpc.MustParams("0x1", false)}
+	b := &jsonrpc.Request{ID: jsonrpc.ID{Num: 2}, Method: "eth_getBlockByNumber", Params: jsonrpc.MustParams("0x1", false)}
+	c := &jsonrpc.Request{ID: jsonrpc.ID{Num: 3}, Method: "eth_getBlockByNumber", Params: jsonrpc.MustParams("0x1", true)}
+	keyA, _ := requestKey(a)
+	keyB, _ := requestKey(b)
+	keyC, _ := requestKey(c)
+	if keyA != keyB || keyA == keyC {
+		t.Errorf("keys %q %q %q", keyA, keyB, keyC)
+	}
+	if _, ok := requestKey(a, &jsonrpc.Request{Method: "eth_sendRawTransaction", Params: jsonrpc.MustParams("0x12")}); ok {
+		t.Error("a transaction broadcast should not be coalesced")
+	}
+
+	shared := batchResult{
+		requests:  []*jsonrpc.Request{a, c},
+		responses: []*jsonrpc.RawResponse{{ID: c.ID}, {ID: a.ID}},
+	}
+	got := shared.forRequests([]*jsonrpc.Request{{ID: jsonrpc.ID{Num: 10}}, {ID: jsonrpc.ID{Num: 30}}})
+	if got[0].ID.Num != 30 || got[1].ID.Num != 10 || shared.responses[0].ID.Num != 3 {
+		t.Errorf("responses not matched to the requests: %+v", got)
+	}
+}
@@ -0,0 +1,120 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+
+	"github.com/INFURA/go-ethlibs/node"
+	"github.com/pkg/errors"
+)
+
+// Kinds of errors returned by the client, Classify returns the kind of an error
+var (
+	ErrNotFound            = errors.New("not found")
+	ErrExecutionReverted   = errors.New("execution reverted")
+	ErrRateLimited         = errors.New("rate limited")
+	ErrInvalidParams       = errors.New("invalid params")
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+	ErrTimeout             = errors.New("timeout")
+)
+
+// JSON-RPC error codes
+const (
+	codeReverted      = 3
+	codeLimitExceeded = -32005
+	codeInvalidParams = -32602
+	codeInternal      = -32603
+)
+
+// RPCError is an error returned by the node, it keeps the JSON-RPC code and data
+type RPCError struct {
+	Code    int
+	Message string
+	Data    json.RawMessage
+	// Kind is one of the kinds of errors, nil if the error can't be classified
+	Kind error
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// parseRPCError decodes the JSON-RPC error of a response and classifies it
+func parseRPCError(raw json.RawMessage) error {
+	rpcErr := struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}{}
+	if err := json.Unmarshal(raw, &rpcErr); err != nil || rpcErr.Message == "" {
+		return &RPCError{Message: string(raw)}
+	}
+	e := &RPCError{Code: rpcErr.Code, Message: rpcErr.Message, Data: rpcErr.Data}
+	e.Kind = classifyRPCError(e.Code, e.Message)
+	return e
+}
+
+// typedError turns a JSON-RPC error returned as plain text into an RPCError
+func typedError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if msg := err.Error(); strings.HasPrefix(msg, "{") {
+		return parseRPCError(json.RawMessage(msg))
+	}
+	return err
+}
+
+// classifyRPCError returns the kind of a JSON-RPC error.
+// Nodes don't agree on the codes besides the ones of the specification, the message is checked as well.
+func classifyRPCError(code int, message string) error {
+	msg := strings.ToLower(message)
+	switch {
+	case code == codeReverted || strings.Contains(msg, "revert"):
+		return ErrExecutionReverted
+	case strings.Contains(msg, "timeout") || strings.Contains(msg, "timed out"):
+		return ErrTimeout
+	case code == codeLimitExceeded || strings.Contains(msg, "rate limit") || strings.Contains(msg, "too many requests"):
+		return ErrRateLimited
+	case code == codeInvalidParams || strings.HasPrefix(msg, "invalid argument"):
+		return ErrInvalidParams
+	case strings.Contains(msg, "not found") || strings.Contains(msg, "unknown block"):
+		return ErrNotFound
+	case code == codeInternal:
+		return ErrUpstreamUnavailable
+	}
+	return nil
+}
+
+// Classify returns the kind of an error returned by the client, nil if it can't be classified
+func Classify(err error) error {
+	switch e := errors.Cause(err).(type) {
+	case nil:
+		return nil
+	case *RPCError:
+		return e.Kind
+	case *RevertError:
+		return ErrExecutionReverted
+	case *RateLimitError:
+		return ErrRateLimited
+	case net.Error:
+		if e.Timeout() {
+			return ErrTimeout
+		}
+		return ErrUpstreamUnavailable
+	}
+
+	switch cause := errors.Cause(err); cause {
+	case ErrNotFound, ErrExecutionReverted, ErrRateLimited, ErrInvalidParams, ErrUpstreamUnavailable, ErrTimeout:
+		return cause
+	case node.ErrBlockNotFound, node.ErrTransactionNotFound:
+		return ErrNotFound
+	case ErrNoUpstream:
+		return ErrUpstreamUnavailable
+	case context.DeadlineExceeded:
+		return ErrTimeout
+	}
+	return nil
+}
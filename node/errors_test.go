@@ -0,0 +1,77 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/INFURA/go-ethlibs/node"
+	"github.com/pkg/errors"
+)
+
+func TestParseRPCError(t *testing.T) {
+	tt := []struct {
+		rpcError string
+		code     int
+		kind     error
+	}{
+		{`{"code":3,"message":"execution reverted","data":"0xdeadbeef"}`, 3, ErrExecutionReverted},
+		{`{"code":-32000,"message":"execution reverted: not enough funds"}`, -32000, ErrExecutionReverted},
+		{`{"code":-32000,"message":"execution aborted (timeout = 5s)"}`, -32000, ErrTimeout},
+		{`{"code":-32005,"message":"daily request count exceeded, request rate limited"}`, -32005, ErrRateLimited},
+		{`{"code":429,"message":"Too Many Requests"}`, 429, ErrRateLimited},
+		{`{"code":-32602,"message":"invalid argument 0: hex string without 0x prefix"}`, -32602, ErrInvalidParams},
+		{`{"code":-32000,"message":"header not found"}`, -32000, ErrNotFound},
+		{`{"code":-32000,"message":"unknown block"}`, -32000, ErrNotFound},
+		{`{"code":-32603,"message":"internal error"}`, -32603, ErrUpstreamUnavailable},
+		{`{"code":-32601,"message":"the method does not exist/is not available"}`, -32601, nil},
+		{`"not an error object"`, 0, nil},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.rpcError, func(t *testing.T) {
+			err := parseRPCError(json.RawMessage(tc.rpcError))
+			rpcErr, ok := err.(*RPCError)
+			if !ok {
+				t.Fatalf("got %T want *RPCError", err)
+			}
+			if rpcErr.Code != tc.code || rpcErr.Kind != tc.kind {
+				t.Errorf("got code %d kind %v want %d %v", rpcErr.Code, rpcErr.Kind, tc.code, tc.kind)
+			}
+			if got := Classify(errors.Wrap(err, "could not get block")); got != tc.kind {
+				t.Errorf("wrapped: got %v want %v", got, tc.kind)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	refused := &url.Error{Op: "Post", URL: "http://localhost:8545", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}
+
+	tt := []struct {
+		name string
+		err  error
+		kind error
+	}{
+		{"revert", &RevertError{Code: 3, Message: "execution reverted"}, ErrExecutionReverted},
+		{"block not found", node.ErrBlockNotFound, ErrNotFound},
+		{"transaction not found", errors.Wrap(node.ErrTransactionNotFound, "could not get receipt"), ErrNotFound},
+		{"no upstream", ErrNoUpstream, ErrUpstreamUnavailable},
+		{"connection refused", errors.Wrap(refused, "error in client.Do"), ErrUpstreamUnavailable},
+		{"deadline", errors.Wrap(context.DeadlineExceeded, "could not make  request"), ErrTimeout},
+		{"kind", errors.Wrap(ErrRateLimited, "upstream"), ErrRateLimited},
+		{"go-ethlibs plain text", typedError(errors.New(`{"code":-32000,"message":"header not found"}`)), ErrNotFound},
+		{"unknown", errors.New("could not decode result"), nil},
+		{"nil", nil, nil},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Classify(tc.err); got != tc.kind {
+				t.Errorf("got %v want %v", got, tc.kind)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"sort"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"github.com/pkg/errors"
+)
+
+// MaxFeeHistoryBlocks is the largest number of blocks a node returns in a fee history
+const MaxFeeHistoryBlocks = 1024
+
+// FeePercentiles are the reward percentiles of the slow, standard and fast suggestions
+var FeePercentiles = []float64{10, 50, 90}
+
+// feeHeadroom is the number of full blocks the base fee of a suggestion can absorb, each one raises the base fee by 12.5%
+var feeHeadroom = []int{1, 3, 6}
+
+// FeeHistory is the result of eth_feeHistory
+type FeeHistory struct {
+	OldestBlock eth.Quantity `json:"oldestBlock"`
+	// BaseFeePerGas has one more entry than the blocks requested: the base fee of the next block
+	BaseFeePerGas []eth.Quantity   `json:"baseFeePerGas"`
+	GasUsedRatio  []float64        `json:"gasUsedRatio"`
+	Reward        [][]eth.Quantity `json:"reward,omitempty"`
+}
+
+// Fee is a fee suggestion for an EIP-1559 transaction
+type Fee struct {
+	MaxFeePerGas         *big.Int
+	MaxPriorityFeePerGas *big.Int
+}
+
+// FeeSuggestion is computed from the fee history of the recent blocks
+type FeeSuggestion struct {
+	OldestBlock       uint64
+	Blocks            int
+	BaseFeePerGas     *big.Int
+	NextBaseFeePerGas *big.Int
+	Slow              Fee
+	Standard          Fee
+	Fast              Fee
+}
+
+// FeeHistory get the base fees, gas used ratios and reward percentiles of blockCount blocks up to newest
+func (c *CustomClient) FeeHistory(ctx context.Context, blockCount uint64, newest BlockParam, percentiles []float64) (*FeeHistory, error) {
+	n := eth.QuantityFromUInt64(blockCount)
+	request := jsonrpc.Request{
+		ID:     jsonrpc.ID{Num: 1},
+		Method: "eth_feeHistory",
+		Params: jsonrpc.MustParams(&n, newest, percentiles),
+	}
+
+	response, err := c.Request(ctx, &request)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not make  request")
+	}
+
+	if response.Error != nil {
+		return nil, parseRPCError(*response.Error)
+	}
+
+	h := FeeHistory{}
+	if err := json.Unmarshal(response.Result, &h); err != nil {
+		return nil, errors.Wrap(err, "could not decode fee history")
+	}
+	return &h, nil
+}
+
+// MaxPriorityFeePerGas get the priority fee suggested by the node in wei
+func (c *CustomClient) MaxPriorityFeePerGas(ctx context.Context) (*big.Int, error) {
+	request := jsonrpc.Request{
+		ID:     jsonrpc.ID{Num: 1},
+		Method: "eth_maxPriorityFeePerGas",
+	}
+
+	response, err := c.Request(ctx, &request)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not make  request")
+	}
+
+	if response.Error != nil {
+		return nil, parseRPCError(*response.Error)
+	}
+
+	q := eth.Quantity{}
+	err = q.UnmarshalJSON(response.Result)
+	if err != nil {
+		return nil, err
+	}
+	return q.Big(), nil
+}
+
+// SuggestFees requests the fee history of the last blocks and the priority fee of the node in a single round trip.
+// The priority fee of the node is used when the recent blocks give no reward e.g. they are empty.
+func (c *CustomClient) SuggestFees(ctx context.Context, blocks int) (*FeeSuggestion, error) {
+	if blocks < 1 || blocks > MaxFeeHistoryBlocks {
+		return nil, errors.Errorf("the number of blocks must be between 1 and %d", MaxFeeHistoryBlocks)
+	}
+	var history *FeeHistory
+	var tip eth.Quantity
+	n := eth.QuantityFromUInt64(uint64(blocks))
+	batch := []BatchElem{
+		{Method: "eth_feeHistory", Params: []interface{}{&n, LatestBlock, FeePercentiles}, Result: &history},
+		{Method: "eth_maxPriorityFeePerGas", Result: &tip},
+	}
+	if err := c.BatchCall(ctx, batch); err != nil {
+		return nil, err
+	}
+	if batch[0].Error != nil {
+		return nil, batch[0].Error
+	}
+	if history == nil {
+		return nil, errors.New("no fee history")
+	}
+	var fallback *big.Int
+	if batch[1].Error == nil {
+		fallback = tip.Big()
+	}
+	return history.Suggest(fallback)
+}
+
+// Suggest computes the slow, standard and fast fees.
+// The priority fee is the median over the blocks of the reward percentile of the speed,
+// the max fee adds the priority fee to the next base fee raised by the headroom of the speed.
+func (h *FeeHistory) Suggest(fallbackTip *big.Int) (*FeeSuggestion, error) {
+	n := len(h.BaseFeePerGas)
+	if n < 2 {
+		return nil, errors.New("no base fee in the fee history, the chain may not support EIP-1559")
+	}
+	s := &FeeSuggestion{
+		OldestBlock:       h.OldestBlock.UInt64(),
+		Blocks:            n - 1,
+		BaseFeePerGas:     h.BaseFeePerGas[n-2].Big(),
+		NextBaseFeePerGas: h.BaseFeePerGas[n-1].Big(),
+	}
+	fees := []*Fee{&s.Slow, &s.Standard, &s.Fast}
+	for i, f := range fees {
+		tip := h.medianReward(i)
+		if tip == nil {
+			tip = fallbackTip
+		}
+		if tip == nil {
+			tip = big.NewInt(0)
+		}
+		maxFee := new(big.Int).Set(s.NextBaseFeePerGas)
+		for j := 0; j < feeHeadroom[i]; j++ {
+			maxFee.Add(maxFee, new(big.Int).Rsh(maxFee, 3))
+		}
+		f.MaxPriorityFeePerGas = tip
+		f.MaxFeePerGas = maxFee.Add(maxFee, tip)
+	}
+	return s, nil
+}
+
+// medianReward is the median of the reward percentile over the blocks that were not empty, nil if there is none
+func (h *FeeHistory) medianReward(percentile int) *big.Int {
+	var rewards []*big.Int
+	for i, r := range h.Reward {
+		if percentile >= len(r) || (i < len(h.GasUsedRatio) && h.GasUsedRatio[i] == 0) {
+			continue
+		}
+		rewards = append(rewards, r[percentile].Big())
+	}
+	if len(rewards) == 0 {
+		return nil
+	}
+	sort.Slice(rewards, func(i, j int) bool { return rewards[i].Cmp(rewards[j]) < 0 })
+	return rewards[len(rewards)/2]
+}
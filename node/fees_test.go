@@ -0,0 +1,43 @@
+package node
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFeeHistorySuggest(t *testing.T) {
+	// the second block is empty, its rewards are ignored
+	raw := `{"oldestBlock":"0x10","baseFeePerGas":["0x64","0x64","0x64","0x80"],"gasUsedRatio":[0.5,0,0.9],
+		"reward":[["0x1","0x2","0x3"],["0x0","0x0","0x0"],["0x3","0x4","0x9"]]}`
+	h := FeeHistory{}
+	if err := json.Unmarshal([]byte(raw), &h); err != nil {
+		t.Fatal(err)
+	}
+	s, err := h.Suggest(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.OldestBlock != 16 || s.Blocks != 3 || s.BaseFeePerGas.Int64() != 100 || s.NextBaseFeePerGas.Int64() != 128 {
+		t.Errorf("unexpected suggestion %+v", s)
+	}
+
+	tt := []struct {
+		name        string
+		fee         Fee
+		tip, maxFee int64
+	}{
+		// 128 raised by 12.5% 1, 3 and 6 times
+		{"slow", s.Slow, 3, 144 + 3},
+		{"standard", s.Standard, 4, 182 + 4},
+		{"fast", s.Fast, 9, 257 + 9},
+	}
+	for _, tc := range tt {
+		if tc.fee.MaxPriorityFeePerGas.Int64() != tc.tip || tc.fee.MaxFeePerGas.Int64() != tc.maxFee {
+			t.Errorf("%s: got tip %s max fee %s want %d %d", tc.name, tc.fee.MaxPriorityFeePerGas, tc.fee.MaxFeePerGas, tc.tip, tc.maxFee)
+		}
+	}
+
+	if _, err := (&FeeHistory{}).Suggest(nil); err == nil {
+		t.Error("a history without base fee should fail")
+	}
+}
@@ -0,0 +1,299 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Types of HeadEvent
+const (
+	BlockEvent = "block"
+	ReorgEvent = "reorg"
+)
+
+// subscriberBuffer is the number of events a slow subscriber can lag behind before being dropped
+const subscriberBuffer = 64
+
+// BlockRef identifies a block
+type BlockRef struct {
+	Number uint64   `json:"number"`
+	Hash   eth.Hash `json:"hash"`
+}
+
+// Reorg describes the blocks removed from the canonical chain
+type Reorg struct {
+	Depth uint64 `json:"depth"`
+	// CommonAncestor is nil if the reorg is deeper than the blocks kept by the feed
+	CommonAncestor *BlockRef  `json:"commonAncestor"`
+	Removed        []BlockRef `json:"removed"`
+	NewHead        BlockRef   `json:"newHead"`
+}
+
+// HeadEvent is either a new canonical block or a reorg.
+// A reorg event is always followed by the block events of the new canonical chain.
+type HeadEvent struct {
+	ID    uint64
+	Type  string
+	Head  *eth.NewHeadsResult
+	Reorg *Reorg
+}
+
+// HeadFeedConfig configures a HeadFeed
+type HeadFeedConfig struct {
+	// PollInterval is the delay between two polls of the latest block when subscriptions are not available
+	PollInterval time.Duration
+	// History is the number of events kept to resume a subscription
+	History int
+	// Depth is the number of canonical blocks kept to detect reorgs
+	Depth int
+}
+
+// HeadFeed follows the new heads of the chain with a single upstream newHeads subscription,
+// or by polling when the client is not bidirectional, and fans them out to its subscribers.
+type HeadFeed struct {
+	client *CustomClient
+	cfg    HeadFeedConfig
+	logger *zap.SugaredLogger
+
+	// chain is only accessed by the Run goroutine
+	chain []*eth.NewHeadsResult
+
+	mu     sync.Mutex
+	seq    uint64
+	events []HeadEvent
+	subs   map[chan HeadEvent]struct{}
+}
+
+// NewHeadFeed creates a feed of new heads, it starts following the chain with Run
+func NewHeadFeed(client *CustomClient, cfg HeadFeedConfig, logger *zap.SugaredLogger) *HeadFeed {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 2 * time.Second
+	}
+	if cfg.History <= 0 {
+		cfg.History = 256
+	}
+	if cfg.Depth <= 0 {
+		cfg.Depth = 64
+	}
+	return &HeadFeed{
+		client: client,
+		cfg:    cfg,
+		logger: logger,
+		subs:   make(map[chan HeadEvent]struct{}),
+	}
+}
+
+// Run follows the chain until the context is done.
+// The upstream subscription is restarted after a poll if it fails.
+func (f *HeadFeed) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		if f.client.IsBidirectional() {
+			err := f.follow(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			f.logger.Warnf("newHeads subscription ended, polling err:%v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := f.poll(ctx); err != nil && ctx.Err() == nil {
+			f.logger.Warnf("can't poll latest block err:%s", err)
+		}
+	}
+}
+
+// Subscribe returns a channel of the new events, when resume is set the events kept after lastID are replayed first.
+// The channel is closed if the subscriber is too slow, it should then resume from the last event received.
+// cancel must be called once the subscriber is done.
+func (f *HeadFeed) Subscribe(resume bool, lastID uint64) (replay []HeadEvent, ch <-chan HeadEvent, cancel func()) {
+	c := make(chan HeadEvent, subscriberBuffer)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ev := range f.events {
+		if resume && ev.ID > lastID {
+			replay = append(replay, ev)
+		}
+	}
+	f.subs[c] = struct{}{}
+	return replay, c, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.subs[c]; ok {
+			delete(f.subs, c)
+			close(c)
+		}
+	}
+}
+
+// follow reads the heads from an upstream subscription until it fails or the context is done
+func (f *HeadFeed) follow(ctx context.Context) error {
+	sub, err := f.client.SubscribeNewHeads(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		uctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = sub.Unsubscribe(uctx)
+	}()
+	f.logger.Infof("following new heads with subscription %s", sub.ID())
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-sub.Done():
+			return sub.Err()
+		case n, ok := <-sub.Ch():
+			if !ok {
+				return errors.New("subscription closed")
+			}
+			p := eth.NewHeadsNotificationParams{}
+			if err := json.Unmarshal(n.Params, &p); err != nil {
+				f.logger.Warnf("can't decode new head err:%s", err)
+				continue
+			}
+			if err := f.onHead(ctx, &p.Result); err != nil {
+				f.logger.Warnf("can't process new head %s err:%s", p.Result.Hash, err)
+			}
+		}
+	}
+}
+
+// poll processes the latest block
+func (f *HeadFeed) poll(ctx context.Context) error {
+	b, err := f.client.BlockByNumberOrTag(ctx, *eth.MustBlockNumberOrTag(eth.TagLatest), false)
+	if err != nil {
+		return err
+	}
+	head := &eth.NewHeadsResult{}
+	head.FromBlock(b)
+	return f.onHead(ctx, head)
+}
+
+// onHead links the head to the known chain, fetching the missing parents, and emits the events.
+// The walk back stops at the common ancestor so a simple extension, a gap and a reorg are handled the same way.
+func (f *HeadFeed) onHead(ctx context.Context, head *eth.NewHeadsResult) error {
+	if known := f.find(head.Number.UInt64()); known != nil && known.Hash == head.Hash {
+		return nil
+	}
+	if len(f.chain) > 0 && head.Number.UInt64() < f.chain[0].Number.UInt64() {
+		// a lagging upstream, the head is older than the blocks we know
+		return nil
+	}
+
+	newBlocks := []*eth.NewHeadsResult{head}
+	var ancestor *eth.NewHeadsResult
+	if tip := f.tip(); tip != nil && head.Number.UInt64() <= tip.Number.UInt64()+uint64(f.cfg.Depth) {
+		cur := head
+		for len(newBlocks) <= f.cfg.Depth && cur.Number.UInt64() > 0 {
+			if p := f.find(cur.Number.UInt64() - 1); p != nil && p.Hash == cur.ParentHash {
+				ancestor = p
+				break
+			}
+			if cur.Number.UInt64()-1 < f.chain[0].Number.UInt64() {
+				// the new chain doesn't join the blocks we know
+				break
+			}
+			b, err := f.client.BlockByHash(ctx, string(cur.ParentHash), false)
+			if err != nil {
+				return errors.Wrap(err, "can't get parent block")
+			}
+			parent := &eth.NewHeadsResult{}
+			parent.FromBlock(b)
+			newBlocks = append([]*eth.NewHeadsResult{parent}, newBlocks...)
+			cur = parent
+		}
+	}
+
+	var removed []BlockRef
+	keep := 0
+	if ancestor != nil {
+		keep = int(ancestor.Number.UInt64()-f.chain[0].Number.UInt64()) + 1
+	}
+	for _, b := range f.chain[keep:] {
+		if ancestor == nil && b.Number.UInt64() < newBlocks[0].Number.UInt64() {
+			// older than the new blocks, not part of a reorg
+			continue
+		}
+		removed = append(removed, BlockRef{b.Number.UInt64(), b.Hash})
+	}
+	if ancestor == nil {
+		f.chain = nil
+	} else {
+		f.chain = f.chain[:keep]
+	}
+
+	if len(removed) > 0 {
+		reorg := &Reorg{
+			Depth:   uint64(len(removed)),
+			Removed: removed,
+			NewHead: BlockRef{head.Number.UInt64(), head.Hash},
+		}
+		if ancestor != nil {
+			reorg.CommonAncestor = &BlockRef{ancestor.Number.UInt64(), ancestor.Hash}
+		}
+		f.logger.Infof("reorg of depth %d new head:%d %s", reorg.Depth, reorg.NewHead.Number, reorg.NewHead.Hash)
+		f.emit(HeadEvent{Type: ReorgEvent, Reorg: reorg})
+	}
+
+	for _, b := range newBlocks {
+		f.chain = append(f.chain, b)
+		f.emit(HeadEvent{Type: BlockEvent, Head: b})
+	}
+	if len(f.chain) > f.cfg.Depth {
+		f.chain = f.chain[len(f.chain)-f.cfg.Depth:]
+	}
+	return nil
+}
+
+// tip is the last known canonical block
+func (f *HeadFeed) tip() *eth.NewHeadsResult {
+	if len(f.chain) == 0 {
+		return nil
+	}
+	return f.chain[len(f.chain)-1]
+}
+
+// find returns the known canonical block at the given height
+func (f *HeadFeed) find(number uint64) *eth.NewHeadsResult {
+	if len(f.chain) == 0 {
+		return nil
+	}
+	first := f.chain[0].Number.UInt64()
+	if number < first || number-first >= uint64(len(f.chain)) {
+		return nil
+	}
+	return f.chain[number-first]
+}
+
+// emit records the event for resumption and sends it to the subscribers, slow subscribers are dropped
+func (f *HeadFeed) emit(ev HeadEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq++
+	ev.ID = f.seq
+	f.events = append(f.events, ev)
+	if len(f.events) > f.cfg.History {
+		f.events = f.events[len(f.events)-f.cfg.History:]
+	}
+	for c := range f.subs {
+		select {
+		case c <- ev:
+		default:
+			delete(f.subs, c)
+			close(c)
+		}
+	}
+}
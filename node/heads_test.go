@@ -0,0 +1,101 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"github.com/INFURA/go-ethlibs/node"
+	"go.uber.org/zap"
+)
+
+// chainStub answers eth_getBlockByHash with the blocks it knows
+type chainStub map[eth.Hash]*eth.NewHeadsResult
+
+func (c chainStub) Request(ctx context.Context, r *jsonrpc.Request) (*jsonrpc.RawResponse, error) {
+	var hash eth.Hash
+	if err := json.Unmarshal(r.Params[0], &hash); err != nil {
+		return nil, err
+	}
+	h, ok := c[hash]
+	if !ok {
+		return &jsonrpc.RawResponse{ID: r.ID, Result: json.RawMessage(`null`)}, nil
+	}
+	b := fmt.Sprintf(`{"number":"%s","hash":"%s","parentHash":"%s","transactions":[],"uncles":[]}`, h.Number.String(), h.Hash, h.ParentHash)
+	return &jsonrpc.RawResponse{ID: r.ID, Result: json.RawMessage(b)}, nil
+}
+
+// add creates a block of the fork at the given height
+func (c chainStub) add(number uint64, fork int, parent *eth.NewHeadsResult) *eth.NewHeadsResult {
+	h := &eth.NewHeadsResult{
+		Number: eth.QuantityFromUInt64(number),
+		Hash:   eth.Hash(fmt.Sprintf("0x%062x%02x", number, fork)),
+	}
+	if parent != nil {
+		h.ParentHash = parent.Hash
+	}
+	c[h.Hash] = h
+	return h
+}
+
+func TestHeadFeed(t *testing.T) {
+	chain := chainStub{}
+	client, err := node.NewCustomClient(chain, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := CustomClient{Client: client}
+	feed := NewHeadFeed(&c, HeadFeedConfig{History: 100, Depth: 10}, zap.NewNop().Sugar())
+	_, events, cancel := feed.Subscribe(false, 0)
+	defer cancel()
+
+	a1 := chain.add(1, 0, nil)
+	a2 := chain.add(2, 0, a1)
+	a3 := chain.add(3, 0, a2)
+	a4 := chain.add(4, 0, a3)
+	b3 := chain.add(3, 1, a2)
+	b4 := chain.add(4, 1, b3)
+	b5 := chain.add(5, 1, b4)
+
+	tt := []struct {
+		name string
+		head *eth.NewHeadsResult
+		want []string
+	}{
+		{"first head", a1, []string{"block 1 00"}},
+		{"extension", a2, []string{"block 2 00"}},
+		{"duplicate", a2, nil},
+		{"gap filled", a4, []string{"block 3 00", "block 4 00"}},
+		{"lagging head ignored", a3, nil},
+		{"reorg", b5, []string{"reorg 2 00", "block 3 01", "block 4 01", "block 5 01"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := feed.onHead(context.Background(), tc.head); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for len(events) > 0 {
+				ev := <-events
+				if ev.Type == ReorgEvent {
+					got = append(got, fmt.Sprintf("reorg %d %s", ev.Reorg.Depth, ev.Reorg.CommonAncestor.Hash[64:]))
+				} else {
+					got = append(got, fmt.Sprintf("block %d %s", ev.Head.Number.UInt64(), ev.Head.Hash[64:]))
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("got events %v want %v", got, tc.want)
+			}
+		})
+	}
+
+	replay, _, cancel := feed.Subscribe(true, 4)
+	defer cancel()
+	if len(replay) != 4 || replay[0].Type != ReorgEvent {
+		t.Errorf("expected the reorg and its blocks to be replayed, got %d events", len(replay))
+	}
+}
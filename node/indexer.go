@@ -0,0 +1,139 @@
+package node
+
+import (
+	"context"
+	"time"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// receiptsPerBatch bounds the number of receipts requested in a single round trip
+const receiptsPerBatch = 100
+
+// IndexerConfig configures an Indexer
+type IndexerConfig struct {
+	// From is the first height indexed, when following the head the indexer resumes after the last block stored if it is higher
+	From uint64
+	// To is the last height indexed, 0 follows the head
+	To uint64
+	// Confirmations is the depth below the head of the blocks indexed so that they can't be reorganized anymore
+	Confirmations uint64
+	// PollInterval is the delay between two checks of the head once the indexer caught up
+	PollInterval time.Duration
+}
+
+// Indexer writes the final blocks, their transactions and their receipts to a Store
+type Indexer struct {
+	client *CustomClient
+	store  *Store
+	cfg    IndexerConfig
+	logger *zap.SugaredLogger
+}
+
+// NewIndexer creates an indexer, it starts with Run
+func NewIndexer(client *CustomClient, store *Store, cfg IndexerConfig, logger *zap.SugaredLogger) *Indexer {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 2 * time.Second
+	}
+	return &Indexer{client: client, store: store, cfg: cfg, logger: logger}
+}
+
+// Run indexes the blocks from From as they become final.
+// It returns once To is indexed, or when the context is done if it follows the head.
+// Failed requests are retried after PollInterval, an error is only returned if a block can't be stored.
+func (ix *Indexer) Run(ctx context.Context) error {
+	next := ix.cfg.From
+	if last, ok := ix.store.Last(); ok && ix.cfg.To == 0 && last+1 > next {
+		next = last + 1
+	}
+	if ix.cfg.To == 0 {
+		ix.logger.Infof("indexing from block %d then following the head", next)
+	} else {
+		ix.logger.Infof("indexing blocks %d to %d", next, ix.cfg.To)
+	}
+	for ix.cfg.To == 0 || next <= ix.cfg.To {
+		head, err := ix.client.BlockNumber(ctx)
+		for ; err == nil && next+ix.cfg.Confirmations <= head && (ix.cfg.To == 0 || next <= ix.cfg.To); next++ {
+			var b *eth.Block
+			var receipts []*Receipt
+			if b, receipts, err = ix.fetch(ctx, next); err != nil {
+				break
+			}
+			if err := ix.store.PutBlock(b, receipts); err != nil {
+				return errors.Wrapf(err, "can't store block %d", next)
+			}
+			if next%1000 == 0 {
+				ix.logger.Infof("indexed block %d", next)
+			}
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			ix.logger.Warnf("can't index block %d, retrying err:%s", next, err)
+		} else if ix.cfg.To != 0 && next > ix.cfg.To {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(ix.cfg.PollInterval):
+		}
+	}
+	ix.logger.Infof("indexed blocks up to %d", ix.cfg.To)
+	return nil
+}
+
+// fetch requests a full block and the receipts of its transactions
+func (ix *Indexer) fetch(ctx context.Context, number uint64) (*eth.Block, []*Receipt, error) {
+	b, err := ix.client.BlockByNumber(ctx, number, true)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can't get block %d", number)
+	}
+	receipts, err := ix.receipts(ctx, b)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, receipts, nil
+}
+
+// receipts requests the receipts of the transactions of a full block in batches
+func (ix *Indexer) receipts(ctx context.Context, b *eth.Block) ([]*Receipt, error) {
+	receipts := make([]*Receipt, len(b.Transactions))
+	for start := 0; start < len(b.Transactions); start += receiptsPerBatch {
+		end := start + receiptsPerBatch
+		if end > len(b.Transactions) {
+			end = len(b.Transactions)
+		}
+		batch := make([]BatchElem, 0, end-start)
+		for i := start; i < end; i++ {
+			batch = append(batch, BatchElem{
+				Method: "eth_getTransactionReceipt",
+				Params: []interface{}{b.Transactions[i].Hash},
+				Result: &receipts[i],
+			})
+		}
+		if err := ix.client.BatchCall(ctx, batch); err != nil {
+			return nil, err
+		}
+		for i, e := range batch {
+			if e.Error != nil {
+				return nil, errors.Wrapf(e.Error, "can't get receipt of %s", b.Transactions[start+i].Hash)
+			}
+		}
+	}
+	for i, r := range receipts {
+		tx := &b.Transactions[i].Transaction
+		if r == nil {
+			return nil, errors.Errorf("no receipt for %s", tx.Hash)
+		}
+		if r.EffectiveGasPrice == nil {
+			// receipts from before EIP-1559
+			price := tx.GasPrice
+			r.EffectiveGasPrice = &price
+		}
+	}
+	return receipts, nil
+}
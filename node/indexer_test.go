@@ -0,0 +1,96 @@
+package node
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"github.com/INFURA/infra-test-benjamin-mateo/node/nodetest"
+	"go.uber.org/zap"
+)
+
+func TestIndexer(t *testing.T) {
+	srv := nodetest.NewServer(nil)
+	defer srv.Close()
+	client, err := GetNewCustomClient(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "indexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	cfg := IndexerConfig{From: nodetest.FirstBlock, To: nodetest.FirstBlock + 2, Confirmations: nodetest.FinalizedDepth, PollInterval: 10 * time.Millisecond}
+
+	// a range is backfilled then the indexer returns, a failed request is retried
+	srv.Fail("eth_getTransactionReceipt", nodetest.ErrUpstreamDown)
+	done := make(chan error)
+	go func() { done <- NewIndexer(&client, store, cfg, zap.NewNop().Sugar()).Run(context.Background()) }()
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := store.Last(); ok {
+		t.Fatal("block stored without its receipts")
+	}
+	srv.Handle("eth_getTransactionReceipt", func(p jsonrpc.Params) (interface{}, error) {
+		var hash string
+		if err := p.UnmarshalInto(&hash); err != nil {
+			return nil, err
+		}
+		return srv.Chain.Receipt(hash), nil
+	})
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("range not indexed")
+	}
+	if last, ok := store.Last(); !ok || last != cfg.To {
+		t.Errorf("got last block %d want %d", last, cfg.To)
+	}
+	tx := srv.Chain.Transaction(cfg.To, 1)
+	if r, ok := store.TransactionReceipt(string(tx.Hash)); !ok || r.EffectiveGasPrice == nil {
+		t.Errorf("receipt not indexed: %+v", r)
+	}
+
+	// following the head resumes after the last block and only indexes the final blocks
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg.From, cfg.To = nodetest.FirstBlock, 0
+	calls := srv.Calls("eth_getBlockByNumber")
+	go func() { done <- NewIndexer(&client, store, cfg, zap.NewNop().Sugar()).Run(ctx) }()
+	srv.Mine()
+	final := srv.Chain.Head().Number.UInt64() - nodetest.FinalizedDepth
+	deadline := time.Now().Add(5 * time.Second)
+	for last, _ := store.Last(); last != final; last, _ = store.Last() {
+		if time.Now().After(deadline) {
+			t.Fatalf("got last block %d want %d", last, final)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := srv.Calls("eth_getBlockByNumber") - calls; n != int(final-nodetest.FirstBlock-2) {
+		t.Errorf("got %d blocks requested want %d", n, final-nodetest.FirstBlock-2)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// backfilling lower blocks doesn't move the last block back
+	cfg.From, cfg.To = nodetest.FirstBlock, nodetest.FirstBlock+1
+	if err := NewIndexer(&client, store, cfg, zap.NewNop().Sugar()).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if last, ok := store.Last(); !ok || last != final {
+		t.Errorf("got last block %d after the backfill want %d", last, final)
+	}
+}
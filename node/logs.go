@@ -0,0 +1,213 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/pkg/errors"
+)
+
+// logRangeSize is the number of blocks requested at once when catching up, nodes reject larger ranges
+const logRangeSize = 1000
+
+// ErrSubscriberDropped is returned when a subscriber could not keep up with the feed
+var ErrSubscriberDropped = errors.New("subscriber too slow")
+
+// LogCursor is the position of a log subscriber in the chain.
+// Its string form is the ID of the events: "N" once block N is fully delivered, "N:I" once the log I of block N is delivered.
+type LogCursor struct {
+	Block    uint64
+	LogIndex *uint64
+}
+
+// ParseLogCursor parses the ID of a log event
+func ParseLogCursor(id string) (LogCursor, error) {
+	parts := strings.SplitN(id, ":", 2)
+	block, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return LogCursor{}, errors.Errorf("invalid block in %q", id)
+	}
+	c := LogCursor{Block: block}
+	if len(parts) == 2 {
+		i, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return LogCursor{}, errors.Errorf("invalid log index in %q", id)
+		}
+		c.LogIndex = &i
+	}
+	return c, nil
+}
+
+func (c LogCursor) String() string {
+	if c.LogIndex == nil {
+		return strconv.FormatUint(c.Block, 10)
+	}
+	return fmt.Sprintf("%d:%d", c.Block, *c.LogIndex)
+}
+
+// next is the first block that has not been fully delivered
+func (c LogCursor) next() uint64 {
+	if c.LogIndex == nil {
+		return c.Block + 1
+	}
+	return c.Block
+}
+
+// delivered tells if the log has already been sent before the cursor
+func (c LogCursor) delivered(l *eth.Log) bool {
+	n := l.BlockNumber.UInt64()
+	return n < c.Block || (n == c.Block && (c.LogIndex == nil || l.LogIndex.UInt64() <= *c.LogIndex))
+}
+
+// LogEvent is a log matching a filter, Log.Removed is set when a reorg removed its block.
+// A checkpoint has no log, it tells that the blocks up to ID have been scanned.
+type LogEvent struct {
+	ID         LogCursor
+	Log        eth.Log
+	Checkpoint bool
+}
+
+// FollowLogs sends the logs matching the address and topics of the filter as the blocks arrive.
+// When from is set the logs after it are sent first so that a subscriber resumes without missing events.
+// The logs of the blocks removed by a reorg are sent again flagged as removed.
+// A checkpoint is sent once a range or a new block is scanned, so a sparse filter resumes after the last block scanned
+// rather than after its last log.
+// The blocks skipped by a head further than Depth past the tip are scanned by range before it.
+// It returns when the context is done, send fails or the subscriber is dropped by the feed.
+func (f *HeadFeed) FollowLogs(ctx context.Context, filter eth.LogFilter, from *LogCursor, send func(LogEvent) error) error {
+	_, events, cancel := f.Subscribe(false, 0)
+	defer cancel()
+
+	// sent keeps the logs of the last blocks to flag them as removed on reorg
+	sent := make(map[eth.Hash][]eth.Log)
+	var order []eth.Hash
+	deliver := func(l eth.Log) error {
+		if l.BlockHash == nil || l.BlockNumber == nil || l.LogIndex == nil {
+			// pending logs are not delivered
+			return nil
+		}
+		i := l.LogIndex.UInt64()
+		if err := send(LogEvent{ID: LogCursor{l.BlockNumber.UInt64(), &i}, Log: l}); err != nil {
+			return err
+		}
+		if _, ok := sent[*l.BlockHash]; !ok {
+			order = append(order, *l.BlockHash)
+			if len(order) > f.cfg.Depth {
+				delete(sent, order[0])
+				order = order[1:]
+			}
+		}
+		sent[*l.BlockHash] = append(sent[*l.BlockHash], l)
+		return nil
+	}
+
+	// scan delivers the logs of the blocks from first to last by range, with a checkpoint after each range
+	scan := func(first, last uint64) error {
+		for start := first; start <= last; start += logRangeSize {
+			end := start + logRangeSize - 1
+			if end > last {
+				end = last
+			}
+			logs, err := f.client.Logs(ctx, rangeFilter(filter, start, end))
+			if err != nil {
+				return errors.Wrapf(err, "can't get logs from block %d to %d", start, end)
+			}
+			for _, l := range logs {
+				if from != nil && from.delivered(&l) {
+					continue
+				}
+				if err := deliver(l); err != nil {
+					return err
+				}
+			}
+			if err := send(LogEvent{ID: LogCursor{Block: end}, Checkpoint: true}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// blocks up to covered have been delivered, the feed events of these blocks are skipped.
+	// Once a block is scanned, the gaps between covered and a new head are scanned as well.
+	var covered uint64
+	scanned := false
+	if from != nil {
+		head, err := f.client.BlockNumber(ctx)
+		if err != nil {
+			return errors.Wrap(err, "can't get block number")
+		}
+		if err := scan(from.next(), head); err != nil {
+			return err
+		}
+		covered, scanned = head, true
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-events:
+			if !ok {
+				return ErrSubscriberDropped
+			}
+			if ev.Type == ReorgEvent {
+				ancestor := LogCursor{}
+				if ev.Reorg.CommonAncestor != nil {
+					ancestor.Block = ev.Reorg.CommonAncestor.Number
+				} else if len(ev.Reorg.Removed) > 0 && ev.Reorg.Removed[0].Number > 0 {
+					ancestor.Block = ev.Reorg.Removed[0].Number - 1
+				}
+				for _, b := range ev.Reorg.Removed {
+					for _, l := range sent[b.Hash] {
+						l.Removed = true
+						if err := send(LogEvent{ID: ancestor, Log: l}); err != nil {
+							return err
+						}
+					}
+					delete(sent, b.Hash)
+				}
+				if covered > ancestor.Block {
+					covered = ancestor.Block
+				}
+				continue
+			}
+
+			number := ev.Head.Number.UInt64()
+			if number <= covered {
+				continue
+			}
+			if scanned && number > covered+1 {
+				// the head jumped further than the feed depth, its missing parents were not emitted
+				if err := scan(covered+1, number-1); err != nil {
+					return err
+				}
+			}
+			hf := filter
+			hf.FromBlock, hf.ToBlock, hf.BlockHash = nil, nil, &ev.Head.Hash
+			logs, err := f.client.Logs(ctx, hf)
+			if err != nil {
+				return errors.Wrapf(err, "can't get logs of block %s", ev.Head.Hash)
+			}
+			for _, l := range logs {
+				if err := deliver(l); err != nil {
+					return err
+				}
+			}
+			if err := send(LogEvent{ID: LogCursor{Block: number}, Checkpoint: true}); err != nil {
+				return err
+			}
+			covered, scanned = number, true
+		}
+	}
+}
+
+// rangeFilter restricts the filter to a range of blocks
+func rangeFilter(filter eth.LogFilter, start, end uint64) eth.LogFilter {
+	filter.FromBlock = eth.MustBlockNumberOrTag(eth.QuantityFromUInt64(start).String())
+	filter.ToBlock = eth.MustBlockNumberOrTag(eth.QuantityFromUInt64(end).String())
+	filter.BlockHash = nil
+	return filter
+}
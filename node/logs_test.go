@@ -0,0 +1,213 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"github.com/INFURA/go-ethlibs/node"
+	"go.uber.org/zap"
+)
+
+// logStub returns a single log per block, the canonical chain is the fork 0.
+// A sparse stub has no log in the ranges requested.
+type logStub struct {
+	chainStub
+	head   uint64
+	sparse bool
+}
+
+func (c logStub) Request(ctx context.Context, r *jsonrpc.Request) (*jsonrpc.RawResponse, error) {
+	switch r.Method {
+	case "eth_blockNumber":
+		return &jsonrpc.RawResponse{ID: r.ID, Result: json.RawMessage(`"` + eth.QuantityFromUInt64(c.head).String() + `"`)}, nil
+	case "eth_getLogs":
+		filter := eth.LogFilter{}
+		if err := json.Unmarshal(r.Params[0], &filter); err != nil {
+			return nil, err
+		}
+		var logs []eth.Log
+		if filter.BlockHash != nil {
+			logs = append(logs, c.log(c.chainStub[*filter.BlockHash]))
+		} else if !c.sparse {
+			from, _ := filter.FromBlock.Quantity()
+			to, _ := filter.ToBlock.Quantity()
+			for n := from.UInt64(); n <= to.UInt64(); n++ {
+				logs = append(logs, c.log(c.chainStub[eth.Hash(fmt.Sprintf("0x%062x%02x", n, 0))]))
+			}
+		}
+		b, err := json.Marshal(logs)
+		return &jsonrpc.RawResponse{ID: r.ID, Result: b}, err
+	}
+	return c.chainStub.Request(ctx, r)
+}
+
+func (c logStub) log(h *eth.NewHeadsResult) eth.Log {
+	return eth.Log{BlockHash: &h.Hash, BlockNumber: &h.Number, LogIndex: eth.MustQuantity("0x0"), Address: eth.Address(testTo.Hex()), Data: "0x"}
+}
+
+func TestParseLogCursor(t *testing.T) {
+	tt := []struct {
+		id   string
+		next uint64
+		err  bool
+	}{
+		{"12", 13, false},
+		{"12:3", 12, false},
+		{"12:", 0, true},
+		{"0x12", 0, true},
+	}
+	for _, tc := range tt {
+		c, err := ParseLogCursor(tc.id)
+		if (err != nil) != tc.err {
+			t.Errorf("%s: unexpected err %v", tc.id, err)
+			continue
+		}
+		if err == nil && (c.next() != tc.next || c.String() != tc.id) {
+			t.Errorf("%s: got %s next %d want next %d", tc.id, c, c.next(), tc.next)
+		}
+	}
+}
+
+func TestFollowLogs(t *testing.T) {
+	chain := logStub{chainStub: chainStub{}, head: 3}
+	client, err := node.NewCustomClient(chain, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed := NewHeadFeed(&CustomClient{Client: client}, HeadFeedConfig{History: 100, Depth: 10}, zap.NewNop().Sugar())
+
+	a1 := chain.add(1, 0, nil)
+	a2 := chain.add(2, 0, a1)
+	a3 := chain.add(3, 0, a2)
+	b3 := chain.add(3, 1, a2)
+	b4 := chain.add(4, 1, b3)
+	for _, h := range []*eth.NewHeadsResult{a1, a2, a3} {
+		if err := feed.onHead(context.Background(), h); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	received := make(chan string, 10)
+	go func() {
+		err := feed.FollowLogs(ctx, eth.LogFilter{}, &LogCursor{Block: 1}, func(ev LogEvent) error {
+			if ev.Checkpoint {
+				received <- fmt.Sprintf("%s checkpoint", ev.ID)
+				return nil
+			}
+			received <- fmt.Sprintf("%s %v", ev.ID, ev.Log.Removed)
+			return nil
+		})
+		if err != nil {
+			received <- err.Error()
+		}
+	}()
+
+	want := []string{"2:0 false", "3:0 false", "3 checkpoint", "2 true", "3:0 false", "3 checkpoint", "4:0 false", "4 checkpoint"}
+	for i, w := range want {
+		select {
+		case got := <-received:
+			if got != w {
+				t.Errorf("event %d: got %s want %s", i, got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d: timeout waiting for %s", i, w)
+		}
+		if i == 2 {
+			// the catch up is done, the reorg is processed by the follower
+			if err := feed.onHead(context.Background(), b4); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestFollowLogsCheckpoints(t *testing.T) {
+	chain := logStub{chainStub: chainStub{}, head: 2500, sparse: true}
+	client, err := node.NewCustomClient(chain, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed := NewHeadFeed(&CustomClient{Client: client}, HeadFeedConfig{History: 100, Depth: 10}, zap.NewNop().Sugar())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var got []string
+	err = feed.FollowLogs(ctx, eth.LogFilter{}, &LogCursor{Block: 0}, func(ev LogEvent) error {
+		if !ev.Checkpoint {
+			t.Errorf("unexpected log %s", ev.ID)
+		}
+		got = append(got, ev.ID.String())
+		if ev.ID.Block == chain.head {
+			cancel()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a resume from the last checkpoint doesn't scan the blocks without logs again
+	if want := "[1000 2000 2500]"; fmt.Sprint(got) != want {
+		t.Errorf("got checkpoints %v want %s", got, want)
+	}
+}
+
+func TestFollowLogsHeadJump(t *testing.T) {
+	chain := logStub{chainStub: chainStub{}, head: 2}
+	client, err := node.NewCustomClient(chain, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed := NewHeadFeed(&CustomClient{Client: client}, HeadFeedConfig{History: 100, Depth: 2}, zap.NewNop().Sugar())
+
+	heads := []*eth.NewHeadsResult{chain.add(1, 0, nil)}
+	for n := uint64(2); n <= 7; n++ {
+		heads = append(heads, chain.add(n, 0, heads[len(heads)-1]))
+	}
+	for _, h := range heads[:2] {
+		if err := feed.onHead(context.Background(), h); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	received := make(chan string, 10)
+	go func() {
+		err := feed.FollowLogs(ctx, eth.LogFilter{}, &LogCursor{Block: 1}, func(ev LogEvent) error {
+			if ev.Checkpoint {
+				received <- fmt.Sprintf("%s checkpoint", ev.ID)
+				return nil
+			}
+			received <- ev.ID.String()
+			return nil
+		})
+		if err != nil {
+			received <- err.Error()
+		}
+	}()
+
+	// the head 7 is further than the depth past the tip 2, the blocks in between are scanned before it
+	want := []string{"2:0", "2 checkpoint", "3:0", "4:0", "5:0", "6:0", "6 checkpoint", "7:0", "7 checkpoint"}
+	for i, w := range want {
+		select {
+		case got := <-received:
+			if got != w {
+				t.Errorf("event %d: got %s want %s", i, got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d: timeout waiting for %s", i, w)
+		}
+		if i == 1 {
+			if err := feed.onHead(context.Background(), heads[6]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package nodetest
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Shape of the fixture chain
+const (
+	// FirstBlock is the number of the first block of a new chain
+	FirstBlock = 9135240
+	// Length is the number of blocks of a new chain
+	Length = 32
+	// TxsPerBlock is the number of transactions of every block: two token transfers and an ether transfer
+	TxsPerBlock = 3
+	// FinalizedDepth is the number of blocks between the head and the safe and finalized blocks
+	FinalizedDepth = 8
+	// BlockTime is the number of seconds between two blocks
+	BlockTime = 12
+	// ChainID is the chain ID and network ID of the chain
+	ChainID = 1
+)
+
+// Fixture accounts and values
+var (
+	// Sender sends every transaction of the chain
+	Sender = common.HexToAddress("0x5cf2cbfd110e7ce39fb353d123776ab683ef9feb")
+	// Recipient receives the token and ether transfers
+	Recipient = common.HexToAddress("0x3f5ce5fbfe3e9af3971dd833d26ba9b5c936f0be")
+	// Token is an ERC20 contract with code and storage
+	Token = common.HexToAddress("0xe530441f4f73bdb6dc2fa5af7c3fc5fd551ec838")
+	// Miner mines every block
+	Miner = common.HexToAddress("0xea674fdde714fd979de3edf0f56aa9716b898ec8")
+
+	// TransferTopic is the topic of the ERC20 Transfer event
+	TransferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	// SenderTokenBalance is the token balance of the sender returned by balanceOf
+	SenderTokenBalance = "0x000000000000000000000000000000000000000000000000000000000016bc50"
+
+	// GasPrice is the gas price of every transaction and the one suggested by the node
+	GasPrice = big.NewInt(20000000000)
+	// BaseFee is the base fee of every block
+	BaseFee = big.NewInt(10000000000)
+	// PriorityFee is the priority fee suggested by the node
+	PriorityFee = big.NewInt(1500000000)
+
+	emptyUncleHash = "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"
+)
+
+// Account is the state of an account of the chain, it is the same at every block
+type Account struct {
+	Balance *big.Int
+	Nonce   uint64
+	Code    []byte
+	Storage map[common.Hash]common.Hash
+	// Calls maps the input of an eth_call to the contract to its output, other inputs revert
+	Calls map[string]string
+}
+
+// Chain is a deterministic chain of blocks with transactions, receipts and logs.
+// Hashes are derived from the block number and the fork, two chains built the same way are identical.
+type Chain struct {
+	mu sync.RWMutex
+	// blocks is the canonical chain with full transactions
+	blocks []*eth.Block
+	// byHash also keeps the blocks reorganized away, nodes still serve them by hash
+	byHash   map[eth.Hash]*eth.Block
+	txs      map[eth.Hash]*eth.Transaction
+	receipts map[eth.Hash]*eth.TransactionReceipt
+	logs     map[eth.Hash][]*eth.Log
+	fork     int
+
+	accounts  map[common.Address]*Account
+	state     *trie.Trie
+	storage   map[common.Address]*trie.Trie
+	stateRoot common.Hash
+}
+
+// NewChain builds Length blocks from FirstBlock, the head is mined now
+func NewChain() *Chain {
+	c := &Chain{
+		byHash:   make(map[eth.Hash]*eth.Block),
+		txs:      make(map[eth.Hash]*eth.Transaction),
+		receipts: make(map[eth.Hash]*eth.TransactionReceipt),
+		logs:     make(map[eth.Hash][]*eth.Log),
+		accounts: map[common.Address]*Account{
+			Sender: {
+				Balance: new(big.Int).Mul(big.NewInt(2), big.NewInt(1e18)),
+				Nonce:   Length * TxsPerBlock,
+			},
+			Recipient: {
+				Balance: new(big.Int).Mul(big.NewInt(Length), big.NewInt(1e16)),
+			},
+			Token: {
+				Balance: new(big.Int),
+				Nonce:   1,
+				Code:    common.FromHex("0x6080604052348015600f57600080fd5b506004361060285760003560e01c806370a0823114602d575b600080fd5b00"),
+				Storage: map[common.Hash]common.Hash{
+					// total supply
+					common.BigToHash(big.NewInt(0)): common.BigToHash(big.NewInt(1e15)),
+					// decimals
+					common.BigToHash(big.NewInt(1)): common.BigToHash(big.NewInt(6)),
+				},
+				Calls: map[string]string{
+					// balanceOf(Sender)
+					"0x70a08231" + strings.TrimPrefix(common.BytesToHash(Sender.Bytes()).Hex(), "0x"): SenderTokenBalance,
+				},
+			},
+		},
+	}
+	c.buildState()
+
+	start := uint64(time.Now().Unix()) - (Length-1)*BlockTime
+	parent := eth.Hash(common.Hash{}.Hex())
+	for i := uint64(0); i < Length; i++ {
+		b := c.makeBlock(FirstBlock+i, parent, start+i*BlockTime)
+		c.insert(b)
+		parent = *b.Hash
+	}
+	return c
+}
+
+// Head returns the last block of the chain
+func (c *Chain) Head() *eth.Block {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.blocks[len(c.blocks)-1]
+}
+
+// Block returns the canonical block at the height, nil if it is not in the chain
+func (c *Chain) Block(number uint64) *eth.Block {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.block(number)
+}
+
+// BlockByHash returns the block with the hash, including the ones reorganized away
+func (c *Chain) BlockByHash(hash string) *eth.Block {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.byHash[eth.Hash(strings.ToLower(hash))]
+}
+
+// Transaction returns the transaction at the index of the canonical block at the height
+func (c *Chain) Transaction(number uint64, index int) *eth.Transaction {
+	b := c.Block(number)
+	if b == nil || index >= len(b.Transactions) {
+		return nil
+	}
+	return &b.Transactions[index].Transaction
+}
+
+// Receipt returns the receipt of a transaction of the canonical chain
+func (c *Chain) Receipt(hash string) *eth.TransactionReceipt {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.receipts[eth.Hash(strings.ToLower(hash))]
+}
+
+// Account returns the state of an address, nil if the address has no state
+func (c *Chain) Account(address common.Address) *Account {
+	return c.accounts[address]
+}
+
+// StateRoot returns the root of the state trie shared by every block
+func (c *Chain) StateRoot() common.Hash {
+	return c.stateRoot
+}
+
+// Mine appends a block on top of the head
+func (c *Chain) Mine() *eth.Block {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	head := c.blocks[len(c.blocks)-1]
+	ts := head.Timestamp.UInt64() + 1
+	if now := uint64(time.Now().Unix()); now > ts {
+		ts = now
+	}
+	b := c.makeBlock(head.Number.UInt64()+1, *head.Hash, ts)
+	c.insert(b)
+	return b
+}
+
+// Reorg replaces the last depth blocks by a fork one block longer and returns the blocks of the fork
+func (c *Chain) Reorg(depth int) []*eth.Block {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if depth >= len(c.blocks) {
+		depth = len(c.blocks) - 1
+	}
+	ancestor := c.blocks[len(c.blocks)-1-depth]
+	c.blocks = c.blocks[:len(c.blocks)-depth]
+	c.fork++
+
+	var fork []*eth.Block
+	parent := ancestor
+	for i := 0; i <= depth; i++ {
+		b := c.makeBlock(parent.Number.UInt64()+1, *parent.Hash, parent.Timestamp.UInt64()+BlockTime)
+		c.insert(b)
+		fork = append(fork, b)
+		parent = b
+	}
+	return fork
+}
+
+func (c *Chain) block(number uint64) *eth.Block {
+	first := c.blocks[0].Number.UInt64()
+	if number < first || number-first >= uint64(len(c.blocks)) {
+		return nil
+	}
+	return c.blocks[number-first]
+}
+
+// head returns the canonical head, c.mu must be held
+func (c *Chain) head() *eth.Block {
+	return c.blocks[len(c.blocks)-1]
+}
+
+// blockLogs returns the logs of a block
+func (c *Chain) blockLogs(hash eth.Hash) []*eth.Log {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.logs[hash]
+}
+
+// insert appends the block to the canonical chain, c.mu must be held
+func (c *Chain) insert(b *eth.Block) {
+	c.blocks = append(c.blocks, b)
+	c.byHash[*b.Hash] = b
+	for i := range b.Transactions {
+		tx := &b.Transactions[i].Transaction
+		c.txs[tx.Hash] = tx
+		r := c.makeReceipt(b, tx)
+		c.receipts[tx.Hash] = r
+		for j := range r.Logs {
+			c.logs[*b.Hash] = append(c.logs[*b.Hash], &r.Logs[j])
+		}
+	}
+}
+
+// hash derives a fixture hash from its parts
+func hash(parts ...interface{}) string {
+	return crypto.Keccak256Hash([]byte(fmt.Sprint(parts...))).Hex()
+}
+
+func quantity(v uint64) *eth.Quantity {
+	q := eth.QuantityFromUInt64(v)
+	return &q
+}
+
+func address(a common.Address) eth.Address {
+	return *eth.MustAddress(a.Hex())
+}
+
+// makeBlock builds the block at the height on the current fork
+func (c *Chain) makeBlock(number uint64, parent eth.Hash, timestamp uint64) *eth.Block {
+	h := eth.Hash(hash("block", number, c.fork))
+	nonce := eth.Data8("0x0000000000000000")
+	mixHash := eth.Data(common.Hash{}.Hex())
+	b := &eth.Block{
+		Number:           quantity(number),
+		Hash:             &h,
+		ParentHash:       parent,
+		SHA3Uncles:       eth.Data32(emptyUncleHash),
+		TransactionsRoot: eth.Data32(hash("transactions", number, c.fork)),
+		StateRoot:        eth.Data32(c.stateRoot.Hex()),
+		ReceiptsRoot:     eth.Data32(hash("receipts", number, c.fork)),
+		Miner:            address(Miner),
+		Difficulty:       eth.QuantityFromUInt64(2),
+		TotalDifficulty:  eth.QuantityFromUInt64(2 * number),
+		ExtraData:        eth.Data("0x"),
+		Size:             eth.QuantityFromUInt64(1024),
+		GasLimit:         eth.QuantityFromUInt64(30000000),
+		Timestamp:        eth.QuantityFromUInt64(timestamp),
+		Uncles:           []eth.Hash{},
+		Nonce:            &nonce,
+		MixHash:          &mixHash,
+	}
+
+	var gasUsed uint64
+	bloom := types.Bloom{}
+	for i := 0; i < TxsPerBlock; i++ {
+		tx := makeTx(b, i)
+		gasUsed += txGasUsed(i)
+		if i < TxsPerBlock-1 {
+			// token transfer
+			bloom.Add(new(big.Int).SetBytes(Token.Bytes()))
+			bloom.Add(new(big.Int).SetBytes(common.FromHex(TransferTopic)))
+		}
+		b.Transactions = append(b.Transactions, eth.TxOrHash{Transaction: tx, Populated: true})
+	}
+	b.GasUsed = eth.QuantityFromUInt64(gasUsed)
+	b.LogsBloom = eth.Data256("0x" + common.Bytes2Hex(bloom.Bytes()))
+	return b
+}
+
+func txGasUsed(index int) uint64 {
+	if index < TxsPerBlock-1 {
+		return 50000
+	}
+	return 21000
+}
+
+// tokenAmount is the amount of tokens transferred by a transaction
+func tokenAmount(number uint64, index int) *big.Int {
+	return big.NewInt(int64(number%1000+1)*1000000 + int64(index))
+}
+
+// makeTx builds the transaction at the index of the block.
+// The hash only depends on the height and the index so a reorg includes the same transactions in the new blocks.
+func makeTx(b *eth.Block, index int) eth.Transaction {
+	number := b.Number.UInt64()
+	tx := eth.Transaction{
+		BlockHash:   b.Hash,
+		BlockNumber: b.Number,
+		From:        address(Sender),
+		GasPrice:    eth.QuantityFromBigInt(GasPrice),
+		Hash:        eth.Hash(hash("tx", number, index)),
+		Nonce:       eth.QuantityFromUInt64((number-FirstBlock)*TxsPerBlock + uint64(index)),
+		Index:       quantity(uint64(index)),
+		V:           eth.QuantityFromUInt64(37),
+		R:           eth.QuantityFromBigInt(new(big.Int).SetBytes(common.FromHex(hash("r", number, index)))),
+		S:           eth.QuantityFromBigInt(new(big.Int).SetBytes(common.FromHex(hash("s", number, index))[1:])),
+	}
+	if index < TxsPerBlock-1 {
+		to := address(Token)
+		tx.To = &to
+		tx.Gas = eth.QuantityFromUInt64(65000)
+		tx.Value = eth.QuantityFromUInt64(0)
+		// transfer(Recipient, amount)
+		tx.Input = eth.Data("0xa9059cbb" +
+			strings.TrimPrefix(common.BytesToHash(Recipient.Bytes()).Hex(), "0x") +
+			strings.TrimPrefix(common.BigToHash(tokenAmount(number, index)).Hex(), "0x"))
+	} else {
+		to := address(Recipient)
+		tx.To = &to
+		tx.Gas = eth.QuantityFromUInt64(21000)
+		tx.Value = eth.QuantityFromBigInt(big.NewInt(1e16))
+		tx.Input = eth.Data("0x")
+	}
+	return tx
+}
+
+// makeReceipt builds the receipt of a transaction of the block, c.mu must be held
+func (c *Chain) makeReceipt(b *eth.Block, tx *eth.Transaction) *eth.TransactionReceipt {
+	index := int(tx.Index.UInt64())
+	var cumulative uint64
+	for i := 0; i <= index; i++ {
+		cumulative += txGasUsed(i)
+	}
+	r := &eth.TransactionReceipt{
+		TransactionHash:   tx.Hash,
+		TransactionIndex:  *tx.Index,
+		BlockHash:         *b.Hash,
+		BlockNumber:       *b.Number,
+		From:              tx.From,
+		To:                tx.To,
+		CumulativeGasUsed: eth.QuantityFromUInt64(cumulative),
+		GasUsed:           eth.QuantityFromUInt64(txGasUsed(index)),
+		Logs:              []eth.Log{},
+		LogsBloom:         eth.Data256("0x" + strings.Repeat("0", 512)),
+		Status:            quantity(1),
+	}
+	if index < TxsPerBlock-1 {
+		bloom := types.Bloom{}
+		bloom.Add(new(big.Int).SetBytes(Token.Bytes()))
+		bloom.Add(new(big.Int).SetBytes(common.FromHex(TransferTopic)))
+		r.LogsBloom = eth.Data256("0x" + common.Bytes2Hex(bloom.Bytes()))
+		r.Logs = append(r.Logs, eth.Log{
+			// the token transfers are the first transactions of the block, their log index is their index
+			LogIndex:    quantity(uint64(index)),
+			TxIndex:     tx.Index,
+			TxHash:      &tx.Hash,
+			BlockHash:   b.Hash,
+			BlockNumber: b.Number,
+			Address:     address(Token),
+			Data:        eth.Data(common.BigToHash(tokenAmount(b.Number.UInt64(), index)).Hex()),
+			Topics: []eth.Topic{
+				eth.Topic(TransferTopic),
+				eth.Topic(common.BytesToHash(Sender.Bytes()).Hex()),
+				eth.Topic(common.BytesToHash(Recipient.Bytes()).Hex()),
+			},
+		})
+	}
+	return r
+}
+
+// buildState computes the state trie of the accounts and the storage tries of the contracts
+func (c *Chain) buildState() {
+	c.storage = make(map[common.Address]*trie.Trie)
+	c.state = newTrie()
+	for addr, a := range c.accounts {
+		st := newTrie()
+		for k, v := range a.Storage {
+			value, _ := rlp.EncodeToBytes(common.TrimLeftZeroes(v.Bytes()))
+			st.Update(crypto.Keccak256(k.Bytes()), value)
+		}
+		c.storage[addr] = st
+
+		account, _ := rlp.EncodeToBytes([]interface{}{a.Nonce, a.Balance, st.Hash(), crypto.Keccak256(a.Code)})
+		c.state.Update(crypto.Keccak256(addr.Bytes()), account)
+	}
+	c.stateRoot = c.state.Hash()
+}
+
+func newTrie() *trie.Trie {
+	t, _ := trie.New(common.Hash{}, trie.NewDatabase(memorydb.New()))
+	return t
+}
@@ -0,0 +1,574 @@
+package nodetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// handlers returns the default handler of every method served from the chain
+func (c *Chain) handlers() map[string]Handler {
+	return map[string]Handler{
+		"eth_chainId":              constant(hexutil.Uint64(ChainID)),
+		"net_version":              constant(fmt.Sprint(ChainID)),
+		"web3_clientVersion":       constant("Geth/v1.10.26-stable/linux-amd64/go1.18.5"),
+		"net_peerCount":            constant(hexutil.Uint64(25)),
+		"eth_syncing":              constant(false),
+		"eth_gasPrice":             constant((*hexutil.Big)(GasPrice)),
+		"eth_maxPriorityFeePerGas": constant((*hexutil.Big)(PriorityFee)),
+
+		"eth_blockNumber": func(jsonrpc.Params) (interface{}, error) {
+			return c.Head().Number, nil
+		},
+		"eth_getBlockByNumber": func(p jsonrpc.Params) (interface{}, error) {
+			var full bool
+			b, err := c.blockParam(p, 0)
+			if err != nil || p.UnmarshalSingleParam(1, &full) != nil {
+				return nil, ErrInvalidParams
+			}
+			return blockResult(b, full), nil
+		},
+		"eth_getBlockByHash": func(p jsonrpc.Params) (interface{}, error) {
+			var h string
+			var full bool
+			if p.UnmarshalInto(&h, &full) != nil {
+				return nil, ErrInvalidParams
+			}
+			return blockResult(c.BlockByHash(h), full), nil
+		},
+		"eth_getBlockTransactionCountByNumber": func(p jsonrpc.Params) (interface{}, error) {
+			b, err := c.blockParam(p, 0)
+			if err != nil {
+				return nil, ErrInvalidParams
+			}
+			return txCount(b), nil
+		},
+		"eth_getBlockTransactionCountByHash": func(p jsonrpc.Params) (interface{}, error) {
+			var h string
+			if p.UnmarshalInto(&h) != nil {
+				return nil, ErrInvalidParams
+			}
+			return txCount(c.BlockByHash(h)), nil
+		},
+		"eth_getTransactionByHash": func(p jsonrpc.Params) (interface{}, error) {
+			var h string
+			if p.UnmarshalInto(&h) != nil {
+				return nil, ErrInvalidParams
+			}
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+			return c.txs[eth.Hash(strings.ToLower(h))], nil
+		},
+		"eth_getTransactionByBlockNumberAndIndex": func(p jsonrpc.Params) (interface{}, error) {
+			var i eth.Quantity
+			b, err := c.blockParam(p, 0)
+			if err != nil || p.UnmarshalSingleParam(1, &i) != nil {
+				return nil, ErrInvalidParams
+			}
+			return txAt(b, i.UInt64()), nil
+		},
+		"eth_getTransactionByBlockHashAndIndex": func(p jsonrpc.Params) (interface{}, error) {
+			var h string
+			var i eth.Quantity
+			if p.UnmarshalInto(&h, &i) != nil {
+				return nil, ErrInvalidParams
+			}
+			return txAt(c.BlockByHash(h), i.UInt64()), nil
+		},
+		"eth_getTransactionReceipt": func(p jsonrpc.Params) (interface{}, error) {
+			var h string
+			if p.UnmarshalInto(&h) != nil {
+				return nil, ErrInvalidParams
+			}
+			return c.Receipt(h), nil
+		},
+		"eth_getUncleCountByBlockNumber": func(p jsonrpc.Params) (interface{}, error) {
+			b, err := c.blockParam(p, 0)
+			if err != nil {
+				return nil, ErrInvalidParams
+			}
+			return uncleCount(b), nil
+		},
+		"eth_getUncleCountByBlockHash": func(p jsonrpc.Params) (interface{}, error) {
+			var h string
+			if p.UnmarshalInto(&h) != nil {
+				return nil, ErrInvalidParams
+			}
+			return uncleCount(c.BlockByHash(h)), nil
+		},
+		// the blocks of the chain have no uncle
+		"eth_getUncleByBlockNumberAndIndex": constant(nil),
+		"eth_getUncleByBlockHashAndIndex":   constant(nil),
+		"eth_getLogs": func(p jsonrpc.Params) (interface{}, error) {
+			f := eth.LogFilter{}
+			if p.UnmarshalInto(&f) != nil {
+				return nil, ErrInvalidParams
+			}
+			return c.filterLogs(&f)
+		},
+
+		"eth_getBalance": c.stateHandler(1, func(a *Account, _ jsonrpc.Params) (interface{}, error) {
+			return (*hexutil.Big)(a.Balance), nil
+		}),
+		"eth_getTransactionCount": c.stateHandler(1, func(a *Account, _ jsonrpc.Params) (interface{}, error) {
+			return hexutil.Uint64(a.Nonce), nil
+		}),
+		"eth_getCode": c.stateHandler(1, func(a *Account, _ jsonrpc.Params) (interface{}, error) {
+			return hexutil.Bytes(a.Code), nil
+		}),
+		"eth_getStorageAt": c.stateHandler(2, func(a *Account, p jsonrpc.Params) (interface{}, error) {
+			var slot eth.Quantity
+			if p.UnmarshalSingleParam(1, &slot) != nil {
+				return nil, ErrInvalidParams
+			}
+			// slots are always returned as 32 bytes
+			return a.Storage[common.BigToHash(slot.Big())], nil
+		}),
+		"eth_getProof":    c.getProof,
+		"eth_call":        c.call,
+		"eth_estimateGas": c.estimateGas,
+		"eth_createAccessList": func(p jsonrpc.Params) (interface{}, error) {
+			gas, err := c.estimateGas(p)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"accessList": []interface{}{}, "gasUsed": gas}, nil
+		},
+		"eth_feeHistory": c.feeHistory,
+		"eth_sendRawTransaction": func(p jsonrpc.Params) (interface{}, error) {
+			var raw hexutil.Bytes
+			if p.UnmarshalInto(&raw) != nil || len(raw) == 0 {
+				return nil, &Error{Code: -32000, Message: "rlp: value size exceeds available input length"}
+			}
+			return crypto.Keccak256Hash(raw), nil
+		},
+		"debug_traceTransaction": c.traceTransaction,
+	}
+}
+
+// constant returns a handler answering the same result to every request
+func constant(result interface{}) Handler {
+	return func(jsonrpc.Params) (interface{}, error) { return result, nil }
+}
+
+// blockParam resolves the block number, tag or EIP-1898 object at the position of the params, nil if the block is unknown
+func (c *Chain) blockParam(p jsonrpc.Params, pos int) (*eth.Block, error) {
+	if pos >= len(p) {
+		return c.Head(), nil
+	}
+	var value string
+	if err := p.UnmarshalSingleParam(pos, &value); err != nil {
+		object := struct {
+			BlockNumber *eth.Quantity `json:"blockNumber"`
+			BlockHash   *string       `json:"blockHash"`
+		}{}
+		if err := p.UnmarshalSingleParam(pos, &object); err != nil {
+			return nil, err
+		}
+		switch {
+		case object.BlockHash != nil:
+			return c.BlockByHash(*object.BlockHash), nil
+		case object.BlockNumber != nil:
+			return c.Block(object.BlockNumber.UInt64()), nil
+		}
+		return nil, ErrInvalidParams
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	switch value {
+	case eth.TagLatest, eth.TagPending:
+		return c.head(), nil
+	case eth.TagEarliest:
+		return c.blocks[0], nil
+	case "safe", "finalized":
+		head := c.head().Number.UInt64()
+		if head-FinalizedDepth < c.blocks[0].Number.UInt64() {
+			return c.blocks[0], nil
+		}
+		return c.block(head - FinalizedDepth), nil
+	}
+	q, err := eth.NewQuantity(value)
+	if err != nil {
+		return nil, err
+	}
+	return c.block(q.UInt64()), nil
+}
+
+// blockResult returns the block with its transaction hashes when full is false
+func blockResult(b *eth.Block, full bool) interface{} {
+	if b == nil {
+		return nil
+	}
+	if full {
+		return b
+	}
+	cp := *b
+	cp.Transactions = make([]eth.TxOrHash, len(b.Transactions))
+	for i, tx := range b.Transactions {
+		cp.Transactions[i] = eth.TxOrHash{Transaction: tx.Transaction}
+	}
+	return &cp
+}
+
+func txCount(b *eth.Block) interface{} {
+	if b == nil {
+		return nil
+	}
+	return hexutil.Uint64(uint64(len(b.Transactions)))
+}
+
+func txAt(b *eth.Block, index uint64) interface{} {
+	if b == nil || index >= uint64(len(b.Transactions)) {
+		return nil
+	}
+	return &b.Transactions[index].Transaction
+}
+
+func uncleCount(b *eth.Block) interface{} {
+	if b == nil {
+		return nil
+	}
+	return hexutil.Uint64(uint64(len(b.Uncles)))
+}
+
+// stateHandler answers a state query of an address at the block param at the position.
+// The state is the same at every block, unknown blocks fail like on a pruned node.
+func (c *Chain) stateHandler(pos int, get func(a *Account, p jsonrpc.Params) (interface{}, error)) Handler {
+	return func(p jsonrpc.Params) (interface{}, error) {
+		var addr string
+		if err := p.UnmarshalSingleParam(0, &addr); err != nil || !common.IsHexAddress(addr) {
+			return nil, ErrInvalidParams
+		}
+		b, err := c.blockParam(p, pos)
+		if err != nil {
+			return nil, ErrInvalidParams
+		}
+		if b == nil {
+			return nil, ErrHeaderNotFound
+		}
+		a := c.Account(common.HexToAddress(addr))
+		if a == nil {
+			a = &Account{Balance: new(big.Int)}
+		}
+		return get(a, p)
+	}
+}
+
+// filterLogs returns the logs of the canonical chain matching the filter
+func (c *Chain) filterLogs(f *eth.LogFilter) (interface{}, error) {
+	var blocks []*eth.Block
+	if f.BlockHash != nil {
+		b := c.BlockByHash(string(*f.BlockHash))
+		if b == nil {
+			return nil, &Error{Code: -32000, Message: "unknown block"}
+		}
+		blocks = append(blocks, b)
+	} else {
+		from, err := c.filterBound(f.FromBlock)
+		if err != nil {
+			return nil, err
+		}
+		to, err := c.filterBound(f.ToBlock)
+		if err != nil {
+			return nil, err
+		}
+		for n := from; n <= to; n++ {
+			if b := c.Block(n); b != nil {
+				blocks = append(blocks, b)
+			}
+		}
+	}
+
+	logs := []*eth.Log{}
+	for _, b := range blocks {
+		for _, l := range c.blockLogs(*b.Hash) {
+			if matchLog(f, l) {
+				logs = append(logs, l)
+			}
+		}
+	}
+	return logs, nil
+}
+
+// filterBound resolves a bound of a log filter range, the default is the head
+func (c *Chain) filterBound(b *eth.BlockNumberOrTag) (uint64, error) {
+	if b == nil {
+		return c.Head().Number.UInt64(), nil
+	}
+	if q, ok := b.Quantity(); ok {
+		return q.UInt64(), nil
+	}
+	tag, _ := b.Tag()
+	block, err := c.blockParam(jsonrpc.MustParams(tag), 0)
+	if err != nil || block == nil {
+		return 0, ErrInvalidParams
+	}
+	return block.Number.UInt64(), nil
+}
+
+// matchLog checks the address and the topics of a log against a filter, the block range is not checked
+func matchLog(f *eth.LogFilter, l *eth.Log) bool {
+	if len(f.Address) > 0 {
+		found := false
+		for _, a := range f.Address {
+			if strings.EqualFold(string(a), string(l.Address)) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for i, topics := range f.Topics {
+		if len(topics) == 0 {
+			// wildcard
+			continue
+		}
+		if i >= len(l.Topics) {
+			return false
+		}
+		found := false
+		for _, t := range topics {
+			if strings.EqualFold(string(t), string(l.Topics[i])) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// callParams are the fields of a call used by the fixture handlers
+type callParams struct {
+	To    *string        `json:"to"`
+	Data  *hexutil.Bytes `json:"data"`
+	Input *hexutil.Bytes `json:"input"`
+}
+
+func (p *callParams) input() string {
+	switch {
+	case p.Input != nil:
+		return p.Input.String()
+	case p.Data != nil:
+		return p.Data.String()
+	}
+	return "0x"
+}
+
+// call answers from the Calls of the contract, a call to an address without code returns nothing
+func (c *Chain) call(p jsonrpc.Params) (interface{}, error) {
+	call := callParams{}
+	if p.UnmarshalSingleParam(0, &call) != nil || call.To == nil {
+		return nil, ErrInvalidParams
+	}
+	if b, err := c.blockParam(p, 1); err != nil || b == nil {
+		return nil, ErrHeaderNotFound
+	}
+	a := c.Account(common.HexToAddress(*call.To))
+	if a == nil || len(a.Code) == 0 {
+		return "0x", nil
+	}
+	out, ok := a.Calls[strings.ToLower(call.input())]
+	if !ok {
+		return nil, ErrExecutionReverted
+	}
+	return out, nil
+}
+
+// estimateGas returns the gas of a transfer for calls without input, it reverts like call otherwise
+func (c *Chain) estimateGas(p jsonrpc.Params) (interface{}, error) {
+	call := callParams{}
+	if p.UnmarshalSingleParam(0, &call) != nil {
+		return nil, ErrInvalidParams
+	}
+	if call.input() == "0x" {
+		return hexutil.Uint64(21000), nil
+	}
+	if _, err := c.call(p); err != nil {
+		return nil, err
+	}
+	return hexutil.Uint64(txGasUsed(0)), nil
+}
+
+// feeHistory reports the same base fee and rewards for every block, the blocks are half full
+func (c *Chain) feeHistory(p jsonrpc.Params) (interface{}, error) {
+	var count eth.Quantity
+	var percentiles []float64
+	if p.UnmarshalSingleParam(0, &count) != nil {
+		return nil, ErrInvalidParams
+	}
+	newest, err := c.blockParam(p, 1)
+	if err != nil || newest == nil {
+		return nil, ErrInvalidParams
+	}
+	if len(p) > 2 && p.UnmarshalSingleParam(2, &percentiles) != nil {
+		return nil, ErrInvalidParams
+	}
+
+	n := count.UInt64()
+	first := c.Block(FirstBlock)
+	if first == nil {
+		first = c.Head()
+	}
+	if oldest := first.Number.UInt64(); newest.Number.UInt64()+1-oldest < n {
+		n = newest.Number.UInt64() + 1 - oldest
+	}
+	history := struct {
+		OldestBlock   eth.Quantity     `json:"oldestBlock"`
+		BaseFeePerGas []eth.Quantity   `json:"baseFeePerGas"`
+		GasUsedRatio  []float64        `json:"gasUsedRatio"`
+		Reward        [][]eth.Quantity `json:"reward,omitempty"`
+	}{OldestBlock: eth.QuantityFromUInt64(newest.Number.UInt64() + 1 - n)}
+	for i := uint64(0); i <= n; i++ {
+		history.BaseFeePerGas = append(history.BaseFeePerGas, eth.QuantityFromBigInt(BaseFee))
+		if i == n {
+			break
+		}
+		history.GasUsedRatio = append(history.GasUsedRatio, 0.5)
+		if len(percentiles) > 0 {
+			rewards := make([]eth.Quantity, len(percentiles))
+			for j, pct := range percentiles {
+				// the reward grows with the percentile
+				rewards[j] = eth.QuantityFromUInt64(uint64(pct) * 1e8)
+			}
+			history.Reward = append(history.Reward, rewards)
+		}
+	}
+	return &history, nil
+}
+
+// getProof proves the account and the storage slots against the state root shared by every block
+func (c *Chain) getProof(p jsonrpc.Params) (interface{}, error) {
+	var addr string
+	var keys []string
+	if p.UnmarshalInto(&addr, &keys) != nil || !common.IsHexAddress(addr) {
+		return nil, ErrInvalidParams
+	}
+	if b, err := c.blockParam(p, 2); err != nil || b == nil {
+		return nil, ErrHeaderNotFound
+	}
+
+	address := common.HexToAddress(addr)
+	a := c.Account(address)
+	result := map[string]interface{}{
+		"address":      strings.ToLower(addr),
+		"balance":      "0x0",
+		"nonce":        "0x0",
+		"codeHash":     crypto.Keccak256Hash(nil),
+		"storageHash":  common.Hash{},
+		"accountProof": prove(c.state, crypto.Keccak256(address.Bytes())),
+	}
+	storage := newTrie()
+	if a != nil {
+		storage = c.storage[address]
+		result["balance"] = (*hexutil.Big)(a.Balance)
+		result["nonce"] = hexutil.Uint64(a.Nonce)
+		result["codeHash"] = crypto.Keccak256Hash(a.Code)
+		result["storageHash"] = storage.Hash()
+	}
+
+	proofs := []map[string]interface{}{}
+	for _, k := range keys {
+		key := common.HexToHash(k)
+		value := new(big.Int)
+		if a != nil {
+			value = a.Storage[key].Big()
+		}
+		proofs = append(proofs, map[string]interface{}{
+			"key":   k,
+			"value": (*hexutil.Big)(value),
+			"proof": prove(storage, crypto.Keccak256(key.Bytes())),
+		})
+	}
+	result["storageProof"] = proofs
+	return result, nil
+}
+
+// traceTransaction supports the call and prestate tracers and the struct logger
+func (c *Chain) traceTransaction(p jsonrpc.Params) (interface{}, error) {
+	var h string
+	if p.UnmarshalSingleParam(0, &h) != nil {
+		return nil, ErrInvalidParams
+	}
+	cfg := struct {
+		Tracer       string          `json:"tracer"`
+		TracerConfig json.RawMessage `json:"tracerConfig"`
+	}{}
+	if len(p) > 1 && p.UnmarshalSingleParam(1, &cfg) != nil {
+		return nil, ErrInvalidParams
+	}
+	c.mu.RLock()
+	tx := c.txs[eth.Hash(strings.ToLower(h))]
+	c.mu.RUnlock()
+	if tx == nil {
+		return nil, &Error{Code: -32000, Message: fmt.Sprintf("transaction %s not found", h)}
+	}
+	gasUsed := txGasUsed(int(tx.Index.UInt64()))
+
+	switch cfg.Tracer {
+	case "callTracer":
+		return map[string]interface{}{
+			"type":    "CALL",
+			"from":    &tx.From,
+			"to":      tx.To,
+			"value":   &tx.Value,
+			"gas":     &tx.Gas,
+			"gasUsed": hexutil.Uint64(gasUsed),
+			"input":   &tx.Input,
+			"output":  "0x",
+		}, nil
+	case "prestateTracer":
+		pre := map[string]interface{}{}
+		for _, addr := range []common.Address{Sender, common.HexToAddress(string(*tx.To))} {
+			a := c.Account(addr)
+			pre[strings.ToLower(addr.Hex())] = map[string]interface{}{
+				"balance": (*hexutil.Big)(a.Balance),
+				"nonce":   a.Nonce,
+			}
+		}
+		if strings.Contains(string(cfg.TracerConfig), `"diffMode":true`) {
+			return map[string]interface{}{"pre": pre, "post": pre}, nil
+		}
+		return pre, nil
+	case "":
+		logs := []map[string]interface{}{}
+		gas := tx.Gas.UInt64() - 21000
+		for pc, op := range []string{"PUSH1", "PUSH1", "MSTORE", "STOP"} {
+			logs = append(logs, map[string]interface{}{
+				"pc": pc * 2, "op": op, "gas": gas, "gasCost": 3, "depth": 1, "stack": []string{},
+			})
+			gas -= 3
+		}
+		return map[string]interface{}{"gas": gasUsed, "failed": false, "returnValue": "", "structLogs": logs}, nil
+	}
+	return nil, &Error{Code: -32000, Message: fmt.Sprintf("unsupported tracer %s", cfg.Tracer)}
+}
+
+// proofList collects the nodes of a merkle proof from the root to the leaf
+type proofList []string
+
+func (l *proofList) Put(key []byte, value []byte) error {
+	*l = append(*l, hexutil.Encode(value))
+	return nil
+}
+
+func (l *proofList) Delete(key []byte) error {
+	return nil
+}
+
+func prove(t *trie.Trie, key []byte) []string {
+	proof := proofList{}
+	t.Prove(key, 0, &proof)
+	return proof
+}
@@ -0,0 +1,378 @@
+// Package nodetest provides an in-process ethereum node serving scripted JSON-RPC responses over http and websocket.
+// It answers from a deterministic fixture chain so the packages using the node client can be tested without network.
+package nodetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"github.com/gorilla/websocket"
+)
+
+// Handler answers a JSON-RPC request, an error that is not an *Error is returned as an internal error
+type Handler func(params jsonrpc.Params) (interface{}, error)
+
+// Error is a JSON-RPC error returned by the node
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+	// HTTPStatus is the status of the http response carrying the error, 200 when empty.
+	// It only applies to requests that are not batched.
+	HTTPStatus int `json:"-"`
+	// RetryAfter is sent in the Retry-After header with HTTPStatus
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Errors returned by the fixture handlers, they can be scripted with Fail
+var (
+	ErrMethodNotFound    = &Error{Code: -32601, Message: "the method does not exist/is not available"}
+	ErrInvalidParams     = &Error{Code: -32602, Message: "invalid argument 0: hex string without 0x prefix"}
+	ErrInternal          = &Error{Code: -32603, Message: "internal error"}
+	ErrHeaderNotFound    = &Error{Code: -32000, Message: "header not found"}
+	ErrNonceTooLow       = &Error{Code: -32000, Message: "nonce too low"}
+	ErrTimeout           = &Error{Code: -32000, Message: "execution aborted (timeout = 5s)"}
+	ErrRateLimited       = &Error{Code: -32005, Message: "daily request count exceeded, request rate limited", HTTPStatus: http.StatusTooManyRequests}
+	ErrUpstreamDown      = &Error{Code: -32603, Message: "upstream unavailable", HTTPStatus: http.StatusBadGateway}
+	ErrExecutionReverted = &Error{
+		Code:    3,
+		Message: "execution reverted: insufficient balance",
+		// Error("insufficient balance")
+		Data: "0x08c379a0" +
+			"0000000000000000000000000000000000000000000000000000000000000020" +
+			"0000000000000000000000000000000000000000000000000000000000000014" +
+			"696e73756666696369656e742062616c616e6365000000000000000000000000",
+	}
+)
+
+// Server is a fake ethereum node listening on a local port.
+// Every method of the fixture chain has a default handler, tests can replace them with Handle and Fail.
+type Server struct {
+	*httptest.Server
+	// WSURL is the websocket endpoint of the node, URL is the http one
+	WSURL string
+	Chain *Chain
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	calls    map[string]int
+	subs     map[string]*subscription
+	conns    map[*wsConn]struct{}
+	nextSub  uint64
+}
+
+// subscription is an eth_subscribe of a websocket connection
+type subscription struct {
+	id     string
+	kind   string
+	filter eth.LogFilter
+	conn   *wsConn
+}
+
+// wsConn serializes the writes of responses and notifications on a websocket
+type wsConn struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func (c *wsConn) write(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteJSON(v)
+}
+
+var upgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+
+// NewServer starts a node serving the chain, a new fixture chain is used if chain is nil.
+// The caller must Close the server.
+func NewServer(chain *Chain) *Server {
+	if chain == nil {
+		chain = NewChain()
+	}
+	s := &Server{
+		Chain:    chain,
+		handlers: make(map[string]Handler),
+		calls:    make(map[string]int),
+		subs:     make(map[string]*subscription),
+		conns:    make(map[*wsConn]struct{}),
+	}
+	for method, h := range chain.handlers() {
+		s.handlers[method] = h
+	}
+	s.Server = httptest.NewServer(s)
+	s.WSURL = "ws" + strings.TrimPrefix(s.URL, "http")
+	return s
+}
+
+// Close closes the websocket connections and stops the server
+func (s *Server) Close() {
+	s.mu.Lock()
+	for c := range s.conns {
+		c.conn.Close()
+	}
+	s.mu.Unlock()
+	s.Server.Close()
+}
+
+// Handle replaces the handler of a method
+func (s *Server) Handle(method string, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = h
+}
+
+// Fail makes every request of the method fail with err
+func (s *Server) Fail(method string, err error) {
+	s.Handle(method, func(jsonrpc.Params) (interface{}, error) { return nil, err })
+}
+
+// Calls returns the number of requests of the method served, batched or not
+func (s *Server) Calls(method string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.calls[method]
+}
+
+// Mine appends a block to the chain and notifies the subscribers
+func (s *Server) Mine() *eth.Block {
+	b := s.Chain.Mine()
+	s.notify([]*eth.Block{b})
+	return b
+}
+
+// Reorg replaces the last depth blocks of the chain by a longer fork and notifies the subscribers of the new blocks
+func (s *Server) Reorg(depth int) []*eth.Block {
+	blocks := s.Chain.Reorg(depth)
+	s.notify(blocks)
+	return blocks
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		s.serveWS(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var requests []*jsonrpc.Request
+		if err := json.Unmarshal(body, &requests); err != nil {
+			json.NewEncoder(w).Encode(parseError())
+			return
+		}
+		responses := make([]*jsonrpc.Response, len(requests))
+		for i, req := range requests {
+			responses[i], _ = s.dispatch(req, nil)
+		}
+		json.NewEncoder(w).Encode(responses)
+		return
+	}
+
+	req := jsonrpc.Request{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		json.NewEncoder(w).Encode(parseError())
+		return
+	}
+	res, e := s.dispatch(&req, nil)
+	if e != nil && e.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(e.RetryAfter.Seconds())))
+	}
+	if e != nil && e.HTTPStatus != 0 {
+		w.WriteHeader(e.HTTPStatus)
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) serveWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &wsConn{conn: conn}
+	s.mu.Lock()
+	s.conns[c] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		for id, sub := range s.subs {
+			if sub.conn == c {
+				delete(s.subs, id)
+			}
+		}
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		req := jsonrpc.Request{}
+		if err := json.Unmarshal(msg, &req); err != nil {
+			if c.write(parseError()) != nil {
+				return
+			}
+			continue
+		}
+		res, _ := s.dispatch(&req, c)
+		if c.write(res) != nil {
+			return
+		}
+	}
+}
+
+// dispatch runs the handler of the request, it returns the error of the response if it has one
+func (s *Server) dispatch(req *jsonrpc.Request, conn *wsConn) (*jsonrpc.Response, *Error) {
+	var result interface{}
+	var err error
+	switch req.Method {
+	case "eth_subscribe":
+		s.count(req.Method)
+		result, err = s.subscribe(req.Params, conn)
+	case "eth_unsubscribe":
+		s.count(req.Method)
+		result, err = s.unsubscribe(req.Params, conn)
+	default:
+		h := s.count(req.Method)
+		if h == nil {
+			err = ErrMethodNotFound
+		} else {
+			result, err = h(req.Params)
+		}
+	}
+
+	res := &jsonrpc.Response{ID: req.ID, Result: result}
+	if err == nil {
+		return res, nil
+	}
+	e, ok := err.(*Error)
+	if !ok {
+		e = &Error{Code: ErrInternal.Code, Message: err.Error()}
+	}
+	res.Result = nil
+	res.Error = e
+	return res, e
+}
+
+// count records the call and returns the handler of the method
+func (s *Server) count(method string) Handler {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[method]++
+	return s.handlers[method]
+}
+
+func (s *Server) subscribe(params jsonrpc.Params, conn *wsConn) (interface{}, error) {
+	if conn == nil {
+		return nil, &Error{Code: -32601, Message: "notifications not supported"}
+	}
+	var kind string
+	if err := params.UnmarshalSingleParam(0, &kind); err != nil {
+		return nil, ErrInvalidParams
+	}
+	sub := &subscription{kind: kind, conn: conn}
+	switch kind {
+	case "newHeads":
+	case "logs":
+		if len(params) > 1 {
+			if err := params.UnmarshalSingleParam(1, &sub.filter); err != nil {
+				return nil, ErrInvalidParams
+			}
+		}
+	default:
+		return nil, &Error{Code: -32602, Message: fmt.Sprintf("no %q subscription in eth namespace", kind)}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextSub++
+	sub.id = fmt.Sprintf("0x%x", s.nextSub)
+	s.subs[sub.id] = sub
+	return sub.id, nil
+}
+
+func (s *Server) unsubscribe(params jsonrpc.Params, conn *wsConn) (interface{}, error) {
+	var id string
+	if err := params.UnmarshalSingleParam(0, &id); err != nil {
+		return nil, ErrInvalidParams
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subs[id]
+	if !ok || sub.conn != conn {
+		return false, nil
+	}
+	delete(s.subs, id)
+	return true, nil
+}
+
+// notify sends the new heads and the logs of the blocks to the subscribers
+func (s *Server) notify(blocks []*eth.Block) {
+	s.mu.RLock()
+	subs := make([]*subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		subs = append(subs, sub)
+	}
+	s.mu.RUnlock()
+
+	for _, b := range blocks {
+		head := eth.NewHeadsResult{}
+		head.FromBlock(b)
+		logs := s.Chain.blockLogs(*b.Hash)
+		for _, sub := range subs {
+			switch sub.kind {
+			case "newHeads":
+				sub.send(&head)
+			case "logs":
+				for _, l := range logs {
+					if matchLog(&sub.filter, l) {
+						sub.send(l)
+					}
+				}
+			}
+		}
+	}
+}
+
+func (sub *subscription) send(result interface{}) {
+	params, err := json.Marshal(struct {
+		Subscription string      `json:"subscription"`
+		Result       interface{} `json:"result"`
+	}{sub.id, result})
+	if err != nil {
+		return
+	}
+	// the connection may be closing, the notification is then lost as with a real node
+	sub.conn.write(jsonrpc.Notification{Method: "eth_subscription", Params: params})
+}
+
+func parseError() *jsonrpc.Response {
+	return &jsonrpc.Response{ID: jsonrpc.ID{}, Error: &Error{Code: -32700, Message: "parse error"}}
+}
@@ -0,0 +1,154 @@
+package nodetest
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	ethnode "github.com/INFURA/go-ethlibs/node"
+	"github.com/INFURA/infra-test-benjamin-mateo/node"
+	"go.uber.org/zap"
+)
+
+func TestServerHTTP(t *testing.T) {
+	srv := NewServer(nil)
+	defer srv.Close()
+	// the upstream is paused after the scripted rate limit, requests wait for it
+	pool, err := node.NewPool(node.PoolConfig{URLs: []string{srv.URL}, HealthInterval: time.Hour, MaxWait: 2 * time.Second}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	client, err := node.GetNewPooledClient(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	head, err := client.BlockNumber(ctx)
+	if err != nil || head != FirstBlock+Length-1 {
+		t.Fatalf("head: got %d %v want %d", head, err, FirstBlock+Length-1)
+	}
+
+	b, err := client.BlockByNumber(ctx, FirstBlock+5, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *b.Hash != *srv.Chain.Block(FirstBlock + 5).Hash || len(b.Transactions) != TxsPerBlock || !b.Transactions[0].Populated {
+		t.Errorf("unexpected block %s with %d transactions", *b.Hash, len(b.Transactions))
+	}
+
+	tx := srv.Chain.Transaction(FirstBlock+5, 1)
+	receipt, err := client.GetTransactionReceipt(ctx, string(tx.Hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.BlockHash != *b.Hash || len(receipt.Logs) != 1 || receipt.Fee() == nil {
+		t.Errorf("unexpected receipt %+v", receipt)
+	}
+
+	from := eth.MustBlockNumberOrTag("0x8b6492")
+	to := eth.MustBlockNumberOrTag("0x8b649c")
+	logs, err := client.Logs(ctx, eth.LogFilter{FromBlock: from, ToBlock: to, Topics: [][]eth.Topic{{eth.Topic(TransferTopic)}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 11*(TxsPerBlock-1) {
+		t.Errorf("got %d logs want %d", len(logs), 11*(TxsPerBlock-1))
+	}
+
+	keys := []eth.Quantity{eth.QuantityFromUInt64(0), eth.QuantityFromUInt64(7)}
+	for _, addr := range []string{Token.Hex(), Sender.Hex(), "0x00000000000000000000000000000000000000aa"} {
+		proof, err := client.GetVerifiedProof(ctx, addr, keys, node.LatestBlock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !proof.Verification.Valid {
+			t.Errorf("invalid proof of %s: %+v", addr, proof.Verification)
+		}
+	}
+
+	if _, err := client.CallContract(ctx, node.CallParams{To: eth.Data(Token.Hex()), Data: "0x12345678"}, node.LatestBlock); err == nil {
+		t.Error("unknown call should revert")
+	}
+
+	srv.Fail("eth_gasPrice", ErrRateLimited)
+	if _, err := client.GetGasPrice(ctx); err == nil || !strings.Contains(err.Error(), "rate limited") {
+		t.Errorf("scripted error: got %v", err)
+	}
+	if n := srv.Calls("eth_gasPrice"); n != 1 {
+		t.Errorf("got %d eth_gasPrice calls want 1", n)
+	}
+	srv.Handle("eth_gasPrice", func(jsonrpc.Params) (interface{}, error) { return "0x2a", nil })
+	if p, err := client.GetGasPrice(ctx); err != nil || p.Uint64() != 42 {
+		t.Errorf("scripted result: got %v %v", p, err)
+	}
+
+	if _, err := client.TransactionByHash(ctx, "0x00000000000000000000000000000000000000000000000000000000000000aa"); err != ethnode.ErrTransactionNotFound {
+		t.Errorf("unknown transaction: got %v", err)
+	}
+}
+
+func TestServerWebsocket(t *testing.T) {
+	srv := NewServer(nil)
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client, err := ethnode.NewClient(ctx, srv.WSURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := client.Subscribe(ctx, &jsonrpc.Request{ID: jsonrpc.ID{Num: 1}, Method: "eth_subscribe", Params: jsonrpc.MustParams("newHeads")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs, err := client.Subscribe(ctx, &jsonrpc.Request{ID: jsonrpc.ID{Num: 2}, Method: "eth_subscribe", Params: jsonrpc.MustParams("logs", map[string]interface{}{"address": Token.Hex()})})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mined := srv.Mine()
+	fork := srv.Reorg(1)
+	want := map[eth.Hash]uint64{*mined.Hash: mined.Number.UInt64()}
+	for _, b := range fork {
+		want[*b.Hash] = b.Number.UInt64()
+	}
+	// notifications are delivered concurrently by the client, their order is not checked
+	got := map[eth.Hash]uint64{}
+	for range want {
+		select {
+		case n := <-sub.Ch():
+			head := eth.NewHeadsResult{}
+			if err := n.UnmarshalParamsInto(&struct {
+				Result *eth.NewHeadsResult `json:"result"`
+			}{&head}); err != nil {
+				t.Fatal(err)
+			}
+			got[head.Hash] = head.Number.UInt64()
+		case <-time.After(5 * time.Second):
+			t.Fatal("no new head")
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got heads %v want %v", got, want)
+	}
+	for i := 0; i < len(want)*(TxsPerBlock-1); i++ {
+		select {
+		case <-logs.Ch():
+		case <-time.After(5 * time.Second):
+			t.Fatal("no log")
+		}
+	}
+
+	if b, err := client.BlockByHash(ctx, string(*mined.Hash), false); err != nil || b.Number.UInt64() != mined.Number.UInt64() {
+		t.Errorf("block reorganized away should be served by hash: %v", err)
+	}
+	if head, err := client.BlockNumber(ctx); err != nil || head != mined.Number.UInt64()+1 {
+		t.Errorf("head after reorg: got %d %v", head, err)
+	}
+}
@@ -0,0 +1,457 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"github.com/INFURA/go-ethlibs/node"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ErrNoUpstream is returned when no upstream of the pool could serve a request
+var ErrNoUpstream = errors.New("no upstream node available")
+
+// PoolConfig configures a pool of upstream ethereum nodes
+type PoolConfig struct {
+	// URLs of the upstream nodes (http, https, ws or wss)
+	URLs []string
+	// HealthInterval is the delay between two health probes
+	HealthInterval time.Duration
+	// HealthTimeout bounds the duration of a single probe
+	HealthTimeout time.Duration
+	// MaxLag is the number of blocks an upstream can be behind the best known head before being taken out of rotation
+	MaxLag uint64
+	// RateLimit is the request budget of each upstream
+	RateLimit RateLimit
+	// MaxWait is how long a request waits for budget when every upstream is exhausted before failing with a RateLimitError
+	MaxWait time.Duration
+}
+
+// UpstreamStatus is a snapshot of the health of an upstream node
+type UpstreamStatus struct {
+	URL       string    `json:"url"`
+	Healthy   bool      `json:"healthy"`
+	Height    uint64    `json:"height"`
+	Error     string    `json:"error,omitempty"`
+	LastCheck time.Time `json:"lastCheck"`
+	Budget    Budget    `json:"budget"`
+}
+
+// upstream is a single node of the pool
+type upstream struct {
+	url     string
+	batcher *httpBatcher
+	limiter *bucket
+
+	mu        sync.RWMutex
+	client    node.Client
+	healthy   bool
+	height    uint64
+	lastErr   error
+	lastCheck time.Time
+}
+
+// Pool routes JSON-RPC requests to healthy upstream nodes.
+// Health is probed in background with eth_blockNumber, dead or lagging nodes are taken out of rotation
+// and requests fail over to the next healthy node on transport errors.
+// It implements the go-ethlibs Requester and Subscriber interfaces.
+type Pool struct {
+	cfg       PoolConfig
+	upstreams []*upstream
+	next      uint32
+	logger    *zap.SugaredLogger
+	inflight  *coalescer
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewPool creates a pool of upstreams, runs a first health check and starts the background probes
+func NewPool(cfg PoolConfig, logger *zap.SugaredLogger) (*Pool, error) {
+	if len(cfg.URLs) == 0 {
+		return nil, errors.New("no upstream url configured")
+	}
+	if cfg.HealthInterval <= 0 {
+		cfg.HealthInterval = 5 * time.Second
+	}
+	if cfg.HealthTimeout <= 0 {
+		cfg.HealthTimeout = cfg.HealthInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		cfg:      cfg,
+		logger:   logger,
+		inflight: newCoalescer(),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	for _, u := range cfg.URLs {
+		up := &upstream{url: u, limiter: newBucket(cfg.RateLimit, time.Now)}
+		if strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
+			up.batcher = newHTTPBatcher(u)
+		}
+		p.upstreams = append(p.upstreams, up)
+	}
+
+	p.check()
+	p.wg.Add(1)
+	go p.run()
+
+	return p, nil
+}
+
+// Close stops the health probes and closes the connections to the upstreams.
+// The bidirectional connections are closed with the context they were dialed with.
+func (p *Pool) Close() {
+	p.cancel()
+	p.wg.Wait()
+	for _, u := range p.upstreams {
+		u.setClient(nil)
+		if u.batcher != nil {
+			u.batcher.client.CloseIdleConnections()
+		}
+	}
+}
+
+// Request sends the request to a healthy upstream and fails over to the next one on transport error or rate limit.
+// Unhealthy upstreams are only tried as a last resort.
+// Identical requests in flight share a single round trip, see Coalesced.
+func (p *Pool) Request(ctx context.Context, r *jsonrpc.Request) (*jsonrpc.RawResponse, error) {
+	key, ok := requestKey(r)
+	if !ok {
+		return p.request(ctx, r)
+	}
+	res, err := p.inflight.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return p.request(ctx, r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return withID(res.(*jsonrpc.RawResponse), r.ID), nil
+}
+
+func (p *Pool) request(ctx context.Context, r *jsonrpc.Request) (*jsonrpc.RawResponse, error) {
+	var res *jsonrpc.RawResponse
+	err := p.send(ctx, 1, r.Method, func(u *upstream) error {
+		var err error
+		if u.batcher != nil {
+			res, err = u.batcher.Request(ctx, r)
+		} else {
+			res, err = u.getClient().Request(ctx, r)
+		}
+		if err == nil && res.Error != nil {
+			// websocket upstreams can only report the limit in the JSON-RPC error
+			if rpcErr := parseRPCError(*res.Error).(*RPCError); rpcErr.Kind == ErrRateLimited {
+				return &RateLimitError{Message: rpcErr.Message, RPC: rpcErr}
+			}
+		}
+		return err
+	})
+	return res, err
+}
+
+// BatchRequest sends the requests in a single round trip to a healthy upstream and fails over to the next one on transport error or rate limit.
+// Upstreams that can't batch requests over their transport, or that reject the batch, get every request concurrently.
+// Identical batches in flight share a single round trip.
+func (p *Pool) BatchRequest(ctx context.Context, requests []*jsonrpc.Request) ([]*jsonrpc.RawResponse, error) {
+	key, ok := requestKey(requests...)
+	if !ok {
+		return p.batchRequest(ctx, requests)
+	}
+	res, err := p.inflight.do(ctx, "batch\n"+key, func(ctx context.Context) (interface{}, error) {
+		responses, err := p.batchRequest(ctx, requests)
+		return batchResult{requests, responses}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.(batchResult).forRequests(requests), nil
+}
+
+func (p *Pool) batchRequest(ctx context.Context, requests []*jsonrpc.Request) ([]*jsonrpc.RawResponse, error) {
+	var res []*jsonrpc.RawResponse
+	err := p.send(ctx, len(requests), fmt.Sprintf("batch of %d requests", len(requests)), func(u *upstream) error {
+		var err error
+		if u.batcher != nil {
+			res, err = u.batcher.BatchRequest(ctx, requests)
+			if rejected, ok := err.(*batchRejectedError); ok {
+				p.logger.Infof("upstream %s rejected a batch of %d requests, sending them one by one err:%s", u.url, len(requests), rejected)
+				res, err = requestEach(ctx, u.batcher, requests)
+			}
+		} else {
+			res, err = requestEach(ctx, u.getClient(), requests)
+		}
+		return err
+	})
+	return res, err
+}
+
+// Coalesced returns the number of requests that shared the round trip of an identical request in flight
+func (p *Pool) Coalesced() uint64 {
+	return atomic.LoadUint64(&p.inflight.coalesced)
+}
+
+// send calls do with the candidates in turn until one succeeds.
+// Upstreams without budget for n requests are skipped, when none has budget send waits up to MaxWait for one.
+// An upstream that rate limits the request is paused, one that fails is taken out of rotation.
+func (p *Pool) send(ctx context.Context, n int, what string, do func(u *upstream) error) error {
+	for {
+		var lastErr error
+		wait := time.Duration(-1)
+		for _, u := range p.candidates() {
+			if u.batcher == nil && u.getClient() == nil {
+				continue
+			}
+			if d := u.limiter.reserve(n); d > 0 {
+				if wait < 0 || d < wait {
+					wait = d
+				}
+				continue
+			}
+			err := do(u)
+			if err == nil {
+				return nil
+			}
+			if ctx.Err() != nil {
+				return err
+			}
+			lastErr = err
+			if limited, ok := errors.Cause(err).(*RateLimitError); ok {
+				u.limiter.pause(limited.RetryAfter)
+				p.logger.Warnf("upstream %s rate limited %s, failing over err:%s", u.url, what, err)
+				continue
+			}
+			u.setFailure(err)
+			p.logger.Warnf("upstream %s failed %s, failing over err:%s", u.url, what, err)
+		}
+
+		switch {
+		case lastErr != nil:
+			return lastErr
+		case wait < 0:
+			return ErrNoUpstream
+		case wait > p.cfg.MaxWait:
+			return &RateLimitError{RetryAfter: wait, Message: "request budget of the upstreams exhausted"}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Subscribe subscribes on a healthy bidirectional upstream
+func (p *Pool) Subscribe(ctx context.Context, r *jsonrpc.Request) (node.Subscription, error) {
+	for _, u := range p.candidates() {
+		c := u.getClient()
+		if c == nil || !c.IsBidirectional() {
+			continue
+		}
+		return c.Subscribe(ctx, r)
+	}
+	return nil, errors.New("subscriptions not supported by any upstream")
+}
+
+// IsBidirectional returns true if at least one upstream supports subscriptions
+func (p *Pool) IsBidirectional() bool {
+	for _, u := range p.upstreams {
+		if c := u.getClient(); c != nil && c.IsBidirectional() {
+			return true
+		}
+	}
+	return false
+}
+
+// Budget returns the remaining budget of all the upstreams together, the pool is paused until one of its upstreams is not
+func (p *Pool) Budget() Budget {
+	total := Budget{}
+	for i, u := range p.upstreams {
+		b := u.limiter.budget()
+		if i == 0 {
+			total = b
+			continue
+		}
+		total.Tokens = addBudget(total.Tokens, b.Tokens)
+		total.Daily = addBudget(total.Daily, b.Daily)
+		if total.PausedUntil != nil && (b.PausedUntil == nil || b.PausedUntil.Before(*total.PausedUntil)) {
+			total.PausedUntil = b.PausedUntil
+		}
+	}
+	return total
+}
+
+// addBudget sums two budgets, -1 is unlimited
+func addBudget(a, b int) int {
+	if a < 0 || b < 0 {
+		return -1
+	}
+	return a + b
+}
+
+// Status returns the health of every upstream
+func (p *Pool) Status() []UpstreamStatus {
+	ret := make([]UpstreamStatus, 0, len(p.upstreams))
+	for _, u := range p.upstreams {
+		ret = append(ret, u.status())
+	}
+	return ret
+}
+
+// candidates returns the healthy upstreams in round robin order followed by the unhealthy ones
+func (p *Pool) candidates() []*upstream {
+	n := len(p.upstreams)
+	start := int(atomic.AddUint32(&p.next, 1)) % n
+	healthy := make([]*upstream, 0, n)
+	var unhealthy []*upstream
+	for i := 0; i < n; i++ {
+		u := p.upstreams[(start+i)%n]
+		if u.isHealthy() {
+			healthy = append(healthy, u)
+		} else {
+			unhealthy = append(unhealthy, u)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// run probes the upstreams every HealthInterval until the pool is closed
+func (p *Pool) run() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.cfg.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			p.check()
+		}
+	}
+}
+
+// check probes all the upstreams concurrently then takes out of rotation the dead and lagging ones
+func (p *Pool) check() {
+	heights := make([]uint64, len(p.upstreams))
+	errs := make([]error, len(p.upstreams))
+
+	var wg sync.WaitGroup
+	for i, u := range p.upstreams {
+		wg.Add(1)
+		go func(i int, u *upstream) {
+			defer wg.Done()
+			heights[i], errs[i] = p.probe(u)
+		}(i, u)
+	}
+	wg.Wait()
+
+	var best uint64
+	for i := range p.upstreams {
+		if errs[i] == nil && heights[i] > best {
+			best = heights[i]
+		}
+	}
+
+	for i, u := range p.upstreams {
+		err := errs[i]
+		if err == nil && best-heights[i] > p.cfg.MaxLag {
+			err = errors.Errorf("lagging %d blocks behind head %d", best-heights[i], best)
+		}
+		wasHealthy := u.setProbe(heights[i], err)
+		switch {
+		case wasHealthy && err != nil:
+			p.logger.Warnf("upstream %s out of rotation err:%s", u.url, err)
+		case !wasHealthy && err == nil:
+			p.logger.Infof("upstream %s in rotation at height %d", u.url, heights[i])
+		}
+	}
+}
+
+// probe dials the upstream if needed and returns its head
+func (p *Pool) probe(u *upstream) (uint64, error) {
+	c := u.getClient()
+	if c == nil {
+		var err error
+		c, err = node.NewClient(p.ctx, u.url)
+		if err != nil {
+			return 0, errors.Wrap(err, "could not connect")
+		}
+		u.setClient(c)
+	}
+	ctx, cancel := context.WithTimeout(p.ctx, p.cfg.HealthTimeout)
+	defer cancel()
+	// probes count against the quota of the provider but never wait for budget
+	u.limiter.spend(1)
+	height, err := c.BlockNumber(ctx)
+	if err != nil && c.IsBidirectional() {
+		// the connection may be broken, dial again on next probe
+		u.setClient(nil)
+	}
+	return height, err
+}
+
+func (u *upstream) getClient() node.Client {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.client
+}
+
+func (u *upstream) setClient(c node.Client) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.client = c
+}
+
+func (u *upstream) isHealthy() bool {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.healthy
+}
+
+// setFailure takes the upstream out of rotation until the next successful probe
+func (u *upstream) setFailure(err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.healthy = false
+	u.lastErr = err
+}
+
+// setProbe records a probe result and returns the previous health
+func (u *upstream) setProbe(height uint64, err error) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	wasHealthy := u.healthy
+	u.healthy = err == nil
+	u.lastErr = err
+	u.lastCheck = time.Now()
+	if err == nil || height > 0 {
+		u.height = height
+	}
+	return wasHealthy
+}
+
+func (u *upstream) status() UpstreamStatus {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	s := UpstreamStatus{
+		URL:       u.url,
+		Healthy:   u.healthy,
+		Height:    u.height,
+		LastCheck: u.lastCheck,
+		Budget:    u.limiter.budget(),
+	}
+	if u.lastErr != nil {
+		s.Error = u.lastErr.Error()
+	}
+	return s
+}
@@ -0,0 +1,117 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"go.uber.org/zap"
+)
+
+// fakeNode answers eth_blockNumber with its height and counts the requests it served
+func fakeNode(height *uint64, served *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := jsonrpc.Request{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		atomic.AddInt32(served, 1)
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"0x%x"}`, req.ID, atomic.LoadUint64(height))
+	}))
+}
+
+func TestPool(t *testing.T) {
+	var headA, headB uint64 = 100, 100
+	var servedA, servedB int32
+	a := fakeNode(&headA, &servedA)
+	defer a.Close()
+	b := fakeNode(&headB, &servedB)
+
+	pool, err := NewPool(PoolConfig{URLs: []string{a.URL, b.URL}, HealthInterval: time.Hour, MaxLag: 2}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	client, err := GetNewPooledClient(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		name        string
+		setup       func()
+		wantHealthy []bool
+	}{
+		{"all healthy", func() {}, []bool{true, true}},
+		{"lagging node out of rotation", func() { atomic.StoreUint64(&headA, 110) }, []bool{true, false}},
+		{"caught up node back in rotation", func() { atomic.StoreUint64(&headB, 109) }, []bool{true, true}},
+		{"dead node out of rotation", func() { b.Close() }, []bool{true, false}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setup()
+			pool.check()
+			for i, st := range pool.Status() {
+				if st.Healthy != tc.wantHealthy[i] {
+					t.Errorf("node %d healthy: got %v want %v err:%s", i, st.Healthy, tc.wantHealthy[i], st.Error)
+				}
+			}
+
+			atomic.StoreInt32(&servedA, 0)
+			atomic.StoreInt32(&servedB, 0)
+			for i := 0; i < 4; i++ {
+				if _, err := client.BlockNumber(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			sa, sb := atomic.LoadInt32(&servedA), atomic.LoadInt32(&servedB)
+			if !tc.wantHealthy[1] && sb != 0 {
+				t.Errorf("node out of rotation served %d requests", sb)
+			}
+			if tc.wantHealthy[0] && tc.wantHealthy[1] && (sa == 0 || sb == 0) {
+				t.Errorf("requests not balanced: %d %d", sa, sb)
+			}
+		})
+	}
+}
+
+func TestPoolFailover(t *testing.T) {
+	var head uint64 = 100
+	var served, servedDead int32
+	alive := fakeNode(&head, &served)
+	defer alive.Close()
+	dead := fakeNode(&head, &servedDead)
+
+	pool, err := NewPool(PoolConfig{URLs: []string{dead.URL, alive.URL}, HealthInterval: time.Hour}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	client, err := GetNewPooledClient(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the node dies between two health checks
+	dead.Close()
+	for i := 0; i < 2; i++ {
+		b, err := client.BlockNumber(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b != head {
+			t.Errorf("got block %d want %d", b, head)
+		}
+	}
+	if pool.Status()[0].Healthy {
+		t.Error("failing node should be out of rotation")
+	}
+}
@@ -0,0 +1,246 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"github.com/INFURA/go-ethlibs/node"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/pkg/errors"
+)
+
+var (
+	// emptyRoot is the root of an empty trie, the storage root of an account without storage
+	emptyRoot = common.HexToHash("0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+	// emptyCodeHash is the code hash of an account without code
+	emptyCodeHash = crypto.Keccak256Hash(nil)
+)
+
+// StorageProof is the merkle proof of a storage slot
+type StorageProof struct {
+	Key   eth.Data     `json:"key"`
+	Value eth.Quantity `json:"value"`
+	Proof []eth.Data   `json:"proof"`
+}
+
+// AccountProof is the result of eth_getProof
+type AccountProof struct {
+	Address      eth.Address    `json:"address"`
+	AccountProof []eth.Data     `json:"accountProof"`
+	Balance      eth.Quantity   `json:"balance"`
+	CodeHash     eth.Hash       `json:"codeHash"`
+	Nonce        eth.Quantity   `json:"nonce"`
+	StorageHash  eth.Hash       `json:"storageHash"`
+	StorageProof []StorageProof `json:"storageProof"`
+}
+
+// ProofVerification is the result of the local verification of a proof, the errors are empty when valid
+type ProofVerification struct {
+	Valid        bool                `json:"valid"`
+	AccountError string              `json:"accountError,omitempty"`
+	StorageError string              `json:"storageError,omitempty"`
+	Storage      []StorageValidation `json:"storage,omitempty"`
+}
+
+// StorageValidation is the result of the verification of a storage proof
+type StorageValidation struct {
+	Key   eth.Data `json:"key"`
+	Valid bool     `json:"valid"`
+	Error string   `json:"error,omitempty"`
+}
+
+// VerifiedProof is a proof with the block it was verified against
+type VerifiedProof struct {
+	BlockNumber  uint64
+	BlockHash    eth.Hash
+	StateRoot    eth.Data32
+	Proof        *AccountProof
+	Verification ProofVerification
+}
+
+// rlpAccount is the account as stored in the state trie
+type rlpAccount struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     common.Hash
+	CodeHash []byte
+}
+
+// GetProof get the merkle proof of an account and of some of its storage slots from the state of the given block
+func (c *CustomClient) GetProof(ctx context.Context, address string, keys []eth.Quantity, block BlockParam) (*AccountProof, error) {
+	slots := make([]string, len(keys))
+	for i, k := range keys {
+		slots[i] = common.BigToHash(k.Big()).Hex()
+	}
+	request := jsonrpc.Request{
+		ID:     jsonrpc.ID{Num: 1},
+		Method: "eth_getProof",
+		Params: jsonrpc.MustParams(address, slots, block),
+	}
+
+	response, err := c.Request(ctx, &request)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not make  request")
+	}
+
+	if response.Error != nil {
+		return nil, parseRPCError(*response.Error)
+	}
+
+	p := AccountProof{}
+	if err := json.Unmarshal(response.Result, &p); err != nil {
+		return nil, errors.Wrap(err, "could not decode proof")
+	}
+	return &p, nil
+}
+
+// GetVerifiedProof get the proof from the state of the given block and verifies it against the state root of the block.
+// The block is resolved first and the proof is requested by its hash, so that the proof and the state root come from the same block
+// even if the block is not canonical anymore.
+// The pending block is rejected, it is not mined so its state can't be requested by number.
+func (c *CustomClient) GetVerifiedProof(ctx context.Context, address string, keys []eth.Quantity, block BlockParam) (*VerifiedProof, error) {
+	var b *eth.Block
+	var err error
+	switch {
+	case block.Hash != nil:
+		b, err = c.BlockByHash(ctx, string(*block.Hash), false)
+	case block.Number != nil:
+		b, err = c.BlockByNumber(ctx, block.Number.UInt64(), false)
+	case block.Tag == eth.TagPending:
+		return nil, errors.Wrap(ErrInvalidParams, "the proof of the pending block can't be verified")
+	default:
+		b, err = c.BlockByTag(ctx, block.String(), false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if b == nil || b.Number == nil || b.Hash == nil {
+		return nil, node.ErrBlockNotFound
+	}
+
+	p, err := c.GetProof(ctx, address, keys, BlockParam{Hash: b.Hash, RequireCanonical: block.RequireCanonical})
+	if err != nil {
+		return nil, err
+	}
+	root := common.HexToHash(string(b.StateRoot))
+	return &VerifiedProof{
+		BlockNumber:  b.Number.UInt64(),
+		BlockHash:    *b.Hash,
+		StateRoot:    b.StateRoot,
+		Proof:        p,
+		Verification: p.Verify(root, keys),
+	}, nil
+}
+
+// Verify checks the account proof against the state root and the storage proofs of the requested keys against the storage root of the account
+func (p *AccountProof) Verify(stateRoot common.Hash, keys []eth.Quantity) ProofVerification {
+	v := ProofVerification{Valid: true}
+	if err := p.verifyAccount(stateRoot); err != nil {
+		v.Valid = false
+		v.AccountError = err.Error()
+	}
+
+	if len(p.StorageProof) != len(keys) {
+		v.Valid = false
+		v.StorageError = errors.Errorf("%d storage proofs for %d keys", len(p.StorageProof), len(keys)).Error()
+		return v
+	}
+	storageRoot := common.HexToHash(string(p.StorageHash))
+	if storageRoot == (common.Hash{}) {
+		// some nodes return a zero hash for accounts without storage
+		storageRoot = emptyRoot
+	}
+	for i, sp := range p.StorageProof {
+		res := StorageValidation{Key: sp.Key, Valid: true}
+		if err := sp.verify(storageRoot, common.BigToHash(keys[i].Big())); err != nil {
+			res.Valid = false
+			res.Error = err.Error()
+			v.Valid = false
+		}
+		v.Storage = append(v.Storage, res)
+	}
+	return v
+}
+
+func (p *AccountProof) verifyAccount(stateRoot common.Hash) error {
+	address := common.HexToAddress(string(p.Address))
+	value, err := verifyProof(stateRoot, crypto.Keccak256(address.Bytes()), p.AccountProof)
+	if err != nil {
+		return err
+	}
+
+	codeHash := common.HexToHash(string(p.CodeHash))
+	storageHash := common.HexToHash(string(p.StorageHash))
+	if value == nil {
+		// the account doesn't exist, the node must return an empty account
+		if p.Nonce.UInt64() != 0 || p.Balance.Big().Sign() != 0 ||
+			(codeHash != emptyCodeHash && codeHash != common.Hash{}) || (storageHash != emptyRoot && storageHash != common.Hash{}) {
+			return errors.New("the proof shows the account doesn't exist but the node returned a non empty account")
+		}
+		return nil
+	}
+
+	a := rlpAccount{}
+	if err := rlp.DecodeBytes(value, &a); err != nil {
+		return errors.Wrap(err, "invalid account in the proof")
+	}
+	switch {
+	case a.Nonce != p.Nonce.UInt64():
+		return errors.Errorf("nonce mismatch: proof %d node %d", a.Nonce, p.Nonce.UInt64())
+	case a.Balance.Cmp(p.Balance.Big()) != 0:
+		return errors.Errorf("balance mismatch: proof %s node %s", a.Balance, p.Balance.Big())
+	case a.Root != storageHash:
+		return errors.Errorf("storage hash mismatch: proof %s node %s", a.Root.Hex(), storageHash.Hex())
+	case !bytes.Equal(a.CodeHash, codeHash.Bytes()):
+		return errors.Errorf("code hash mismatch: proof %s node %s", hexutil.Encode(a.CodeHash), codeHash.Hex())
+	}
+	return nil
+}
+
+func (sp *StorageProof) verify(storageRoot common.Hash, key common.Hash) error {
+	value, err := verifyProof(storageRoot, crypto.Keccak256(key.Bytes()), sp.Proof)
+	if err != nil {
+		return err
+	}
+	got := new(big.Int)
+	if value != nil {
+		// slots are stored as RLP encoded integers
+		var content []byte
+		if err := rlp.DecodeBytes(value, &content); err != nil {
+			return errors.Wrap(err, "invalid storage value in the proof")
+		}
+		got.SetBytes(content)
+	}
+	if got.Cmp(sp.Value.Big()) != 0 {
+		return errors.Errorf("value mismatch: proof 0x%x node 0x%x", got, sp.Value.Big())
+	}
+	return nil
+}
+
+// verifyProof returns the value of the key proven by the nodes, nil if the proof shows the key is absent
+func verifyProof(root common.Hash, key []byte, proof []eth.Data) ([]byte, error) {
+	if len(proof) == 0 && root == emptyRoot {
+		return nil, nil
+	}
+	db := memorydb.New()
+	for _, n := range proof {
+		b, err := hexutil.Decode(string(n))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid proof node")
+		}
+		if err := db.Put(crypto.Keccak256(b), b); err != nil {
+			return nil, err
+		}
+	}
+	value, _, err := trie.VerifyProof(root, key, db)
+	return value, err
+}
@@ -0,0 +1,143 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"github.com/INFURA/infra-test-benjamin-mateo/node/nodetest"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// proofNodes collects the nodes written by trie.Prove
+type proofNodes []eth.Data
+
+func (p *proofNodes) Put(key []byte, value []byte) error {
+	*p = append(*p, eth.Data(hexutil.Encode(value)))
+	return nil
+}
+
+func (p *proofNodes) Delete(key []byte) error { return nil }
+
+func newTrie(t *testing.T) *trie.Trie {
+	tr, err := trie.New(common.Hash{}, trie.NewDatabase(memorydb.New()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+func prove(t *testing.T, tr *trie.Trie, key []byte) []eth.Data {
+	var nodes proofNodes
+	if err := tr.Prove(crypto.Keccak256(key), 0, &nodes); err != nil {
+		t.Fatal(err)
+	}
+	return nodes
+}
+
+// testProof builds a state with testAddr holding 0x2a in slot 1 and returns its proof for slots 1 and 2
+func testProof(t *testing.T) (*AccountProof, common.Hash, []eth.Quantity) {
+	storage := newTrie(t)
+	slot := common.BigToHash(big.NewInt(1))
+	v, _ := rlp.EncodeToBytes([]byte{0x2a})
+	storage.Update(crypto.Keccak256(slot.Bytes()), v)
+
+	account, _ := rlp.EncodeToBytes(rlpAccount{Nonce: 3, Balance: big.NewInt(1e18), Root: storage.Hash(), CodeHash: emptyCodeHash.Bytes()})
+	state := newTrie(t)
+	state.Update(crypto.Keccak256(testAddr.Bytes()), account)
+	state.Update(crypto.Keccak256(testTo.Bytes()), account)
+
+	keys := []eth.Quantity{eth.QuantityFromUInt64(1), eth.QuantityFromUInt64(2)}
+	p := &AccountProof{
+		Address:      eth.Address(testAddr.Hex()),
+		AccountProof: prove(t, state, testAddr.Bytes()),
+		Balance:      eth.QuantityFromBigInt(big.NewInt(1e18)),
+		CodeHash:     eth.Hash(emptyCodeHash.Hex()),
+		Nonce:        eth.QuantityFromUInt64(3),
+		StorageHash:  eth.Hash(storage.Hash().Hex()),
+		StorageProof: []StorageProof{
+			{Key: "0x1", Value: eth.QuantityFromUInt64(0x2a), Proof: prove(t, storage, slot.Bytes())},
+			{Key: "0x2", Value: eth.QuantityFromUInt64(0), Proof: prove(t, storage, common.BigToHash(big.NewInt(2)).Bytes())},
+		},
+	}
+	return p, state.Hash(), keys
+}
+
+func TestAccountProofVerify(t *testing.T) {
+	tt := []struct {
+		name       string
+		tamper     func(p *AccountProof, root *common.Hash)
+		accountErr string
+		storageErr []string
+	}{
+		{"valid", func(p *AccountProof, root *common.Hash) {}, "", []string{"", ""}},
+		{"balance lie", func(p *AccountProof, root *common.Hash) { p.Balance = eth.QuantityFromUInt64(5) }, "balance mismatch", []string{"", ""}},
+		{"storage lie", func(p *AccountProof, root *common.Hash) { p.StorageProof[0].Value = eth.QuantityFromUInt64(1) }, "", []string{"value mismatch", ""}},
+		{"absent slot lie", func(p *AccountProof, root *common.Hash) { p.StorageProof[1].Value = eth.QuantityFromUInt64(1) }, "", []string{"", "value mismatch"}},
+		{"other state root", func(p *AccountProof, root *common.Hash) { *root = emptyRoot }, "missing", []string{"", ""}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			p, root, keys := testProof(t)
+			tc.tamper(p, &root)
+			v := p.Verify(root, keys)
+			valid := tc.accountErr == "" && tc.storageErr[0] == "" && tc.storageErr[1] == ""
+			if v.Valid != valid {
+				t.Errorf("got valid %v want %v: %+v", v.Valid, valid, v)
+			}
+			if !strings.Contains(v.AccountError, tc.accountErr) || (tc.accountErr == "" && v.AccountError != "") {
+				t.Errorf("got account error %q want %q", v.AccountError, tc.accountErr)
+			}
+			for i, want := range tc.storageErr {
+				if got := v.Storage[i].Error; !strings.Contains(got, want) || (want == "" && got != "") {
+					t.Errorf("slot %d: got error %q want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetVerifiedProofBlock(t *testing.T) {
+	srv := nodetest.NewServer(nil)
+	defer srv.Close()
+	client, err := GetNewCustomClient(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requested json.RawMessage
+	srv.Handle("eth_getProof", func(p jsonrpc.Params) (interface{}, error) {
+		requested = json.RawMessage(p[2])
+		return nil, nodetest.ErrUpstreamDown
+	})
+
+	b := srv.Chain.Block(nodetest.FirstBlock)
+	tt := []struct {
+		name  string
+		block BlockParam
+		want  string
+	}{
+		{"number", BlockParam{Number: b.Number}, `{"blockHash":"` + string(*b.Hash) + `"}`},
+		{"hash", BlockParam{Hash: b.Hash, RequireCanonical: true}, `{"blockHash":"` + string(*b.Hash) + `","requireCanonical":true}`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			// the proof is requested from the block whose state root verifies it
+			if _, err := client.GetVerifiedProof(context.Background(), "0xe530441f4f73bDB6DC2fA5aF7c3fC5fD551Ec838", nil, tc.block); err == nil {
+				t.Fatal("the proof should fail")
+			}
+			if string(requested) != tc.want {
+				t.Errorf("got proof of block %s want %s", requested, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package node
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultBackoff is the pause of an upstream that rate limited a request without a Retry-After
+const defaultBackoff = time.Second
+
+// RateLimit is the request budget of an upstream, the zero value is unlimited.
+// Requests take tokens from a bucket refilled at PerSecond up to Burst, and from a daily quota reset at midnight UTC.
+// A request of a batch counts as one request like for the providers.
+type RateLimit struct {
+	// PerSecond is the rate at which the bucket refills, 0 disables the bucket
+	PerSecond float64
+	// Burst is the size of the bucket, PerSecond rounded up when empty
+	Burst int
+	// Daily is the number of requests allowed per day, 0 disables the quota
+	Daily int
+}
+
+// RateLimitError is returned when an upstream rate limited a request or when the budget of every upstream is exhausted
+type RateLimitError struct {
+	// RetryAfter is the delay before the request can be retried, 0 if unknown
+	RetryAfter time.Duration
+	Message    string
+	// RPC is the JSON-RPC error the node explained the limit with, nil if there is none
+	RPC *RPCError
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%s, retry after %s", e.Message, e.RetryAfter)
+	}
+	return e.Message
+}
+
+// Unwrap returns the JSON-RPC error of the node
+func (e *RateLimitError) Unwrap() error {
+	if e.RPC == nil {
+		return nil
+	}
+	return e.RPC
+}
+
+// Budget is the remaining request budget of an upstream
+type Budget struct {
+	// Tokens is the number of requests that can be sent right away, -1 if unlimited
+	Tokens int `json:"tokens"`
+	// Daily is the number of requests left today, -1 if unlimited
+	Daily int `json:"daily"`
+	// PausedUntil is set while the upstream asked to slow down
+	PausedUntil *time.Time `json:"pausedUntil,omitempty"`
+}
+
+// bucket enforces the rate limit of an upstream and the pauses it asks for
+type bucket struct {
+	limit RateLimit
+	now   func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	// day is the start of the current day of the quota, used the requests sent since
+	day         time.Time
+	used        int
+	pausedUntil time.Time
+}
+
+func newBucket(limit RateLimit, now func() time.Time) *bucket {
+	if limit.PerSecond > 0 && limit.Burst <= 0 {
+		limit.Burst = int(math.Ceil(limit.PerSecond))
+	}
+	t := now()
+	return &bucket{
+		limit:  limit,
+		now:    now,
+		tokens: float64(limit.Burst),
+		last:   t,
+		day:    t.UTC().Truncate(24 * time.Hour),
+	}
+}
+
+// reserve takes n tokens if the budget allows it and returns 0, otherwise it returns the delay before it does
+func (b *bucket) reserve(n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.now()
+	b.refill(t)
+
+	if t.Before(b.pausedUntil) {
+		return b.pausedUntil.Sub(t)
+	}
+	if b.limit.Daily > 0 && b.used+n > b.limit.Daily {
+		return b.day.Add(24 * time.Hour).Sub(t)
+	}
+	if b.limit.PerSecond > 0 {
+		// a batch larger than the bucket waits for a full bucket
+		need := math.Min(float64(n), float64(b.limit.Burst))
+		if b.tokens < need {
+			return time.Duration(math.Ceil((need - b.tokens) / b.limit.PerSecond * float64(time.Second)))
+		}
+		b.tokens -= need
+	}
+	b.used += n
+	return 0
+}
+
+// spend takes n tokens even if the budget is exhausted, it is used by the requests that can't wait like health probes
+func (b *bucket) spend(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(b.now())
+	if b.limit.PerSecond > 0 {
+		b.tokens -= float64(n)
+	}
+	b.used += n
+}
+
+// pause stops sending requests to the upstream for d, defaultBackoff if d is 0
+func (b *bucket) pause(d time.Duration) {
+	if d <= 0 {
+		d = defaultBackoff
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if until := b.now().Add(d); until.After(b.pausedUntil) {
+		b.pausedUntil = until
+	}
+}
+
+func (b *bucket) budget() Budget {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.now()
+	b.refill(t)
+	budget := Budget{Tokens: -1, Daily: -1}
+	if b.limit.PerSecond > 0 {
+		budget.Tokens = int(math.Max(0, b.tokens))
+	}
+	if b.limit.Daily > 0 {
+		budget.Daily = b.limit.Daily - b.used
+		if budget.Daily < 0 {
+			budget.Daily = 0
+		}
+	}
+	if t.Before(b.pausedUntil) {
+		until := b.pausedUntil
+		budget.PausedUntil = &until
+	}
+	return budget
+}
+
+// refill adds the tokens earned since the last call and resets the quota on a new day, b.mu must be held
+func (b *bucket) refill(t time.Time) {
+	if b.limit.PerSecond > 0 && t.After(b.last) {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+t.Sub(b.last).Seconds()*b.limit.PerSecond)
+	}
+	b.last = t
+	if day := t.UTC().Truncate(24 * time.Hour); day.After(b.day) {
+		b.day = day
+		b.used = 0
+	}
+}
+
+// parseRetryAfter reads a Retry-After header in seconds or as an http date, it returns 0 if it is missing or invalid
+func parseRetryAfter(h string, now time.Time) time.Duration {
+	if h == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(h); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,147 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/INFURA/infra-test-benjamin-mateo/node/nodetest"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+func TestBucket(t *testing.T) {
+	now := time.Date(2020, 1, 1, 23, 59, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	b := newBucket(RateLimit{PerSecond: 2, Daily: 4}, clock)
+
+	if d := b.reserve(1); d != 0 {
+		t.Fatalf("first request waits %s", d)
+	}
+	if d := b.reserve(1); d != 0 {
+		t.Fatalf("burst request waits %s", d)
+	}
+	if d := b.reserve(1); d != 500*time.Millisecond {
+		t.Errorf("empty bucket: got wait %s want 500ms", d)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if d := b.reserve(1); d != 0 {
+		t.Errorf("refilled bucket waits %s", d)
+	}
+	if got := b.budget(); got.Tokens != 0 || got.Daily != 1 {
+		t.Errorf("budget: got %+v", got)
+	}
+
+	// the probes exhaust the quota of the day
+	b.spend(1)
+	now = now.Add(10 * time.Second)
+	if d := b.reserve(1); d != 49500*time.Millisecond {
+		t.Errorf("exhausted quota: got wait %s want until midnight", d)
+	}
+	now = now.Add(time.Minute)
+	if d := b.reserve(1); d != 0 {
+		t.Errorf("quota of the new day: got wait %s", d)
+	}
+
+	b.pause(3 * time.Second)
+	if d := b.reserve(1); d != 3*time.Second {
+		t.Errorf("paused: got wait %s want 3s", d)
+	}
+	if got := b.budget(); got.PausedUntil == nil || !got.PausedUntil.Equal(now.Add(3*time.Second)) {
+		t.Errorf("paused budget: got %+v", got)
+	}
+
+	if got := newBucket(RateLimit{}, clock); got.reserve(1000) != 0 || got.budget().Tokens != -1 || got.budget().Daily != -1 {
+		t.Error("zero rate limit should be unlimited")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tt := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{"Wed, 01 Jan 2020 00:00:30 GMT", 30 * time.Second},
+		{"soon", 0},
+	}
+	for _, tc := range tt {
+		if got := parseRetryAfter(tc.header, now); got != tc.want {
+			t.Errorf("%q: got %s want %s", tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestPoolRateLimit(t *testing.T) {
+	ctx := context.Background()
+	limited := &nodetest.Error{Code: -32005, Message: "project ID request rate exceeded", HTTPStatus: 429, RetryAfter: 2 * time.Second}
+
+	t.Run("upstreams asking to slow down", func(t *testing.T) {
+		a, b := nodetest.NewServer(nil), nodetest.NewServer(nil)
+		defer a.Close()
+		defer b.Close()
+		pool, err := NewPool(PoolConfig{URLs: []string{a.URL, b.URL}, HealthInterval: time.Hour, MaxWait: 100 * time.Millisecond}, zap.NewNop().Sugar())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer pool.Close()
+		client, err := GetNewPooledClient(pool)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		a.Fail("eth_gasPrice", limited)
+		if _, err := client.GetGasPrice(ctx); err != nil {
+			t.Fatalf("should fail over to the other upstream: %v", err)
+		}
+		b.Fail("eth_gasPrice", limited)
+		_, err = client.GetGasPrice(ctx)
+		limit, ok := errors.Cause(err).(*RateLimitError)
+		if !ok || limit.RetryAfter != 2*time.Second || Classify(err) != ErrRateLimited {
+			t.Fatalf("got %v want a rate limit with retry after", err)
+		}
+
+		// both upstreams are paused, the request fails without reaching them
+		served := a.Calls("eth_gasPrice") + b.Calls("eth_gasPrice")
+		_, err = client.GetGasPrice(ctx)
+		if limit, ok := errors.Cause(err).(*RateLimitError); !ok || limit.RetryAfter <= time.Second {
+			t.Errorf("got %v want the budget exhausted", err)
+		}
+		if n := a.Calls("eth_gasPrice") + b.Calls("eth_gasPrice"); n != served {
+			t.Errorf("paused upstreams served %d requests", n-served)
+		}
+		if budget := pool.Budget(); budget.PausedUntil == nil || budget.Tokens != -1 {
+			t.Errorf("got budget %+v", budget)
+		}
+	})
+
+	t.Run("token bucket", func(t *testing.T) {
+		srv := nodetest.NewServer(nil)
+		defer srv.Close()
+		pool, err := NewPool(PoolConfig{URLs: []string{srv.URL}, HealthInterval: time.Hour, RateLimit: RateLimit{PerSecond: 20, Daily: 100}, MaxWait: time.Second}, zap.NewNop().Sugar())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer pool.Close()
+		client, err := GetNewPooledClient(pool)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		start := time.Now()
+		for i := 0; i < 30; i++ {
+			if _, err := client.GetGasPrice(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// the probe and the first 19 requests use the burst, the others wait for tokens
+		if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+			t.Errorf("30 requests at 20/s took %s", elapsed)
+		}
+		if budget := pool.Budget(); budget.Daily != 100-31 {
+			t.Errorf("got budget %+v want %d requests left today", budget, 100-31)
+		}
+	})
+}
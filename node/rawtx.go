@@ -0,0 +1,226 @@
+package node
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/pkg/errors"
+)
+
+// EIP-2718 transaction types
+const (
+	LegacyTxType     = 0x00
+	AccessListTxType = 0x01
+	DynamicFeeTxType = 0x02
+)
+
+// intrinsic gas costs as of Shanghai
+const (
+	txGas                 = 21000
+	txGasContractCreation = 53000
+	txDataZeroGas         = 4
+	txDataNonZeroGas      = 16
+	txAccessListAddress   = 2400
+	txAccessListStorage   = 1900
+	initCodeWordGas       = 2
+	maxInitCodeSize       = 2 * 24576
+)
+
+// RawTransaction is a signed transaction decoded locally from its RLP encoding
+type RawTransaction struct {
+	Type                 uint8
+	ChainID              *big.Int
+	Nonce                uint64
+	GasPrice             *big.Int
+	MaxPriorityFeePerGas *big.Int
+	MaxFeePerGas         *big.Int
+	Gas                  uint64
+	To                   *common.Address
+	Value                *big.Int
+	Data                 []byte
+	AccessList           []rlpAccessTuple
+
+	// From is the sender recovered from the signature
+	From common.Address
+	// Hash is the transaction hash, the node should return the same one
+	Hash common.Hash
+}
+
+type rlpAccessTuple struct {
+	Address     common.Address
+	StorageKeys []common.Hash
+}
+
+type legacyTx struct {
+	Nonce    uint64
+	GasPrice *big.Int
+	Gas      uint64
+	To       []byte
+	Value    *big.Int
+	Data     []byte
+	V, R, S  *big.Int
+}
+
+type accessListTx struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasPrice   *big.Int
+	Gas        uint64
+	To         []byte
+	Value      *big.Int
+	Data       []byte
+	AccessList []rlpAccessTuple
+	V, R, S    *big.Int
+}
+
+type dynamicFeeTx struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasTipCap  *big.Int
+	GasFeeCap  *big.Int
+	Gas        uint64
+	To         []byte
+	Value      *big.Int
+	Data       []byte
+	AccessList []rlpAccessTuple
+	V, R, S    *big.Int
+}
+
+// DecodeRawTransaction decodes a hex encoded signed transaction and recovers its sender.
+// Legacy, EIP-2930 and EIP-1559 transactions are supported.
+func DecodeRawTransaction(raw string) (*RawTransaction, error) {
+	b, err := hexutil.Decode(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid hex")
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty transaction")
+	}
+
+	tx := &RawTransaction{Hash: crypto.Keccak256Hash(b)}
+	var sigHash []byte
+	var v, r, s *big.Int
+	var to []byte
+
+	switch {
+	case b[0] >= 0xc0:
+		// a legacy transaction is an RLP list
+		t := legacyTx{}
+		if err := rlp.DecodeBytes(b, &t); err != nil {
+			return nil, errors.Wrap(err, "invalid legacy transaction")
+		}
+		tx.Type = LegacyTxType
+		tx.Nonce, tx.GasPrice, tx.Gas, to, tx.Value, tx.Data = t.Nonce, t.GasPrice, t.Gas, t.To, t.Value, t.Data
+		v, r, s = t.V, t.R, t.S
+
+		fields := []interface{}{t.Nonce, t.GasPrice, t.Gas, t.To, t.Value, t.Data}
+		if v.Cmp(big.NewInt(35)) >= 0 {
+			// EIP-155 v = chainId * 2 + 35 + yParity
+			tx.ChainID = new(big.Int).Rsh(new(big.Int).Sub(v, big.NewInt(35)), 1)
+			fields = append(fields, tx.ChainID, uint(0), uint(0))
+			v = new(big.Int).Sub(v, new(big.Int).Add(new(big.Int).Lsh(tx.ChainID, 1), big.NewInt(35)))
+		} else {
+			v = new(big.Int).Sub(v, big.NewInt(27))
+		}
+		sigHash, err = rlpHash(nil, fields)
+	case b[0] == AccessListTxType:
+		t := accessListTx{}
+		if err := rlp.DecodeBytes(b[1:], &t); err != nil {
+			return nil, errors.Wrap(err, "invalid access list transaction")
+		}
+		tx.Type = AccessListTxType
+		tx.ChainID, tx.Nonce, tx.GasPrice, tx.Gas, to, tx.Value, tx.Data, tx.AccessList = t.ChainID, t.Nonce, t.GasPrice, t.Gas, t.To, t.Value, t.Data, t.AccessList
+		v, r, s = t.V, t.R, t.S
+		sigHash, err = rlpHash([]byte{AccessListTxType}, []interface{}{t.ChainID, t.Nonce, t.GasPrice, t.Gas, t.To, t.Value, t.Data, t.AccessList})
+	case b[0] == DynamicFeeTxType:
+		t := dynamicFeeTx{}
+		if err := rlp.DecodeBytes(b[1:], &t); err != nil {
+			return nil, errors.Wrap(err, "invalid dynamic fee transaction")
+		}
+		tx.Type = DynamicFeeTxType
+		tx.ChainID, tx.Nonce, tx.MaxPriorityFeePerGas, tx.MaxFeePerGas, tx.Gas, to, tx.Value, tx.Data, tx.AccessList = t.ChainID, t.Nonce, t.GasTipCap, t.GasFeeCap, t.Gas, t.To, t.Value, t.Data, t.AccessList
+		v, r, s = t.V, t.R, t.S
+		sigHash, err = rlpHash([]byte{DynamicFeeTxType}, []interface{}{t.ChainID, t.Nonce, t.GasTipCap, t.GasFeeCap, t.Gas, t.To, t.Value, t.Data, t.AccessList})
+	default:
+		return nil, errors.Errorf("unsupported transaction type 0x%x", b[0])
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "could not compute signing hash")
+	}
+
+	switch len(to) {
+	case 0:
+	case common.AddressLength:
+		a := common.BytesToAddress(to)
+		tx.To = &a
+	default:
+		return nil, errors.Errorf("invalid recipient length %d", len(to))
+	}
+
+	if !v.IsUint64() || v.Uint64() > 1 || !crypto.ValidateSignatureValues(byte(v.Uint64()), r, s, true) {
+		return nil, errors.New("invalid signature values")
+	}
+	sig := make([]byte, crypto.SignatureLength)
+	copy(sig[32-len(r.Bytes()):32], r.Bytes())
+	copy(sig[64-len(s.Bytes()):64], s.Bytes())
+	sig[64] = byte(v.Uint64())
+	pub, err := crypto.SigToPub(sigHash, sig)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not recover sender")
+	}
+	tx.From = crypto.PubkeyToAddress(*pub)
+
+	return tx, nil
+}
+
+// Validate checks the transaction targets the given chain, is replay protected and pays its intrinsic gas
+func (tx *RawTransaction) Validate(chainID *big.Int) error {
+	if tx.ChainID == nil {
+		return errors.New("transaction is not replay protected (EIP-155)")
+	}
+	if tx.ChainID.Cmp(chainID) != 0 {
+		return errors.Errorf("invalid chain id %s, expected %s", tx.ChainID, chainID)
+	}
+	if tx.Type == DynamicFeeTxType && tx.MaxPriorityFeePerGas.Cmp(tx.MaxFeePerGas) > 0 {
+		return errors.Errorf("max priority fee per gas %s higher than max fee per gas %s", tx.MaxPriorityFeePerGas, tx.MaxFeePerGas)
+	}
+	if tx.To == nil && len(tx.Data) > maxInitCodeSize {
+		return errors.Errorf("init code size %d exceeds the limit %d", len(tx.Data), maxInitCodeSize)
+	}
+	if gas := tx.IntrinsicGas(); tx.Gas < gas {
+		return errors.Errorf("intrinsic gas too low: have %d, want %d", tx.Gas, gas)
+	}
+	return nil
+}
+
+// IntrinsicGas is the gas consumed by the transaction before any execution
+func (tx *RawTransaction) IntrinsicGas() uint64 {
+	gas := uint64(txGas)
+	if tx.To == nil {
+		gas = txGasContractCreation
+		gas += initCodeWordGas * ((uint64(len(tx.Data)) + 31) / 32)
+	}
+	for _, c := range tx.Data {
+		if c == 0 {
+			gas += txDataZeroGas
+		} else {
+			gas += txDataNonZeroGas
+		}
+	}
+	for _, t := range tx.AccessList {
+		gas += txAccessListAddress + txAccessListStorage*uint64(len(t.StorageKeys))
+	}
+	return gas
+}
+
+// rlpHash is the keccak256 of the prefix followed by the RLP encoding of the fields
+func rlpHash(prefix []byte, fields []interface{}) ([]byte, error) {
+	enc, err := rlp.EncodeToBytes(fields)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(prefix, enc), nil
+}
@@ -0,0 +1,104 @@
+package node
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	testTo      = common.HexToAddress("0xe530441f4f73bDB6DC2fA5aF7c3fC5fD551Ec838")
+	testChainID = big.NewInt(1)
+)
+
+// signLegacy signs a legacy transaction with go-ethereum
+func signLegacy(t *testing.T, gas uint64, signer types.Signer) string {
+	tx := types.NewTransaction(3, testTo, big.NewInt(10), gas, big.NewInt(1e9), []byte{0, 1})
+	signed, err := types.SignTx(tx, signer, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := rlp.EncodeToBytes(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hexutil.Encode(b)
+}
+
+// signDynamicFee builds and signs an EIP-1559 transaction
+func signDynamicFee(t *testing.T, chainID *big.Int, tip, feeCap int64) string {
+	fields := []interface{}{chainID, uint64(7), big.NewInt(tip), big.NewInt(feeCap), uint64(50000), testTo.Bytes(), big.NewInt(0), []byte{}, []rlpAccessTuple{{Address: testTo, StorageKeys: []common.Hash{{}}}}}
+	h, err := rlpHash([]byte{DynamicFeeTxType}, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := crypto.Sign(h, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields = append(fields, uint64(sig[64]), new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:64]))
+	b, err := rlp.EncodeToBytes(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hexutil.Encode(append([]byte{DynamicFeeTxType}, b...))
+}
+
+func TestDecodeRawTransaction(t *testing.T) {
+	tt := []struct {
+		name      string
+		raw       string
+		decodeErr string
+		validErr  string
+		gas       uint64
+	}{
+		{"legacy EIP-155", signLegacy(t, 21100, types.NewEIP155Signer(testChainID)), "", "", 21000 + 4 + 16},
+		{"legacy unprotected", signLegacy(t, 21100, types.HomesteadSigner{}), "", "not replay protected", 21000 + 4 + 16},
+		{"legacy wrong chain", signLegacy(t, 21100, types.NewEIP155Signer(big.NewInt(5))), "", "invalid chain id", 21000 + 4 + 16},
+		{"legacy intrinsic gas too low", signLegacy(t, 21000, types.NewEIP155Signer(testChainID)), "", "intrinsic gas too low", 21000 + 4 + 16},
+		{"dynamic fee", signDynamicFee(t, testChainID, 1, 2), "", "", 21000 + 2400 + 1900},
+		{"dynamic fee tip above cap", signDynamicFee(t, testChainID, 3, 2), "", "max priority fee", 21000 + 2400 + 1900},
+		{"blob", "0x03c0", "unsupported transaction type", "", 0},
+		{"not hex", "0xzz", "invalid hex", "", 0},
+		{"truncated", signLegacy(t, 21100, types.NewEIP155Signer(testChainID))[:40], "invalid legacy transaction", "", 0},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tx, err := DecodeRawTransaction(tc.raw)
+			if tc.decodeErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.decodeErr) {
+					t.Fatalf("got err %v want %s", err, tc.decodeErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tx.From != testAddr {
+				t.Errorf("got sender %s want %s", tx.From.Hex(), testAddr.Hex())
+			}
+			if tx.Hash != crypto.Keccak256Hash(hexutil.MustDecode(tc.raw)) {
+				t.Errorf("unexpected hash %s", tx.Hash.Hex())
+			}
+			if g := tx.IntrinsicGas(); g != tc.gas {
+				t.Errorf("got intrinsic gas %d want %d", g, tc.gas)
+			}
+			err = tx.Validate(testChainID)
+			if tc.validErr == "" && err != nil {
+				t.Errorf("should be valid got %s", err)
+			}
+			if tc.validErr != "" && (err == nil || !strings.Contains(err.Error(), tc.validErr)) {
+				t.Errorf("got err %v want %s", err, tc.validErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var (
+	// selector of Error(string)
+	errorSelector = []byte{0x08, 0xc3, 0x79, 0xa0}
+	// selector of Panic(uint256)
+	panicSelector = []byte{0x4e, 0x48, 0x7b, 0x71}
+)
+
+// RevertError is returned when the execution of a call reverted
+type RevertError struct {
+	// Code is the JSON-RPC error code
+	Code    int
+	Message string
+	// Data is the raw revert data returned by the contract
+	Data eth.Data
+	// Reason is the decoded revert reason, empty if the data can't be decoded
+	Reason string
+}
+
+func (e *RevertError) Error() string {
+	if e.Reason != "" {
+		return "execution reverted: " + e.Reason
+	}
+	return e.Message
+}
+
+// parseExecutionError turns a JSON-RPC error of a call into a RevertError when the execution reverted
+func parseExecutionError(raw json.RawMessage) error {
+	err := parseRPCError(raw)
+	rpcErr := err.(*RPCError)
+	if rpcErr.Kind != ErrExecutionReverted {
+		return err
+	}
+
+	e := &RevertError{Code: rpcErr.Code, Message: rpcErr.Message}
+	var data string
+	if json.Unmarshal(rpcErr.Data, &data) == nil && strings.HasPrefix(data, "0x") {
+		e.Data = eth.Data(data)
+		e.Reason = DecodeRevertReason(data)
+	}
+	if e.Reason == "" {
+		e.Reason = strings.TrimPrefix(strings.TrimPrefix(rpcErr.Message, "execution reverted"), ": ")
+	}
+	return e
+}
+
+// DecodeRevertReason decodes the revert data of Error(string) and Panic(uint256), it returns an empty string otherwise
+func DecodeRevertReason(data string) string {
+	b, err := hexutil.Decode(data)
+	if err != nil || len(b) < 4 {
+		return ""
+	}
+	selector, args := b[:4], b[4:]
+
+	switch {
+	case string(selector) == string(errorSelector):
+		// abi encoded string: offset, length then the bytes
+		if len(args) < 64 {
+			return ""
+		}
+		n := uint64(len(args))
+		offset := new(big.Int).SetBytes(args[:32])
+		if !offset.IsUint64() || offset.Uint64() > n-32 {
+			return ""
+		}
+		start := offset.Uint64() + 32
+		length := new(big.Int).SetBytes(args[offset.Uint64():start])
+		if !length.IsUint64() || length.Uint64() > n-start {
+			return ""
+		}
+		return string(args[start : start+length.Uint64()])
+	case string(selector) == string(panicSelector):
+		if len(args) < 32 {
+			return ""
+		}
+		return fmt.Sprintf("panic code 0x%x", new(big.Int).SetBytes(args[:32]))
+	}
+	return ""
+}
@@ -0,0 +1,35 @@
+package node
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseExecutionError(t *testing.T) {
+	tt := []struct {
+		name     string
+		rpcError string
+		reason   string
+		reverted bool
+	}{
+		{"error string", `{"code":3,"message":"execution reverted: Ownable: caller is not the owner","data":"0x08c379a0000000000000000000000000000000000000000000000000000000000000002000000000000000000000000000000000000000000000000000000000000000204f776e61626c653a2063616c6c6572206973206e6f7420746865206f776e6572"}`, "Ownable: caller is not the owner", true},
+		{"panic", `{"code":3,"message":"execution reverted","data":"0x4e487b710000000000000000000000000000000000000000000000000000000000000011"}`, "panic code 0x11", true},
+		{"custom error", `{"code":3,"message":"execution reverted","data":"0xdeadbeef"}`, "", true},
+		{"message only", `{"code":-32000,"message":"execution reverted: not enough funds"}`, "not enough funds", true},
+		{"truncated data", `{"code":3,"message":"execution reverted","data":"0x08c379a00000"}`, "", true},
+		{"not a revert", `{"code":-32000,"message":"gas required exceeds allowance (30000000)"}`, "", false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := parseExecutionError(json.RawMessage(tc.rpcError))
+			revert, ok := err.(*RevertError)
+			if ok != tc.reverted {
+				t.Fatalf("reverted: got %v want %v err:%s", ok, tc.reverted, err)
+			}
+			if ok && revert.Reason != tc.reason {
+				t.Errorf("got reason %q want %q", revert.Reason, tc.reason)
+			}
+		})
+	}
+}
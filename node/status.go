@@ -0,0 +1,151 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"github.com/pkg/errors"
+)
+
+// SyncStatus is the result of eth_syncing
+type SyncStatus struct {
+	Syncing       bool   `json:"syncing"`
+	StartingBlock uint64 `json:"startingBlock,omitempty"`
+	CurrentBlock  uint64 `json:"currentBlock,omitempty"`
+	HighestBlock  uint64 `json:"highestBlock,omitempty"`
+}
+
+// UnmarshalJSON decodes false when the node is not syncing or the progress object
+func (s *SyncStatus) UnmarshalJSON(data []byte) error {
+	var syncing bool
+	if json.Unmarshal(data, &syncing) == nil {
+		*s = SyncStatus{Syncing: syncing}
+		return nil
+	}
+	p := struct {
+		StartingBlock eth.Quantity `json:"startingBlock"`
+		CurrentBlock  eth.Quantity `json:"currentBlock"`
+		HighestBlock  eth.Quantity `json:"highestBlock"`
+	}{}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return errors.Wrap(err, "could not decode sync status")
+	}
+	*s = SyncStatus{true, p.StartingBlock.UInt64(), p.CurrentBlock.UInt64(), p.HighestBlock.UInt64()}
+	return nil
+}
+
+// NodeStatus describes the node and its head.
+// Every field but the head is optional, Errors holds the reason of the missing ones by method.
+type NodeStatus struct {
+	ChainID       *big.Int
+	NetworkID     string
+	ClientVersion string
+	PeerCount     *uint64
+	Sync          *SyncStatus
+	Head          *eth.Block
+	// HeadLag is the time elapsed since the head was mined
+	HeadLag time.Duration
+	Errors  map[string]string
+}
+
+// NetVersion get the network ID
+func (c *CustomClient) NetVersion(ctx context.Context) (string, error) {
+	var v string
+	err := c.call(ctx, "net_version", &v)
+	return v, err
+}
+
+// Syncing get the sync progress of the node
+func (c *CustomClient) Syncing(ctx context.Context) (*SyncStatus, error) {
+	s := SyncStatus{}
+	if err := c.call(ctx, "eth_syncing", &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// PeerCount get the number of peers connected to the node
+func (c *CustomClient) PeerCount(ctx context.Context) (uint64, error) {
+	q := eth.Quantity{}
+	err := c.call(ctx, "net_peerCount", &q)
+	return q.UInt64(), err
+}
+
+// ClientVersion get the name and version of the node software
+func (c *CustomClient) ClientVersion(ctx context.Context) (string, error) {
+	var v string
+	err := c.call(ctx, "web3_clientVersion", &v)
+	return v, err
+}
+
+// Status requests the chain ID, network, client version, peer count, sync progress and head in a single round trip.
+// Providers often disable some of these methods, only the head is required.
+func (c *CustomClient) Status(ctx context.Context) (*NodeStatus, error) {
+	var chainID, peers eth.Quantity
+	var status NodeStatus
+	var sync SyncStatus
+	batch := []BatchElem{
+		{Method: "eth_getBlockByNumber", Params: []interface{}{eth.TagLatest, false}, Result: &status.Head},
+		{Method: "eth_chainId", Result: &chainID},
+		{Method: "net_version", Result: &status.NetworkID},
+		{Method: "web3_clientVersion", Result: &status.ClientVersion},
+		{Method: "net_peerCount", Result: &peers},
+		{Method: "eth_syncing", Result: &sync},
+	}
+	if err := c.BatchCall(ctx, batch); err != nil {
+		return nil, err
+	}
+	if batch[0].Error != nil {
+		return nil, batch[0].Error
+	}
+	if status.Head == nil {
+		return nil, errors.New("no head block")
+	}
+	status.HeadLag = time.Since(time.Unix(int64(status.Head.Timestamp.UInt64()), 0))
+
+	status.Errors = make(map[string]string)
+	for _, e := range batch[1:] {
+		if e.Error != nil {
+			status.Errors[e.Method] = e.Error.Error()
+		}
+	}
+	if batch[1].Error == nil {
+		status.ChainID = chainID.Big()
+	}
+	if batch[4].Error == nil {
+		n := peers.UInt64()
+		status.PeerCount = &n
+	}
+	if batch[5].Error == nil {
+		status.Sync = &sync
+	}
+	return &status, nil
+}
+
+// call sends a request without batching and decodes its result
+func (c *CustomClient) call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	p, err := jsonrpc.MakeParams(params...)
+	if err != nil {
+		return errors.Wrapf(err, "invalid params for %s", method)
+	}
+	request := jsonrpc.Request{
+		ID:     jsonrpc.ID{Num: 1},
+		Method: method,
+		Params: p,
+	}
+
+	response, err := c.Request(ctx, &request)
+	if err != nil {
+		return errors.Wrap(err, "could not make  request")
+	}
+
+	if response.Error != nil {
+		return parseRPCError(*response.Error)
+	}
+
+	return errors.Wrapf(json.Unmarshal(response.Result, result), "could not decode %s result", method)
+}
@@ -0,0 +1,56 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"github.com/INFURA/go-ethlibs/node"
+)
+
+func TestStatus(t *testing.T) {
+	minedAt := time.Now().Add(-30 * time.Second).Unix()
+	disabled := json.RawMessage(`{"code":-32601,"message":"the method net_peerCount does not exist/is not available"}`)
+	results := map[string]string{
+		"eth_getBlockByNumber": fmt.Sprintf(`{"number":"0x10","hash":"0xafddcde383196ecacf07def76a2572c86d1f992081cb1023c8decef92b7712ee","parentHash":"0xafddcde383196ecacf07def76a2572c86d1f992081cb1023c8decef92b7712ef","timestamp":"0x%x","transactions":[],"uncles":[]}`, minedAt),
+		"eth_chainId":          `"0x1"`,
+		"net_version":          `"1"`,
+		"web3_clientVersion":   `"Geth/v1.13.0"`,
+		"eth_syncing":          `{"startingBlock":"0x0","currentBlock":"0x10","highestBlock":"0x20"}`,
+	}
+	client, err := node.NewCustomClient(requesterFunc(func(r *jsonrpc.Request) (json.RawMessage, *json.RawMessage) {
+		if r.Method == "net_peerCount" {
+			return nil, &disabled
+		}
+		return json.RawMessage(results[r.Method]), nil
+	}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := CustomClient{Client: client}
+
+	st, err := c.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.ChainID.Int64() != 1 || st.NetworkID != "1" || st.ClientVersion != "Geth/v1.13.0" {
+		t.Errorf("unexpected status %+v", st)
+	}
+	if st.PeerCount != nil || st.Errors["net_peerCount"] == "" {
+		t.Errorf("disabled method should be reported in errors, got %v %v", st.PeerCount, st.Errors)
+	}
+	if st.Sync == nil || !st.Sync.Syncing || st.Sync.HighestBlock != 32 {
+		t.Errorf("unexpected sync status %+v", st.Sync)
+	}
+	if st.HeadLag < 29*time.Second || st.HeadLag > time.Minute {
+		t.Errorf("unexpected head lag %s", st.HeadLag)
+	}
+
+	s := SyncStatus{Syncing: true}
+	if err := json.Unmarshal([]byte(`false`), &s); err != nil || s.Syncing {
+		t.Errorf("got %+v err %v want not syncing", s, err)
+	}
+}
@@ -0,0 +1,154 @@
+package node
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/pkg/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// Prefixes of the keys of the store
+const (
+	// storeBlock maps a block hash to the full block
+	storeBlock = "b:"
+	// storeHeight maps a big endian height to the hash of the canonical block
+	storeHeight = "n:"
+	// storeTx maps a transaction hash to the transaction
+	storeTx = "t:"
+	// storeReceipt maps a transaction hash to its receipt
+	storeReceipt = "r:"
+	// storeLast is the height of the highest block written, the indexer resumes after it
+	storeLast = "last"
+)
+
+// Store keeps final blocks, their transactions and their receipts on disk in a LevelDB database.
+// Only one process can open a store, the server or the indexer command.
+type Store struct {
+	db *leveldb.DB
+	// mu serializes the updates of the last height
+	mu sync.Mutex
+}
+
+// OpenStore opens or creates the store in the directory
+func OpenStore(path string) (*Store, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't open store %s", path)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// PutBlock writes a canonical full block with the receipts of its transactions.
+// The block becomes the last one if it is higher, so a backfill doesn't move the indexer back.
+func (s *Store) PutBlock(b *eth.Block, receipts []*Receipt) error {
+	if b == nil || b.Hash == nil || b.Number == nil {
+		return errors.New("can't store a pending block")
+	}
+	batch := new(leveldb.Batch)
+	if err := putJSON(batch, storeBlock+strings.ToLower(string(*b.Hash)), b); err != nil {
+		return err
+	}
+	batch.Put(heightKeyBytes(b.Number.UInt64()), []byte(strings.ToLower(string(*b.Hash))))
+	for i := range b.Transactions {
+		tx := &b.Transactions[i].Transaction
+		if err := putJSON(batch, storeTx+strings.ToLower(string(tx.Hash)), tx); err != nil {
+			return err
+		}
+	}
+	for _, r := range receipts {
+		if err := putJSON(batch, storeReceipt+strings.ToLower(string(r.TransactionHash)), r); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if last, ok := s.Last(); !ok || b.Number.UInt64() > last {
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, b.Number.UInt64())
+		batch.Put([]byte(storeLast), v)
+	}
+	return errors.Wrap(s.db.Write(batch, nil), "can't write block")
+}
+
+// Last returns the height of the highest block written, ok is false if the store is empty
+func (s *Store) Last() (uint64, bool) {
+	v, err := s.db.Get([]byte(storeLast), nil)
+	if err != nil || len(v) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(v), true
+}
+
+// BlockByHash returns a stored block
+func (s *Store) BlockByHash(hash string, full bool) (*eth.Block, bool) {
+	b := &eth.Block{}
+	if !s.getJSON(storeBlock+strings.ToLower(hash), b) {
+		return nil, false
+	}
+	if !full {
+		b = lightBlock(b)
+	}
+	return b, true
+}
+
+// BlockByNumber returns a stored canonical block
+func (s *Store) BlockByNumber(number uint64, full bool) (*eth.Block, bool) {
+	hash, err := s.db.Get(heightKeyBytes(number), nil)
+	if err != nil {
+		return nil, false
+	}
+	return s.BlockByHash(string(hash), full)
+}
+
+// TransactionByHash returns a stored transaction
+func (s *Store) TransactionByHash(hash string) (*eth.Transaction, bool) {
+	tx := &eth.Transaction{}
+	if !s.getJSON(storeTx+strings.ToLower(hash), tx) {
+		return nil, false
+	}
+	return tx, true
+}
+
+// TransactionReceipt returns a stored receipt
+func (s *Store) TransactionReceipt(hash string) (*Receipt, bool) {
+	r := &Receipt{}
+	if !s.getJSON(storeReceipt+strings.ToLower(hash), r) {
+		return nil, false
+	}
+	return r, true
+}
+
+// getJSON decodes the value of the key, a value that can't be read is a miss and is requested to the node
+func (s *Store) getJSON(key string, v interface{}) bool {
+	data, err := s.db.Get([]byte(key), nil)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
+
+func putJSON(batch *leveldb.Batch, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrapf(err, "can't encode %s", key)
+	}
+	batch.Put([]byte(key), data)
+	return nil
+}
+
+// heightKeyBytes is big endian so that the heights are sorted
+func heightKeyBytes(number uint64) []byte {
+	key := make([]byte, len(storeHeight)+8)
+	copy(key, storeHeight)
+	binary.BigEndian.PutUint64(key[len(storeHeight):], number)
+	return key
+}
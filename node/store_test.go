@@ -0,0 +1,61 @@
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/INFURA/infra-test-benjamin-mateo/node/nodetest"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain := nodetest.NewChain()
+	b := chain.Block(nodetest.FirstBlock)
+	tx := &b.Transactions[0].Transaction
+	receipt := &Receipt{TransactionReceipt: *chain.Receipt(string(tx.Hash))}
+	if _, ok := store.Last(); ok {
+		t.Error("empty store has a last block")
+	}
+	if err := store.PutBlock(b, []*Receipt{receipt}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the blocks are kept on disk
+	if store, err = OpenStore(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if last, ok := store.Last(); !ok || last != nodetest.FirstBlock {
+		t.Errorf("got last block %d want %d", last, nodetest.FirstBlock)
+	}
+	full, ok := store.BlockByNumber(nodetest.FirstBlock, true)
+	if !ok || *full.Hash != *b.Hash || len(full.Transactions) != nodetest.TxsPerBlock || !full.Transactions[0].Populated {
+		t.Fatalf("full block not stored: %+v", full)
+	}
+	light, ok := store.BlockByHash(string(*b.Hash), false)
+	if !ok || light.Transactions[0].Populated || light.Transactions[0].Hash != tx.Hash {
+		t.Errorf("light block not served: %+v", light)
+	}
+	if got, ok := store.TransactionByHash(string(tx.Hash)); !ok || got.Hash != tx.Hash || *got.BlockHash != *b.Hash {
+		t.Errorf("transaction not stored: %+v", got)
+	}
+	if got, ok := store.TransactionReceipt(string(tx.Hash)); !ok || got.TransactionHash != tx.Hash {
+		t.Errorf("receipt not stored: %+v", got)
+	}
+	if _, ok := store.BlockByNumber(nodetest.FirstBlock+1, false); ok {
+		t.Error("block not indexed served")
+	}
+}
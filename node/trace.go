@@ -0,0 +1,198 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"github.com/INFURA/go-ethlibs/node"
+	"github.com/pkg/errors"
+)
+
+// Tracer is a tracer of debug_traceTransaction
+type Tracer string
+
+// Tracers supported by the client
+const (
+	// CallTracer returns the tree of the calls
+	CallTracer Tracer = "callTracer"
+	// PrestateTracer returns the accounts touched by the transaction
+	PrestateTracer Tracer = "prestateTracer"
+	// StructLogger is the default opcode logger of the node
+	StructLogger Tracer = ""
+)
+
+// TraceConfig is the configuration of debug_traceTransaction
+type TraceConfig struct {
+	Tracer       Tracer      `json:"tracer,omitempty"`
+	TracerConfig interface{} `json:"tracerConfig,omitempty"`
+	Timeout      string      `json:"timeout,omitempty"`
+
+	// options of the struct logger
+	EnableMemory     bool `json:"enableMemory,omitempty"`
+	DisableStack     bool `json:"disableStack,omitempty"`
+	DisableStorage   bool `json:"disableStorage,omitempty"`
+	EnableReturnData bool `json:"enableReturnData,omitempty"`
+}
+
+// CallFrame is a call of the call tree normalized from the callTracer output
+type CallFrame struct {
+	Type    string        `json:"type"`
+	From    eth.Address   `json:"from"`
+	To      *eth.Address  `json:"to,omitempty"`
+	Value   *eth.Quantity `json:"value,omitempty"`
+	Gas     uint64        `json:"gas"`
+	GasUsed uint64        `json:"gasUsed"`
+	Input   eth.Data      `json:"input"`
+	Output  eth.Data      `json:"output,omitempty"`
+	Error   string        `json:"error,omitempty"`
+	// Reverted is set when the frame failed, its state changes are discarded
+	Reverted     bool         `json:"reverted"`
+	RevertReason string       `json:"revertReason,omitempty"`
+	Depth        int          `json:"depth"`
+	Calls        []*CallFrame `json:"calls,omitempty"`
+}
+
+// rawCallFrame is the output of callTracer
+type rawCallFrame struct {
+	Type         string          `json:"type"`
+	From         eth.Address     `json:"from"`
+	To           *eth.Address    `json:"to"`
+	Value        *eth.Quantity   `json:"value"`
+	Gas          eth.Quantity    `json:"gas"`
+	GasUsed      eth.Quantity    `json:"gasUsed"`
+	Input        eth.Data        `json:"input"`
+	Output       eth.Data        `json:"output"`
+	Error        string          `json:"error"`
+	RevertReason string          `json:"revertReason"`
+	Calls        []*rawCallFrame `json:"calls"`
+}
+
+// PrestateAccount is the state of an account touched by a transaction
+type PrestateAccount struct {
+	Balance *eth.Quantity         `json:"balance,omitempty"`
+	Nonce   uint64                `json:"nonce,omitempty"`
+	Code    eth.Data              `json:"code,omitempty"`
+	Storage map[eth.Hash]eth.Hash `json:"storage,omitempty"`
+}
+
+// PrestateTrace is the output of prestateTracer, Post is only set in diff mode
+type PrestateTrace struct {
+	Pre  map[eth.Address]*PrestateAccount `json:"pre"`
+	Post map[eth.Address]*PrestateAccount `json:"post,omitempty"`
+}
+
+// StructLog is a single opcode executed
+type StructLog struct {
+	Pc      uint64            `json:"pc"`
+	Op      string            `json:"op"`
+	Gas     uint64            `json:"gas"`
+	GasCost uint64            `json:"gasCost"`
+	Depth   int               `json:"depth"`
+	Error   string            `json:"error,omitempty"`
+	Stack   []string          `json:"stack,omitempty"`
+	Memory  []string          `json:"memory,omitempty"`
+	Storage map[string]string `json:"storage,omitempty"`
+}
+
+// StructLogTrace is the output of the struct logger
+type StructLogTrace struct {
+	Gas         uint64      `json:"gas"`
+	Failed      bool        `json:"failed"`
+	ReturnValue string      `json:"returnValue"`
+	StructLogs  []StructLog `json:"structLogs"`
+}
+
+// TraceTransaction replays the transaction with debug_traceTransaction and decodes the output of the tracer into result.
+// It returns node.ErrTransactionNotFound if the node doesn't know the transaction.
+func (c *CustomClient) TraceTransaction(ctx context.Context, hash string, cfg TraceConfig, result interface{}) error {
+	request := jsonrpc.Request{
+		ID:     jsonrpc.ID{Num: 1},
+		Method: "debug_traceTransaction",
+		Params: jsonrpc.MustParams(hash, cfg),
+	}
+
+	response, err := c.Request(ctx, &request)
+	if err != nil {
+		return errors.Wrap(err, "could not make  request")
+	}
+
+	if response.Error != nil {
+		err := parseRPCError(*response.Error)
+		if Classify(err) == ErrNotFound {
+			return node.ErrTransactionNotFound
+		}
+		return err
+	}
+	if len(response.Result) == 0 || bytes.Equal(response.Result, []byte("null")) {
+		return node.ErrTransactionNotFound
+	}
+
+	return errors.Wrapf(json.Unmarshal(response.Result, result), "could not decode %s output", cfg.Tracer)
+}
+
+// TraceCalls returns the call tree of the transaction
+func (c *CustomClient) TraceCalls(ctx context.Context, hash string) (*CallFrame, error) {
+	raw := rawCallFrame{}
+	if err := c.TraceTransaction(ctx, hash, TraceConfig{Tracer: CallTracer}, &raw); err != nil {
+		return nil, err
+	}
+	return raw.normalize(0), nil
+}
+
+// TracePrestate returns the accounts touched by the transaction before its execution,
+// in diff mode only the modified accounts are returned with their state after the execution.
+func (c *CustomClient) TracePrestate(ctx context.Context, hash string, diff bool) (*PrestateTrace, error) {
+	cfg := TraceConfig{Tracer: PrestateTracer}
+	if !diff {
+		trace := PrestateTrace{}
+		if err := c.TraceTransaction(ctx, hash, cfg, &trace.Pre); err != nil {
+			return nil, err
+		}
+		return &trace, nil
+	}
+	cfg.TracerConfig = map[string]bool{"diffMode": true}
+	trace := PrestateTrace{}
+	if err := c.TraceTransaction(ctx, hash, cfg, &trace); err != nil {
+		return nil, err
+	}
+	return &trace, nil
+}
+
+// TraceStructLogs returns the opcodes executed by the transaction
+func (c *CustomClient) TraceStructLogs(ctx context.Context, hash string, cfg TraceConfig) (*StructLogTrace, error) {
+	cfg.Tracer = StructLogger
+	trace := StructLogTrace{}
+	if err := c.TraceTransaction(ctx, hash, cfg, &trace); err != nil {
+		return nil, err
+	}
+	return &trace, nil
+}
+
+// normalize converts the quantities and decodes the revert reason of the frame and its children
+func (f *rawCallFrame) normalize(depth int) *CallFrame {
+	frame := &CallFrame{
+		Type:         strings.ToUpper(f.Type),
+		From:         f.From,
+		To:           f.To,
+		Value:        f.Value,
+		Gas:          f.Gas.UInt64(),
+		GasUsed:      f.GasUsed.UInt64(),
+		Input:        f.Input,
+		Output:       f.Output,
+		Error:        f.Error,
+		Reverted:     f.Error != "",
+		RevertReason: f.RevertReason,
+		Depth:        depth,
+	}
+	if frame.Reverted && frame.RevertReason == "" && f.Output != "" {
+		frame.RevertReason = DecodeRevertReason(string(f.Output))
+	}
+	for _, c := range f.Calls {
+		frame.Calls = append(frame.Calls, c.normalize(depth+1))
+	}
+	return frame
+}
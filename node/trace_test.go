@@ -0,0 +1,59 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"github.com/INFURA/go-ethlibs/node"
+)
+
+// requesterFunc answers every request with the result or the error given
+type requesterFunc func(r *jsonrpc.Request) (json.RawMessage, *json.RawMessage)
+
+func (f requesterFunc) Request(ctx context.Context, r *jsonrpc.Request) (*jsonrpc.RawResponse, error) {
+	res, rpcErr := f(r)
+	return &jsonrpc.RawResponse{ID: r.ID, Result: res, Error: rpcErr}, nil
+}
+
+func TestTraceCalls(t *testing.T) {
+	// a call to a contract that reverts with Error("nope") in a sub call
+	out := `{"type":"CALL","from":"0x5cf2cbfd110e7ce39fb353d123776ab683ef9feb","to":"0xe530441f4f73bdb6dc2fa5af7c3fc5fd551ec838","value":"0x0","gas":"0x7530","gasUsed":"0x5208","input":"0x","error":"execution reverted",
+		"calls":[{"type":"DELEGATECALL","from":"0xe530441f4f73bdb6dc2fa5af7c3fc5fd551ec838","to":"0x6b175474e89094c44da98b954eedeac495271d0f","gas":"0x2710","gasUsed":"0x3e8","input":"0x01",
+		"output":"0x08c379a0000000000000000000000000000000000000000000000000000000000000002000000000000000000000000000000000000000000000000000000000000000046e6f706500000000000000000000000000000000000000000000000000000000","error":"execution reverted"}]}`
+	var method string
+	var params jsonrpc.Params
+	client, err := node.NewCustomClient(requesterFunc(func(r *jsonrpc.Request) (json.RawMessage, *json.RawMessage) {
+		method, params = r.Method, r.Params
+		return json.RawMessage(out), nil
+	}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := CustomClient{Client: client}
+
+	root, err := c.TraceCalls(context.Background(), "0x37e458fcff2a79f32257776aa67f929187d2ff1f8868092bead0b788d248b9b4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method != "debug_traceTransaction" || string(params[1]) != `{"tracer":"callTracer"}` {
+		t.Errorf("unexpected request %s %s", method, params[1])
+	}
+	if root.Gas != 30000 || root.GasUsed != 21000 || !root.Reverted || root.Depth != 0 || len(root.Calls) != 1 {
+		t.Errorf("unexpected root frame %+v", root)
+	}
+	sub := root.Calls[0]
+	if sub.Type != "DELEGATECALL" || sub.Depth != 1 || sub.GasUsed != 1000 || sub.RevertReason != "nope" {
+		t.Errorf("unexpected sub frame %+v", sub)
+	}
+
+	notFound := json.RawMessage(`{"code":-32000,"message":"transaction 0x37e4 not found"}`)
+	client, _ = node.NewCustomClient(requesterFunc(func(r *jsonrpc.Request) (json.RawMessage, *json.RawMessage) {
+		return nil, &notFound
+	}), nil)
+	c = CustomClient{Client: client}
+	if _, err := c.TraceCalls(context.Background(), "0x37e4"); err != node.ErrTransactionNotFound {
+		t.Errorf("got err %v want %v", err, node.ErrTransactionNotFound)
+	}
+}
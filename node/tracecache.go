@@ -0,0 +1,88 @@
+package node
+
+import (
+	"container/list"
+	"sync"
+)
+
+// TraceCache is a least recently used cache of struct log traces.
+// Tracing a transaction replays it on the node, the pages of a trace are served from the cache instead of tracing it again.
+type TraceCache struct {
+	// size bounds the number of struct logs kept
+	size int
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	weight  int
+}
+
+// traceEntry is a trace of the cache, it weighs its struct logs
+type traceEntry struct {
+	key   string
+	trace *StructLogTrace
+}
+
+// NewTraceCache creates an empty cache keeping up to size struct logs
+func NewTraceCache(size int) *TraceCache {
+	if size <= 0 {
+		size = 100000
+	}
+	return &TraceCache{
+		size:    size,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns a cached trace, it must not be modified
+func (c *TraceCache) Get(key string) (*StructLogTrace, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*traceEntry).trace, true
+}
+
+// Add caches a trace and evicts the least recently used ones, a trace larger than the cache is not kept
+func (c *TraceCache) Add(key string, trace *StructLogTrace) {
+	w := traceWeight(trace)
+	if w > c.size {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	c.entries[key] = c.lru.PushFront(&traceEntry{key: key, trace: trace})
+	c.weight += w
+	for c.weight > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+// Len returns the number of traces cached
+func (c *TraceCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// remove drops an entry, c.mu must be held
+func (c *TraceCache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*traceEntry)
+	delete(c.entries, e.key)
+	c.weight -= traceWeight(e.trace)
+}
+
+// traceWeight is the number of struct logs of a trace, an empty trace weighs one
+func traceWeight(trace *StructLogTrace) int {
+	if len(trace.StructLogs) == 0 {
+		return 1
+	}
+	return len(trace.StructLogs)
+}
@@ -0,0 +1,33 @@
+package node
+
+import (
+	"testing"
+)
+
+func TestTraceCache(t *testing.T) {
+	trace := func(n int) *StructLogTrace {
+		return &StructLogTrace{StructLogs: make([]StructLog, n)}
+	}
+	c := NewTraceCache(10)
+	c.Add("a", trace(4))
+	c.Add("b", trace(4))
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a should be cached")
+	}
+	// b is the least recently used
+	c.Add("c", trace(4))
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("a should be kept")
+	}
+	c.Add("d", trace(11))
+	if _, ok := c.Get("d"); ok {
+		t.Error("a trace larger than the cache should not be kept")
+	}
+	c.Add("a", trace(2))
+	if got, _ := c.Get("a"); len(got.StructLogs) != 2 || c.Len() != 2 || c.weight != 6 {
+		t.Errorf("a should be replaced: got %d struct logs, %d traces weighing %d", len(got.StructLogs), c.Len(), c.weight)
+	}
+}
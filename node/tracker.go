@@ -0,0 +1,223 @@
+package node
+
+import (
+	"context"
+	"sync"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"go.uber.org/zap"
+)
+
+// HeadTrackerConfig configures a HeadTracker
+type HeadTrackerConfig struct {
+	// Size is the number of recent canonical blocks kept
+	Size int
+	// Full keeps the blocks with their transactions, otherwise only the hashes of the transactions are kept
+	Full bool
+}
+
+// HeadTrackerStats describes the blocks kept by a HeadTracker
+type HeadTrackerStats struct {
+	Head   uint64 `json:"head"`
+	Blocks int    `json:"blocks"`
+	Full   bool   `json:"full"`
+	Reorgs uint64 `json:"reorgs"`
+}
+
+// HeadTracker keeps the last blocks of the canonical chain in a ring buffer indexed by height.
+// It follows a HeadFeed and fetches every new block, the latest blocks are then served without reaching the node
+// and the head and its height are always consistent with each other.
+type HeadTracker struct {
+	client *CustomClient
+	cfg    HeadTrackerConfig
+	logger *zap.SugaredLogger
+
+	mu   sync.RWMutex
+	ring []*eth.Block
+	// head is the last canonical block, nil until the first block is fetched or after a block could not be
+	head   *eth.Block
+	reorgs uint64
+}
+
+// NewHeadTracker creates an empty tracker, it starts following the chain with Follow
+func NewHeadTracker(client *CustomClient, cfg HeadTrackerConfig, logger *zap.SugaredLogger) *HeadTracker {
+	if cfg.Size <= 0 {
+		cfg.Size = 128
+	}
+	return &HeadTracker{
+		client: client,
+		cfg:    cfg,
+		logger: logger,
+		ring:   make([]*eth.Block, cfg.Size),
+	}
+}
+
+// Follow fetches the blocks of the feed until the context is done
+func (t *HeadTracker) Follow(ctx context.Context, feed *HeadFeed) {
+	var lastID uint64
+	for ctx.Err() == nil {
+		replay, events, cancel := feed.Subscribe(true, lastID)
+		// only the last blocks of the replay fit in the ring
+		var top uint64
+		for _, ev := range replay {
+			if ev.Type == BlockEvent && ev.Head.Number.UInt64() > top {
+				top = ev.Head.Number.UInt64()
+			}
+		}
+		for _, ev := range replay {
+			if ev.Type != BlockEvent || ev.Head.Number.UInt64()+uint64(t.cfg.Size) > top {
+				t.onEvent(ctx, ev)
+			}
+			lastID = ev.ID
+		}
+		open := true
+		for open {
+			select {
+			case <-ctx.Done():
+				cancel()
+				return
+			case ev, ok := <-events:
+				if !ok {
+					open = false
+					break
+				}
+				t.onEvent(ctx, ev)
+				lastID = ev.ID
+			}
+		}
+		cancel()
+	}
+}
+
+// Head returns the last canonical block, ok is false until the tracker follows the chain or if full blocks are not kept
+func (t *HeadTracker) Head(full bool) (b *eth.Block, ok bool) {
+	if full && !t.cfg.Full {
+		return nil, false
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.head == nil {
+		return nil, false
+	}
+	return t.view(t.head, full), true
+}
+
+// BlockByNumber returns a recent canonical block
+func (t *HeadTracker) BlockByNumber(number uint64, full bool) (*eth.Block, bool) {
+	if full && !t.cfg.Full {
+		return nil, false
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	b := t.at(number)
+	if b == nil {
+		return nil, false
+	}
+	return t.view(b, full), true
+}
+
+// Stats describes the blocks kept
+func (t *HeadTracker) Stats() HeadTrackerStats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	s := HeadTrackerStats{Full: t.cfg.Full, Reorgs: t.reorgs}
+	if t.head != nil {
+		s.Head = t.head.Number.UInt64()
+	}
+	for _, b := range t.ring {
+		if b != nil {
+			s.Blocks++
+		}
+	}
+	return s
+}
+
+func (t *HeadTracker) onEvent(ctx context.Context, ev HeadEvent) {
+	switch ev.Type {
+	case BlockEvent:
+		b, err := t.client.BlockByHash(ctx, string(ev.Head.Hash), t.cfg.Full)
+		if err != nil {
+			if ctx.Err() == nil {
+				t.logger.Warnf("head tracker can't get block %d %s err:%s", ev.Head.Number.UInt64(), ev.Head.Hash, err)
+			}
+			// the head is unknown until the next block, the latest block is requested to the node meanwhile
+			t.mu.Lock()
+			t.head = nil
+			t.mu.Unlock()
+			return
+		}
+		t.add(b)
+	case ReorgEvent:
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.reorgs++
+		if ev.Reorg.CommonAncestor != nil {
+			t.truncate(ev.Reorg.CommonAncestor.Number + 1)
+		} else {
+			t.truncate(0)
+		}
+	}
+}
+
+// add makes the block the head, the blocks at its height and above are replaced.
+// A block that doesn't extend the known parent means a reorg was missed, the older blocks are dropped.
+func (t *HeadTracker) add(b *eth.Block) {
+	if b == nil || b.Hash == nil || b.Number == nil {
+		return
+	}
+	n := b.Number.UInt64()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.truncate(n)
+	if n > 0 {
+		if parent := t.at(n - 1); parent != nil && *parent.Hash != b.ParentHash {
+			t.logger.Infof("head tracker block %d %s doesn't extend %s, dropping the older blocks", n, *b.Hash, *parent.Hash)
+			t.reorgs++
+			t.truncate(0)
+		}
+	}
+	t.ring[n%uint64(len(t.ring))] = b
+	t.head = b
+}
+
+// truncate drops the blocks from the height, the head becomes the block below if it is kept, t.mu must be held
+func (t *HeadTracker) truncate(number uint64) {
+	for i, b := range t.ring {
+		if b != nil && b.Number.UInt64() >= number {
+			t.ring[i] = nil
+		}
+	}
+	if t.head != nil && t.head.Number.UInt64() >= number {
+		t.head = nil
+		if number > 0 {
+			t.head = t.at(number - 1)
+		}
+	}
+}
+
+// at returns the block kept at the height, t.mu must be held
+func (t *HeadTracker) at(number uint64) *eth.Block {
+	b := t.ring[number%uint64(len(t.ring))]
+	if b == nil || b.Number.UInt64() != number {
+		return nil
+	}
+	return b
+}
+
+// view returns the block as requested, the transactions of a full block are replaced by their hashes for a light one
+func (t *HeadTracker) view(b *eth.Block, full bool) *eth.Block {
+	if full || !t.cfg.Full {
+		return b
+	}
+	return lightBlock(b)
+}
+
+// lightBlock returns a copy of a full block with the hashes of its transactions
+func lightBlock(b *eth.Block) *eth.Block {
+	light := *b
+	light.Transactions = make([]eth.TxOrHash, len(b.Transactions))
+	for i, tx := range b.Transactions {
+		light.Transactions[i] = eth.TxOrHash{Transaction: eth.Transaction{Hash: tx.Hash}}
+	}
+	return &light
+}
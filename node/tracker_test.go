@@ -0,0 +1,96 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/INFURA/infra-test-benjamin-mateo/node/nodetest"
+	"go.uber.org/zap"
+)
+
+func TestHeadTracker(t *testing.T) {
+	srv := nodetest.NewServer(nil)
+	defer srv.Close()
+	client, err := GetNewCustomClient(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	feed := NewHeadFeed(&client, HeadFeedConfig{Depth: 16}, zap.NewNop().Sugar())
+	tracker := NewHeadTracker(&client, HeadTrackerConfig{Size: 4, Full: true}, zap.NewNop().Sugar())
+
+	if _, ok := tracker.Head(false); ok {
+		t.Fatal("head known before following the chain")
+	}
+	go tracker.Follow(ctx, feed)
+
+	// onHead sends the block to the feed and waits for the tracker to make it its head
+	onHead := func(b *eth.Block) {
+		head := &eth.NewHeadsResult{}
+		head.FromBlock(b)
+		if err := feed.onHead(ctx, head); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if got, ok := tracker.Head(true); ok && *got.Hash == *b.Hash {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("tracker didn't follow head %d: %+v", b.Number.UInt64(), tracker.Stats())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	onHead(srv.Chain.Head())
+	for i := 0; i < 5; i++ {
+		onHead(srv.Mine())
+	}
+	head := srv.Chain.Head()
+	n := head.Number.UInt64()
+	if s := tracker.Stats(); s.Head != n || s.Blocks != 4 {
+		t.Errorf("ring not bounded: %+v", s)
+	}
+	if _, ok := tracker.BlockByNumber(n-4, false); ok {
+		t.Error("block older than the ring served")
+	}
+	light, ok := tracker.BlockByNumber(n-1, false)
+	if !ok || *light.Hash != *srv.Chain.Block(n - 1).Hash {
+		t.Fatal("recent block not kept")
+	}
+	for _, tx := range light.Transactions {
+		if tx.Populated {
+			t.Fatal("light block with full transactions")
+		}
+	}
+
+	// a reorg replaces the blocks above the common ancestor
+	replaced := srv.Chain.Block(n - 1)
+	fork := srv.Reorg(2)
+	onHead(fork[len(fork)-1])
+	b, ok := tracker.BlockByNumber(n-1, true)
+	if !ok || *b.Hash == *replaced.Hash || *b.Hash != *srv.Chain.Block(n - 1).Hash {
+		t.Error("reorganized block still served")
+	}
+	if s := tracker.Stats(); s.Reorgs != 1 || s.Head != fork[len(fork)-1].Number.UInt64() {
+		t.Errorf("reorg not tracked: %+v", s)
+	}
+
+	// a block that doesn't extend its parent drops the older blocks
+	orphan := *srv.Chain.Block(n - 2)
+	number := eth.QuantityFromUInt64(tracker.Stats().Head + 1)
+	orphan.Number = &number
+	tracker.add(&orphan)
+	if s := tracker.Stats(); s.Blocks != 1 || s.Reorgs != 2 {
+		t.Errorf("missed reorg not detected: %+v", s)
+	}
+
+	light = tracker.view(b, false)
+	if len(light.Transactions) != len(b.Transactions) || (len(b.Transactions) > 0 && !b.Transactions[0].Populated) {
+		t.Error("light view changed the full block")
+	}
+}
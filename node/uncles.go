@@ -0,0 +1,91 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/INFURA/go-ethlibs/eth"
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"github.com/INFURA/go-ethlibs/node"
+	"github.com/pkg/errors"
+)
+
+// UncleByBlockNumberAndIndex get the header of an uncle based on its index in a block designed by its number
+func (c *CustomClient) UncleByBlockNumberAndIndex(ctx context.Context, number uint64, index uint64) (*eth.Block, error) {
+	n := eth.QuantityFromUInt64(number)
+	i := eth.QuantityFromUInt64(index)
+	return c.uncle(ctx, "eth_getUncleByBlockNumberAndIndex", jsonrpc.MustParams(&n, &i))
+}
+
+// UncleByBlockHashAndIndex get the header of an uncle based on its index in a block designed by its hash
+func (c *CustomClient) UncleByBlockHashAndIndex(ctx context.Context, hash string, index uint64) (*eth.Block, error) {
+	i := eth.QuantityFromUInt64(index)
+	return c.uncle(ctx, "eth_getUncleByBlockHashAndIndex", jsonrpc.MustParams(hash, &i))
+}
+
+// UncleCountByBlockNumber get the number of uncles of a block designed by its number
+func (c *CustomClient) UncleCountByBlockNumber(ctx context.Context, number uint64) (uint64, error) {
+	n := eth.QuantityFromUInt64(number)
+	return c.uncleCount(ctx, "eth_getUncleCountByBlockNumber", jsonrpc.MustParams(&n))
+}
+
+// UncleCountByBlockHash get the number of uncles of a block designed by its hash
+func (c *CustomClient) UncleCountByBlockHash(ctx context.Context, hash string) (uint64, error) {
+	return c.uncleCount(ctx, "eth_getUncleCountByBlockHash", jsonrpc.MustParams(hash))
+}
+
+// uncle returns node.ErrBlockNotFound if the block or the uncle doesn't exist.
+// Uncles are returned without transactions, only their header is known.
+func (c *CustomClient) uncle(ctx context.Context, method string, params jsonrpc.Params) (*eth.Block, error) {
+	request := jsonrpc.Request{
+		ID:     jsonrpc.ID{Num: 1},
+		Method: method,
+		Params: params,
+	}
+
+	response, err := c.Request(ctx, &request)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not make  request")
+	}
+
+	if response.Error != nil {
+		return nil, parseRPCError(*response.Error)
+	}
+
+	if len(response.Result) == 0 || bytes.Equal(response.Result, json.RawMessage(`null`)) {
+		return nil, node.ErrBlockNotFound
+	}
+
+	b := eth.Block{}
+	err = b.UnmarshalJSON(response.Result)
+	return &b, err
+}
+
+// uncleCount returns node.ErrBlockNotFound if the block doesn't exist
+func (c *CustomClient) uncleCount(ctx context.Context, method string, params jsonrpc.Params) (uint64, error) {
+	request := jsonrpc.Request{
+		ID:     jsonrpc.ID{Num: 1},
+		Method: method,
+		Params: params,
+	}
+
+	response, err := c.Request(ctx, &request)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not make  request")
+	}
+
+	if response.Error != nil {
+		return 0, parseRPCError(*response.Error)
+	}
+
+	if len(response.Result) == 0 || bytes.Equal(response.Result, json.RawMessage(`null`)) {
+		return 0, node.ErrBlockNotFound
+	}
+
+	q := eth.Quantity{}
+	if err := q.UnmarshalJSON(response.Result); err != nil {
+		return 0, err
+	}
+	return q.UInt64(), nil
+}
@@ -0,0 +1,36 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/INFURA/go-ethlibs/jsonrpc"
+	"github.com/INFURA/go-ethlibs/node"
+)
+
+func TestUncles(t *testing.T) {
+	results := map[string]string{
+		"eth_getUncleCountByBlockNumber":    `"0x2"`,
+		"eth_getUncleCountByBlockHash":      `null`,
+		"eth_getUncleByBlockNumberAndIndex": `null`,
+	}
+	client, err := node.NewCustomClient(requesterFunc(func(r *jsonrpc.Request) (json.RawMessage, *json.RawMessage) {
+		return json.RawMessage(results[r.Method]), nil
+	}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := CustomClient{Client: client}
+	ctx := context.Background()
+
+	if n, err := c.UncleCountByBlockNumber(ctx, 10); err != nil || n != 2 {
+		t.Errorf("got count %d err %v want 2", n, err)
+	}
+	if _, err := c.UncleCountByBlockHash(ctx, "0xafddcde383196ecacf07def76a2572c86d1f992081cb1023c8decef92b7712ee"); err != node.ErrBlockNotFound {
+		t.Errorf("got err %v want %v", err, node.ErrBlockNotFound)
+	}
+	if _, err := c.UncleByBlockNumberAndIndex(ctx, 10, 5); err != node.ErrBlockNotFound {
+		t.Errorf("got err %v want %v", err, node.ErrBlockNotFound)
+	}
+}